@@ -0,0 +1,198 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	branchprotection "sigs.k8s.io/prow/prow/config"
+	"sigs.k8s.io/prow/prow/github"
+)
+
+// protectionChange is a branch protection setting whose current state on
+// GitHub differs from the one requested by the policy.
+type protectionChange struct {
+	Setting string
+	Current string
+	Desired string
+}
+
+// diffBranchProtection previews the changes applying the policy would make to
+// the current branch protection of a branch, without applying them. It returns
+// no changes when the policy is unmanaged or already matches.
+func diffBranchProtection(state *github.BranchProtection, policy branchprotection.Policy, enableAppsRestrictions bool) []protectionChange {
+	if policy.Protect == nil {
+		return nil
+	}
+	var request *github.BranchProtectionRequest
+	if *policy.Protect {
+		r := makeRequest(policy, enableAppsRestrictions)
+		request = &r
+	}
+	if state == nil || request == nil {
+		if (state == nil) == (request == nil) {
+			return nil
+		}
+		return []protectionChange{{
+			Setting: "protect",
+			Current: strconv.FormatBool(state != nil),
+			Desired: strconv.FormatBool(request != nil),
+		}}
+	}
+
+	var changes []protectionChange
+	if !equalRequiredStatusChecks(state.RequiredStatusChecks, request.RequiredStatusChecks) {
+		changes = append(changes, protectionChange{
+			Setting: "required_status_checks",
+			Current: describeStatusChecks(state.RequiredStatusChecks),
+			Desired: describeStatusChecks(request.RequiredStatusChecks),
+		})
+	}
+	if !equalAdminEnforcement(state.EnforceAdmins, request.EnforceAdmins) {
+		changes = append(changes, protectionChange{
+			Setting: "enforce_admins",
+			Current: strconv.FormatBool(state.EnforceAdmins.Enabled),
+			Desired: strconv.FormatBool(request.EnforceAdmins != nil && *request.EnforceAdmins),
+		})
+	}
+	if !equalRequiredPullRequestReviews(state.RequiredPullRequestReviews, request.RequiredPullRequestReviews) {
+		changes = append(changes, protectionChange{
+			Setting: "required_pull_request_reviews",
+			Current: describeReviews(state.RequiredPullRequestReviews),
+			Desired: describeReviewsRequest(request.RequiredPullRequestReviews),
+		})
+	}
+	if !equalRestrictions(state.Restrictions, request.Restrictions) {
+		changes = append(changes, protectionChange{
+			Setting: "restrictions",
+			Current: describeRestrictions(state.Restrictions),
+			Desired: describeRestrictionsRequest(request.Restrictions),
+		})
+	}
+	if !equalAllowForcePushes(state.AllowForcePushes, request.AllowForcePushes) {
+		changes = append(changes, protectionChange{
+			Setting: "allow_force_pushes",
+			Current: strconv.FormatBool(state.AllowForcePushes.Enabled),
+			Desired: strconv.FormatBool(request.AllowForcePushes),
+		})
+	}
+	if !equalRequiredLinearHistory(state.RequiredLinearHistory, request.RequiredLinearHistory) {
+		changes = append(changes, protectionChange{
+			Setting: "required_linear_history",
+			Current: strconv.FormatBool(state.RequiredLinearHistory.Enabled),
+			Desired: strconv.FormatBool(request.RequiredLinearHistory),
+		})
+	}
+	if !equalAllowDeletions(state.AllowDeletions, request.AllowDeletions) {
+		changes = append(changes, protectionChange{
+			Setting: "allow_deletions",
+			Current: strconv.FormatBool(state.AllowDeletions.Enabled),
+			Desired: strconv.FormatBool(request.AllowDeletions),
+		})
+	}
+	return changes
+}
+
+const noneDescription = "none"
+
+func describeStatusChecks(checks *github.RequiredStatusChecks) string {
+	if checks == nil {
+		return noneDescription
+	}
+	return fmt.Sprintf("strict=%t contexts=%v", checks.Strict, sets.List(sets.New[string](checks.Contexts...)))
+}
+
+func describeReviews(reviews *github.RequiredPullRequestReviews) string {
+	if reviews == nil {
+		return noneDescription
+	}
+	var dismissalUsers, dismissalTeams, bypassUsers, bypassTeams []string
+	if reviews.DismissalRestrictions != nil {
+		dismissalUsers = logins(reviews.DismissalRestrictions.Users)
+		dismissalTeams = teamSlugs(reviews.DismissalRestrictions.Teams)
+	}
+	if reviews.BypassRestrictions != nil {
+		bypassUsers = logins(reviews.BypassRestrictions.Users)
+		bypassTeams = teamSlugs(reviews.BypassRestrictions.Teams)
+	}
+	return fmt.Sprintf("approvals=%d dismiss_stale_reviews=%t require_code_owner_reviews=%t dismissal_users=%v dismissal_teams=%v bypass_users=%v bypass_teams=%v",
+		reviews.RequiredApprovingReviewCount, reviews.DismissStaleReviews, reviews.RequireCodeOwnerReviews,
+		dismissalUsers, dismissalTeams, bypassUsers, bypassTeams)
+}
+
+func describeReviewsRequest(reviews *github.RequiredPullRequestReviewsRequest) string {
+	if reviews == nil {
+		return noneDescription
+	}
+	return fmt.Sprintf("approvals=%d dismiss_stale_reviews=%t require_code_owner_reviews=%t dismissal_users=%v dismissal_teams=%v bypass_users=%v bypass_teams=%v",
+		reviews.RequiredApprovingReviewCount, reviews.DismissStaleReviews, reviews.RequireCodeOwnerReviews,
+		normLogins(reviews.DismissalRestrictions.Users), sortedStrings(reviews.DismissalRestrictions.Teams),
+		normLogins(reviews.BypassRestrictions.Users), sortedStrings(reviews.BypassRestrictions.Teams))
+}
+
+func describeRestrictions(restrictions *github.Restrictions) string {
+	if restrictions == nil {
+		return noneDescription
+	}
+	apps := sets.New[string]()
+	for _, app := range restrictions.Apps {
+		apps.Insert(app.Slug)
+	}
+	return fmt.Sprintf("apps=%v users=%v teams=%v", sets.List(apps), logins(restrictions.Users), teamSlugs(restrictions.Teams))
+}
+
+func describeRestrictionsRequest(restrictions *github.RestrictionsRequest) string {
+	if restrictions == nil {
+		return noneDescription
+	}
+	return fmt.Sprintf("apps=%v users=%v teams=%v", sortedStrings(restrictions.Apps), normLogins(restrictions.Users), sortedStrings(restrictions.Teams))
+}
+
+func logins(users []github.User) []string {
+	normalized := sets.New[string]()
+	for _, user := range users {
+		normalized.Insert(github.NormLogin(user.Login))
+	}
+	return sets.List(normalized)
+}
+
+func teamSlugs(teams []github.Team) []string {
+	slugs := sets.New[string]()
+	for _, team := range teams {
+		slugs.Insert(team.Slug)
+	}
+	return sets.List(slugs)
+}
+
+func normLogins(users *[]string) []string {
+	normalized := sets.New[string]()
+	if users != nil {
+		for _, user := range *users {
+			normalized.Insert(github.NormLogin(user))
+		}
+	}
+	return sets.List(normalized)
+}
+
+func sortedStrings(s *[]string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return sets.List(sets.New[string](*s...))
+}
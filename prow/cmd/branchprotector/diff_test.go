@@ -0,0 +1,159 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/prow/prow/config"
+	"sigs.k8s.io/prow/prow/github"
+)
+
+func TestDiffBranchProtection(t *testing.T) {
+	yes, no := true, false
+	one, two := 1, 2
+	current := &github.BranchProtection{
+		RequiredStatusChecks: &github.RequiredStatusChecks{
+			Strict:   true,
+			Contexts: []string{"unit", "lint"},
+		},
+		EnforceAdmins: github.EnforceAdmins{Enabled: true},
+		RequiredPullRequestReviews: &github.RequiredPullRequestReviews{
+			RequiredApprovingReviewCount: 1,
+		},
+	}
+	matching := config.Policy{
+		Protect: &yes,
+		RequiredStatusChecks: &config.ContextPolicy{
+			Strict:   &yes,
+			Contexts: []string{"lint", "unit"},
+		},
+		Admins: &yes,
+		RequiredPullRequestReviews: &config.ReviewPolicy{
+			Approvals: &one,
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		current  *github.BranchProtection
+		policy   config.Policy
+		expected []protectionChange
+	}{
+		{
+			name:    "matching policy has no changes",
+			current: current,
+			policy:  matching,
+		},
+		{
+			name:    "unmanaged policy has no changes",
+			current: current,
+			policy:  config.Policy{},
+		},
+		{
+			name:   "protecting an unprotected branch",
+			policy: matching,
+			expected: []protectionChange{
+				{Setting: "protect", Current: "false", Desired: "true"},
+			},
+		},
+		{
+			name:    "unprotecting a protected branch",
+			current: current,
+			policy:  config.Policy{Protect: &no},
+			expected: []protectionChange{
+				{Setting: "protect", Current: "true", Desired: "false"},
+			},
+		},
+		{
+			name:   "already unprotected branch has no changes",
+			policy: config.Policy{Protect: &no},
+		},
+		{
+			name:    "changed settings are reported",
+			current: current,
+			policy: config.Policy{
+				Protect: &yes,
+				RequiredStatusChecks: &config.ContextPolicy{
+					Strict:   &yes,
+					Contexts: []string{"lint", "unit", "e2e"},
+				},
+				Admins: &no,
+				RequiredPullRequestReviews: &config.ReviewPolicy{
+					Approvals: &two,
+				},
+				RequiredLinearHistory: &yes,
+			},
+			expected: []protectionChange{
+				{
+					Setting: "required_status_checks",
+					Current: "strict=true contexts=[lint unit]",
+					Desired: "strict=true contexts=[e2e lint unit]",
+				},
+				{
+					Setting: "enforce_admins",
+					Current: "true",
+					Desired: "false",
+				},
+				{
+					Setting: "required_pull_request_reviews",
+					Current: "approvals=1 dismiss_stale_reviews=false require_code_owner_reviews=false dismissal_users=[] dismissal_teams=[] bypass_users=[] bypass_teams=[]",
+					Desired: "approvals=2 dismiss_stale_reviews=false require_code_owner_reviews=false dismissal_users=[] dismissal_teams=[] bypass_users=[] bypass_teams=[]",
+				},
+				{
+					Setting: "required_linear_history",
+					Current: "false",
+					Desired: "true",
+				},
+			},
+		},
+		{
+			name: "restrictions are described by slug and login",
+			current: &github.BranchProtection{
+				Restrictions: &github.Restrictions{
+					Users: []github.User{{Login: "Alice"}},
+					Teams: []github.Team{{Slug: "admins"}},
+				},
+			},
+			policy: config.Policy{
+				Protect: &yes,
+				Restrictions: &config.Restrictions{
+					Users: []string{"alice", "bob"},
+					Teams: []string{"admins"},
+				},
+			},
+			expected: []protectionChange{
+				{
+					Setting: "restrictions",
+					Current: "apps=[] users=[alice] teams=[admins]",
+					Desired: "apps=[] users=[alice bob] teams=[admins]",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := diffBranchProtection(tc.current, tc.policy, false)
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				t.Errorf("changes differ from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -72,7 +72,7 @@ func gatherOptions() options {
 	o := options{}
 	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	fs.BoolVar(&o.confirm, "confirm", false, "Mutate github if set")
-	fs.BoolVar(&o.verifyRestrictions, "verify-restrictions", false, "Verify the restrictions section of the request for authorized apps/collaborators/teams")
+	fs.BoolVar(&o.verifyRestrictions, "verify-restrictions", false, "Verify the restrictions and dismissal restrictions sections of the request for authorized apps/collaborators/teams")
 	fs.BoolVar(&o.enableAppsRestrictions, "enable-apps-restrictions", false, "Enable feature to enforce apps restrictions in branch protection rules")
 	o.config.AddFlags(fs)
 	o.github.AddCustomizedFlags(fs, flagutil.ThrottlerDefaults(defaultTokens, defaultBurst))
@@ -282,6 +282,9 @@ func (p *protector) UpdateOrg(orgName string, org config.Org) error {
 // UpdateRepo updates all branches in the repo with the specified defaults
 func (p *protector) UpdateRepo(orgName string, repoName string, repo config.Repo) error {
 	p.completedRepos[orgName+"/"+repoName] = true
+	if repo.IsArchived() {
+		return nil
+	}
 	if repo.Policy.Unmanaged != nil && *repo.Policy.Unmanaged && !repo.HasManagedBranches() {
 		return nil
 	}
@@ -418,11 +421,19 @@ func (p *protector) authorizedTeams(org, repo string) ([]string, error) {
 }
 
 func validateRestrictions(org, repo string, bp *github.BranchProtectionRequest, authorizedApps, authorizedCollaborators, authorizedTeams []string) []error {
-	if bp == nil || bp.Restrictions == nil {
+	if bp == nil {
 		return nil
 	}
 
 	var errs []error
+	if reviews := bp.RequiredPullRequestReviews; reviews != nil && reviews.DismissalRestrictions.Teams != nil {
+		if unknown := sets.New[string](*reviews.DismissalRestrictions.Teams...).Difference(sets.New[string](authorizedTeams...)); unknown.Len() > 0 {
+			errs = append(errs, fmt.Errorf("the following dismissal restriction teams are not authorized for %s/%s: %s", org, repo, sets.List(unknown)))
+		}
+	}
+	if bp.Restrictions == nil {
+		return errs
+	}
 	if bp.Restrictions.Apps != nil {
 		if unauthorized := sets.New[string](*bp.Restrictions.Apps...).Difference(sets.New[string](authorizedApps...)); unauthorized.Len() > 0 {
 			errs = append(errs, fmt.Errorf("the following apps are not authorized for %s/%s: %s", org, repo, sets.List(unauthorized)))
@@ -446,10 +457,18 @@ func (p *protector) UpdateBranch(orgName, repo string, branchName string, branch
 	if branch.Unmanaged != nil && *branch.Unmanaged {
 		return nil
 	}
-	bp, err := p.cfg.GetPolicy(orgName, repo, branchName, branch, p.cfg.GetPresubmitsStatic(orgName+"/"+repo), &protected)
+	presubmits := p.cfg.GetPresubmitsStatic(orgName + "/" + repo)
+	bp, err := p.cfg.GetPolicy(orgName, repo, branchName, branch, presubmits, &protected)
 	if err != nil {
 		return fmt.Errorf("get policy: %w", err)
 	}
+	logger := logrus.WithField("branch", fmt.Sprintf("%s/%s/%s", orgName, repo, branchName))
+	if warning := config.UnenforcedContextsWarning(bp); warning != "" {
+		logger.Warn(warning)
+	}
+	if err := config.ValidateBranchContexts(branchName, presubmits); err != nil {
+		logger.WithError(err).Warn("Several presubmits report to the same context, so the required contexts of the branch are ambiguous.")
+	}
 	if bp == nil || bp.Protect == nil {
 		return nil
 	}
@@ -2534,6 +2534,46 @@ func TestValidateRequest(t *testing.T) {
 			collaborators:    []string{"foo"},
 			teams:            []string{"bar"},
 		},
+		{
+			name: "dismissal restricted to unknown team results in error",
+			request: &github.BranchProtectionRequest{
+				RequiredPullRequestReviews: &github.RequiredPullRequestReviewsRequest{
+					DismissalRestrictions: github.DismissalRestrictionsRequest{
+						Teams: &[]string{"bar", "foo"},
+					},
+				},
+			},
+			teams: []string{"bar"},
+			errs:  []error{fmt.Errorf("the following dismissal restriction teams are not authorized for %s/%s: [%s]", "org", "repo", "foo")},
+		},
+		{
+			name: "dismissal restricted to known team results in no errors",
+			request: &github.BranchProtectionRequest{
+				RequiredPullRequestReviews: &github.RequiredPullRequestReviewsRequest{
+					DismissalRestrictions: github.DismissalRestrictionsRequest{
+						Teams: &[]string{"bar"},
+					},
+				},
+			},
+			teams: []string{"bar"},
+		},
+		{
+			name: "unknown dismissal and push restriction teams both result in errors",
+			request: &github.BranchProtectionRequest{
+				RequiredPullRequestReviews: &github.RequiredPullRequestReviewsRequest{
+					DismissalRestrictions: github.DismissalRestrictionsRequest{
+						Teams: &[]string{"foo"},
+					},
+				},
+				Restrictions: &github.RestrictionsRequest{
+					Teams: &[]string{"foo"},
+				},
+			},
+			errs: []error{
+				fmt.Errorf("the following dismissal restriction teams are not authorized for %s/%s: [%s]", "org", "repo", "foo"),
+				fmt.Errorf("the following teams are not authorized for %s/%s: [%s]", "org", "repo", "foo"),
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -123,6 +123,9 @@ const (
 	validateLabelWarning                          = "validate-label"
 	requiredJobAnnotationsWarning                 = "required-job-annotations"
 	periodicDefaultCloneWarning                   = "periodic-default-clone-config"
+	reviewDismissalWarning                        = "review-dismissal"
+	strictEnforceAdminsWarning                    = "strict-enforce-admins"
+	unenforcedContextsWarning                     = "unenforced-contexts"
 
 	defaultHourlyTokens = 3000
 	defaultAllowedBurst = 100
@@ -160,6 +163,9 @@ var optionalWarnings = []string{
 	// https://github.com/kubernetes/test-infra/pull/21075#issuecomment-862550510
 	unknownFieldsAllWarning,
 	validateGitHubAppInstallationWarning,
+	reviewDismissalWarning,
+	strictEnforceAdminsWarning,
+	unenforcedContextsWarning,
 }
 
 var throttlerDefaults = flagutil.ThrottlerDefaults(defaultHourlyTokens, defaultAllowedBurst)
@@ -425,6 +431,27 @@ func validate(o options) error {
 		}
 	}
 
+	// The policies are walked once for all checks, so that errors getting
+	// them are only reported once.
+	var branchProtectionChecks []branchProtectionCheck
+	if o.warningEnabled(reviewDismissalWarning) {
+		branchProtectionChecks = append(branchProtectionChecks, reviewDismissalProblems)
+	}
+	if o.warningEnabled(strictEnforceAdminsWarning) {
+		branchProtectionChecks = append(branchProtectionChecks, strictEnforceAdminsProblems)
+	}
+	if len(branchProtectionChecks) > 0 {
+		if err := validateBranchProtectionPolicies(cfg.BranchProtection, branchProtectionChecks...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if o.warningEnabled(unenforcedContextsWarning) {
+		if err := validateUnenforcedContexts(cfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
 	if o.warningEnabled(validateGitHubAppInstallationWarning) {
 		githubClient, err := o.github.GitHubClient(false)
 		if err != nil {
@@ -1407,6 +1434,93 @@ func validateAdditionalConfigIsInOrgRepoDirectoryStructure(root string, filesyst
 	return utilerrors.NewAggregate(errs)
 }
 
+// branchProtectionCheck returns the problems of a branch protection policy,
+// given the policy of the level it inherits from.
+type branchProtectionCheck func(policy, parent config.Policy) []string
+
+// reviewDismissalProblems flags policies whose required approvals and review
+// dismissal settings combine in a likely problematic way.
+func reviewDismissalProblems(policy, parent config.Policy) []string {
+	problems := policy.ReviewDismissalProblems()
+	if reflect.DeepEqual(problems, parent.ReviewDismissalProblems()) {
+		return nil
+	}
+	return problems
+}
+
+// strictEnforceAdminsProblems flags policies requiring strict status checks
+// that admins can bypass as enforce_admins is not set.
+func strictEnforceAdminsProblems(policy, parent config.Policy) []string {
+	if !policy.AdminsBypassStrictChecks() || parent.AdminsBypassStrictChecks() {
+		return nil
+	}
+	return []string{"strict status checks are required, but enforce_admins is not set, so admins can merge PRs that aren't up to date with their base branch"}
+}
+
+// validateUnenforcedContexts flags the branches of the branch protection config
+// that require contexts branchprotector doesn't enforce, as no level of the
+// configuration sets protect. Branches that are not explicitly configured are
+// not checked, as they are only known to GitHub.
+func validateUnenforcedContexts(cfg *config.Config) error {
+	var errs []error
+	for _, orgName := range sets.List(sets.KeySet(cfg.BranchProtection.Orgs)) {
+		org := cfg.BranchProtection.GetOrg(orgName)
+		for _, repoName := range sets.List(sets.KeySet(org.Repos)) {
+			repo := org.GetRepo(repoName)
+			for _, branchName := range sets.List(sets.KeySet(repo.Branches)) {
+				branch, err := repo.GetBranch(branchName)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("error for repo=%s/%s and branch=%s: %w", orgName, repoName, branchName, err))
+					continue
+				}
+				policy, err := cfg.GetPolicy(orgName, repoName, branchName, *branch, cfg.GetPresubmitsStatic(orgName+"/"+repoName), nil)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("error for repo=%s/%s and branch=%s: %w", orgName, repoName, branchName, err))
+					continue
+				}
+				if warning := config.UnenforcedContextsWarning(policy); warning != "" {
+					errs = append(errs, fmt.Errorf("branch protection config for branch %s/%s=%s: %s", orgName, repoName, branchName, warning))
+				}
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// validateBranchProtectionPolicies runs the checks against the policy of every
+// level of the branch protection config. Problems are reported on the level that
+// introduces them, rather than on every level inheriting them.
+func validateBranchProtectionPolicies(bp config.BranchProtection, checks ...branchProtectionCheck) error {
+	var errs []error
+	check := func(where string, policy, parent config.Policy) {
+		var problems []string
+		for _, check := range checks {
+			problems = append(problems, check(policy, parent)...)
+		}
+		if len(problems) > 0 {
+			errs = append(errs, fmt.Errorf("branch protection config for %s: %s", where, strings.Join(problems, "; ")))
+		}
+	}
+	check("all orgs", bp.Policy, config.Policy{})
+	for _, orgName := range sets.List(sets.KeySet(bp.Orgs)) {
+		org := bp.GetOrg(orgName)
+		check(fmt.Sprintf("org %s", orgName), org.Policy, bp.Policy)
+		for _, repoName := range sets.List(sets.KeySet(org.Repos)) {
+			repo := org.GetRepo(repoName)
+			check(fmt.Sprintf("repo %s/%s", orgName, repoName), repo.Policy, org.Policy)
+			for _, branchName := range sets.List(sets.KeySet(repo.Branches)) {
+				branch, err := repo.GetBranch(branchName)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("error for repo=%s/%s and branch=%s: %w", orgName, repoName, branchName, err))
+					continue
+				}
+				check(fmt.Sprintf("branch %s/%s=%s", orgName, repoName, branchName), branch.Policy, repo.Policy)
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
 func validateUnmanagedBranchprotectionConfigDoesntHaveSubconfig(bp config.BranchProtection) error {
 	var errs []error
 	if bp.Unmanaged != nil && *bp.Unmanaged {
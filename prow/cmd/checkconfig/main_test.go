@@ -2209,6 +2209,284 @@ func TestValidateUnmanagedBranchprotectionConfigDoesntHaveSubconfig(t *testing.T
 	}
 }
 
+func TestValidateReviewDismissal(t *testing.T) {
+	t.Parallel()
+	dismissingPolicy := config.Policy{
+		Protect:                    utilpointer.Bool(true),
+		RequiredStatusChecks:       &config.ContextPolicy{Strict: utilpointer.Bool(true)},
+		RequiredPullRequestReviews: &config.ReviewPolicy{Approvals: utilpointer.Int(1), DismissStale: utilpointer.Bool(true)},
+	}
+	const strictProblem = "strict status checks require updating PRs with their base branch, which dismisses their 1 required approvals with dismiss_stale_reviews"
+
+	testCases := []struct {
+		name   string
+		config config.BranchProtection
+
+		expectedErrorMsg string
+	}{
+		{
+			name: "Empty config, no error",
+		},
+		{
+			name: "Org-level problem is reported once",
+			config: config.BranchProtection{
+				Orgs: map[string]config.Org{
+					"my-org": {
+						Policy: dismissingPolicy,
+						Repos: map[string]config.Repo{
+							"my-repo": {
+								Branches: map[string]config.Branch{
+									"my-branch": {Policy: config.Policy{Protect: utilpointer.Bool(true)}},
+								},
+							},
+						},
+					},
+				},
+			},
+
+			expectedErrorMsg: "branch protection config for org my-org: " + strictProblem,
+		},
+		{
+			name: "Repo-level override fixes the org-level problem",
+			config: config.BranchProtection{
+				Orgs: map[string]config.Org{
+					"my-org": {
+						Policy: dismissingPolicy,
+						Repos: map[string]config.Repo{
+							"my-repo": {
+								Policy: config.Policy{
+									RequiredPullRequestReviews: &config.ReviewPolicy{DismissStale: utilpointer.Bool(false)},
+								},
+							},
+						},
+					},
+				},
+			},
+
+			expectedErrorMsg: "branch protection config for org my-org: " + strictProblem,
+		},
+		{
+			name: "Branch-level problems are reported per branch",
+			config: config.BranchProtection{
+				Orgs: map[string]config.Org{
+					"my-org": {
+						Repos: map[string]config.Repo{
+							"my-repo": {
+								Branches: map[string]config.Branch{
+									"dismissing": {Policy: dismissingPolicy},
+									"no-approvals": {Policy: config.Policy{
+										Protect:                    utilpointer.Bool(true),
+										RequiredPullRequestReviews: &config.ReviewPolicy{DismissStale: utilpointer.Bool(true)},
+									}},
+									"fine": {Policy: config.Policy{Protect: utilpointer.Bool(true)}},
+								},
+							},
+						},
+					},
+				},
+			},
+
+			expectedErrorMsg: "[branch protection config for branch my-org/my-repo=dismissing: " + strictProblem +
+				", branch protection config for branch my-org/my-repo=no-approvals: dismiss_stale_reviews has no effect without required_approving_review_count]",
+		},
+	}
+
+	for _, tc := range testCases {
+		var errMsg string
+		err := validateBranchProtectionPolicies(tc.config, reviewDismissalProblems)
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if tc.expectedErrorMsg != errMsg {
+			t.Errorf("%s: expected error message\n%s\ngot error message\n%s", tc.name, tc.expectedErrorMsg, errMsg)
+		}
+	}
+}
+
+func TestValidateStrictEnforceAdmins(t *testing.T) {
+	t.Parallel()
+	strictPolicy := config.Policy{
+		Protect:              utilpointer.Bool(true),
+		RequiredStatusChecks: &config.ContextPolicy{Strict: utilpointer.Bool(true)},
+	}
+	const problem = "strict status checks are required, but enforce_admins is not set, so admins can merge PRs that aren't up to date with their base branch"
+
+	testCases := []struct {
+		name   string
+		config config.BranchProtection
+
+		expectedErrorMsg string
+	}{
+		{
+			name: "Empty config, no error",
+		},
+		{
+			name: "Strict checks enforced for admins, no error",
+			config: config.BranchProtection{
+				Policy: config.Policy{
+					Protect:              utilpointer.Bool(true),
+					RequiredStatusChecks: &config.ContextPolicy{Strict: utilpointer.Bool(true)},
+					Admins:               utilpointer.Bool(true),
+				},
+				Orgs: map[string]config.Org{
+					"my-org": {Repos: map[string]config.Repo{"my-repo": {}}},
+				},
+			},
+		},
+		{
+			name: "Org-level problem is reported once",
+			config: config.BranchProtection{
+				Orgs: map[string]config.Org{
+					"my-org": {
+						Policy: strictPolicy,
+						Repos: map[string]config.Repo{
+							"my-repo": {
+								Branches: map[string]config.Branch{
+									"my-branch": {Policy: config.Policy{Protect: utilpointer.Bool(true)}},
+								},
+							},
+						},
+					},
+				},
+			},
+
+			expectedErrorMsg: "branch protection config for org my-org: " + problem,
+		},
+		{
+			name: "Repo-level enforce_admins fixes the problem",
+			config: config.BranchProtection{
+				Orgs: map[string]config.Org{
+					"my-org": {
+						Policy: strictPolicy,
+						Repos: map[string]config.Repo{
+							"my-repo": {
+								Policy: config.Policy{Admins: utilpointer.Bool(true)},
+								Branches: map[string]config.Branch{
+									"my-branch": {Policy: config.Policy{Protect: utilpointer.Bool(true)}},
+								},
+							},
+						},
+					},
+				},
+			},
+
+			expectedErrorMsg: "branch protection config for org my-org: " + problem,
+		},
+		{
+			name: "Branch-level problems are reported per branch",
+			config: config.BranchProtection{
+				Policy: config.Policy{Admins: utilpointer.Bool(true)},
+				Orgs: map[string]config.Org{
+					"my-org": {
+						Repos: map[string]config.Repo{
+							"my-repo": {
+								Branches: map[string]config.Branch{
+									"bypassed": {Policy: config.Policy{
+										Protect:              utilpointer.Bool(true),
+										RequiredStatusChecks: &config.ContextPolicy{Strict: utilpointer.Bool(true)},
+										Admins:               utilpointer.Bool(false),
+									}},
+									"enforced": {Policy: config.Policy{
+										Protect:              utilpointer.Bool(true),
+										RequiredStatusChecks: &config.ContextPolicy{Strict: utilpointer.Bool(true)},
+									}},
+									"lenient": {Policy: config.Policy{
+										Protect:              utilpointer.Bool(true),
+										RequiredStatusChecks: &config.ContextPolicy{Strict: utilpointer.Bool(false)},
+										Admins:               utilpointer.Bool(false),
+									}},
+								},
+							},
+						},
+					},
+				},
+			},
+
+			expectedErrorMsg: "branch protection config for branch my-org/my-repo=bypassed: " + problem,
+		},
+	}
+
+	for _, tc := range testCases {
+		var errMsg string
+		err := validateBranchProtectionPolicies(tc.config, strictEnforceAdminsProblems)
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if tc.expectedErrorMsg != errMsg {
+			t.Errorf("%s: expected error message\n%s\ngot error message\n%s", tc.name, tc.expectedErrorMsg, errMsg)
+		}
+	}
+}
+
+func TestValidateUnenforcedContexts(t *testing.T) {
+	t.Parallel()
+	contexts := &config.ContextPolicy{Contexts: []string{"my-context"}}
+	cfg := &config.Config{
+		ProwConfig: config.ProwConfig{
+			BranchProtection: config.BranchProtection{
+				Orgs: map[string]config.Org{
+					"my-org": {
+						Repos: map[string]config.Repo{
+							"my-repo": {
+								Branches: map[string]config.Branch{
+									"enforced":    {Policy: config.Policy{Protect: utilpointer.Bool(true), RequiredStatusChecks: contexts}},
+									"unenforced":  {Policy: config.Policy{RequiredStatusChecks: contexts}},
+									"no-contexts": {Policy: config.Policy{Admins: utilpointer.Bool(true)}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	expected := "branch protection config for branch my-org/my-repo=unenforced: " + config.UnenforcedContextsWarning(&config.Policy{RequiredStatusChecks: contexts})
+
+	var errMsg string
+	if err := validateUnenforcedContexts(cfg); err != nil {
+		errMsg = err.Error()
+	}
+	if errMsg != expected {
+		t.Errorf("expected error message\n%s\ngot error message\n%s", expected, errMsg)
+	}
+}
+
+func TestValidateBranchProtectionPolicies(t *testing.T) {
+	t.Parallel()
+	bp := config.BranchProtection{
+		Orgs: map[string]config.Org{
+			"my-org": {
+				Repos: map[string]config.Repo{
+					"my-repo": {
+						Policy: config.Policy{
+							Protect:                    utilpointer.Bool(true),
+							RequiredStatusChecks:       &config.ContextPolicy{Strict: utilpointer.Bool(true)},
+							RequiredPullRequestReviews: &config.ReviewPolicy{Approvals: utilpointer.Int(1), DismissStale: utilpointer.Bool(true)},
+						},
+					},
+					"other-repo": {
+						Branches: map[string]config.Branch{
+							"invalid": {},
+						},
+					},
+				},
+			},
+		},
+	}
+	// Both checks report on the same level, and the invalid branch is reported once.
+	expectedErrorMsg := "[branch protection config for repo my-org/my-repo: strict status checks require updating PRs with their base branch, which dismisses their 1 required approvals with dismiss_stale_reviews; " +
+		"strict status checks are required, but enforce_admins is not set, so admins can merge PRs that aren't up to date with their base branch" +
+		", error for repo=my-org/other-repo and branch=invalid: defined branch policies must set protect or unmanaged=true]"
+
+	var errMsg string
+	if err := validateBranchProtectionPolicies(bp, reviewDismissalProblems, strictEnforceAdminsProblems); err != nil {
+		errMsg = err.Error()
+	}
+	if expectedErrorMsg != errMsg {
+		t.Errorf("expected error message\n%s\ngot error message\n%s", expectedErrorMsg, errMsg)
+	}
+}
+
 type fakeGhAppListingClient struct {
 	installations []github.AppInstallation
 }
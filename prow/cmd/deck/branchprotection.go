@@ -0,0 +1,124 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"sigs.k8s.io/prow/prow/config"
+)
+
+const (
+	// maxBranchProtectionBody is the size limit of candidate branch protection configs.
+	maxBranchProtectionBody = 1 << 20
+	// maxResolvedPolicies is how many resolved policies are sampled at most.
+	maxResolvedPolicies = 50
+)
+
+// branchProtectionValidation is the result of validating a candidate branch protection config.
+type branchProtectionValidation struct {
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors,omitempty"`
+	// Policies samples the policies resolved for the configured orgs, repos
+	// and branches, in that order.
+	Policies []resolvedPolicy `json:"policies,omitempty"`
+}
+
+// resolvedPolicy is the policy of an org, repo or branch after merging in the
+// policies of its parents.
+type resolvedPolicy struct {
+	Org    string        `json:"org"`
+	Repo   string        `json:"repo,omitempty"`
+	Branch string        `json:"branch,omitempty"`
+	Policy config.Policy `json:"policy"`
+}
+
+// handleBranchProtectionValidation validates the candidate branch protection
+// config POSTed as JSON, e.g. for config editing UIs.
+func handleBranchProtectionValidation(log *logrus.Entry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setHeadersNoCaching(w)
+		if r.Method != http.MethodPost {
+			http.Error(w, fmt.Sprintf("bad verb %v", r.Method), http.StatusMethodNotAllowed)
+			return
+		}
+		var bp config.BranchProtection
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBranchProtectionBody))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&bp); err != nil {
+			http.Error(w, fmt.Sprintf("Could not unmarshal branch protection config: %v.", err), http.StatusBadRequest)
+			return
+		}
+
+		result := branchProtectionValidation{Valid: true, Policies: resolvePolicies(bp, maxResolvedPolicies)}
+		if err := config.ValidateBranchProtection(bp); err != nil {
+			result.Valid = false
+			errs := []error{err}
+			if agg, ok := err.(utilerrors.Aggregate); ok {
+				errs = agg.Errors()
+			}
+			for _, err := range errs {
+				result.Errors = append(result.Errors, err.Error())
+			}
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			log.WithError(err).Error("Error marshaling branch protection validation.")
+			http.Error(w, "Could not marshal branch protection validation.", http.StatusInternalServerError)
+			return
+		}
+		writeJSONResponse(w, r, b)
+	}
+}
+
+// resolvePolicies returns the resolved policies of up to max configured orgs,
+// repos and branches. Branches whose policy can't be resolved are skipped.
+// Policies are only resolved until max of them were collected.
+func resolvePolicies(bp config.BranchProtection, max int) []resolvedPolicy {
+	var policies []resolvedPolicy
+	for _, orgName := range sets.List(sets.KeySet(bp.Orgs)) {
+		if len(policies) >= max {
+			break
+		}
+		org := bp.GetOrg(orgName)
+		policies = append(policies, resolvedPolicy{Org: orgName, Policy: org.Policy})
+		for _, repoName := range sets.List(sets.KeySet(org.Repos)) {
+			if len(policies) >= max {
+				break
+			}
+			repo := org.GetRepo(repoName)
+			policies = append(policies, resolvedPolicy{Org: orgName, Repo: repoName, Policy: repo.Policy})
+			for _, branchName := range sets.List(sets.KeySet(repo.Branches)) {
+				if len(policies) >= max {
+					break
+				}
+				branch, err := repo.GetBranch(branchName)
+				if err != nil {
+					continue
+				}
+				policies = append(policies, resolvedPolicy{Org: orgName, Repo: repoName, Branch: branchName, Policy: branch.Policy})
+			}
+		}
+	}
+	return policies
+}
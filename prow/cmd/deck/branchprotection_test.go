@@ -0,0 +1,128 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
+
+	"sigs.k8s.io/prow/prow/config"
+)
+
+func TestHandleBranchProtectionValidation(t *testing.T) {
+	yes := true
+	testCases := []struct {
+		name     string
+		method   string
+		body     string
+		httpCode int
+		expected *branchProtectionValidation
+	}{
+		{
+			name:     "valid candidate",
+			method:   http.MethodPost,
+			body:     `{"protect": true, "orgs": {"org": {"repos": {"repo": {"required_status_checks": {"contexts": ["unit"]}, "branches": {"main": {"enforce_admins": true}}}}}}}`,
+			httpCode: http.StatusOK,
+			expected: &branchProtectionValidation{
+				Valid: true,
+				Policies: []resolvedPolicy{
+					{Org: "org", Policy: config.Policy{Protect: &yes}},
+					{Org: "org", Repo: "repo", Policy: config.Policy{Protect: &yes, RequiredStatusChecks: &config.ContextPolicy{Contexts: []string{"unit"}}}},
+					{Org: "org", Repo: "repo", Branch: "main", Policy: config.Policy{Protect: &yes, Admins: &yes, RequiredStatusChecks: &config.ContextPolicy{Contexts: []string{"unit"}}}},
+				},
+			},
+		},
+		{
+			name:     "invalid candidate",
+			method:   http.MethodPost,
+			body:     `{"orgs": {"org": {"include": ["("], "repos": {"repo": {"branches": {"main": {"enforce_admins": true}}}}}}}`,
+			httpCode: http.StatusOK,
+			expected: &branchProtectionValidation{
+				Errors: []string{
+					"org: invalid include pattern \"(\": error parsing regexp: missing closing ): `(`",
+					"org/repo=main: defined branch policies must set protect or unmanaged=true",
+				},
+				Policies: []resolvedPolicy{
+					{Org: "org", Policy: config.Policy{Include: []string{"("}}},
+					{Org: "org", Repo: "repo", Policy: config.Policy{Include: []string{"("}}},
+				},
+			},
+		},
+		{
+			name:     "unknown field",
+			method:   http.MethodPost,
+			body:     `{"protected": true}`,
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "bad verb",
+			method:   http.MethodGet,
+			httpCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/branch-protection/validate", strings.NewReader(tc.body))
+			rr := httptest.NewRecorder()
+			handleBranchProtectionValidation(logrus.WithField("handler", "/branch-protection/validate")).ServeHTTP(rr, req)
+			if rr.Code != tc.httpCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tc.httpCode, rr.Code, rr.Body.String())
+			}
+			if tc.expected == nil {
+				return
+			}
+			var actual branchProtectionValidation
+			if err := json.Unmarshal(rr.Body.Bytes(), &actual); err != nil {
+				t.Fatalf("Could not unmarshal response: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, &actual); diff != "" {
+				t.Errorf("Validation differs from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestResolvePoliciesLimit(t *testing.T) {
+	bp := config.BranchProtection{
+		Orgs: map[string]config.Org{
+			"a": {Repos: map[string]config.Repo{"repo": {}}},
+			"b": {},
+		},
+	}
+	policies := resolvePolicies(bp, 2)
+	var names []string
+	for _, policy := range policies {
+		names = append(names, policy.Org+"/"+policy.Repo)
+	}
+	if diff := cmp.Diff([]string{"a/", "a/repo"}, names); diff != "" {
+		t.Errorf("Resolved policies differ from expected (-want +got):\n%s", diff)
+	}
+}
+
+func TestResolvePoliciesNone(t *testing.T) {
+	bp := config.BranchProtection{Orgs: map[string]config.Org{"a": {}}}
+	if policies := resolvePolicies(bp, 0); len(policies) != 0 {
+		t.Errorf("Expected no policies to be resolved, got %v", policies)
+	}
+}
@@ -134,6 +134,7 @@ type options struct {
 	storage               prowflagutil.StorageClientOptions
 	gcsCookieAuth         bool
 	rerunCreatesJob       bool
+	bpValidation          bool
 	allowInsecure         bool
 	controllerManager     prowflagutil.ControllerManagerOptions
 	dryRun                bool
@@ -181,6 +182,7 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	fs.StringVar(&o.templateFilesLocation, "template-files-location", fmt.Sprintf("%s%s", os.Getenv("KO_DATA_PATH"), defaultTemplateFilesLocation), "Path to the template files")
 	fs.BoolVar(&o.gcsCookieAuth, "gcs-cookie-auth", false, "Use storage.cloud.google.com instead of signed URLs")
 	fs.BoolVar(&o.rerunCreatesJob, "rerun-creates-job", false, "Change the re-run option in Deck to actually create the job. **WARNING:** Only use this with non-public deck instances, otherwise strangers can DOS your Prow instance")
+	fs.BoolVar(&o.bpValidation, "validate-branch-protection", false, "Serve /branch-protection/validate to validate candidate branch protection configs. **WARNING:** The endpoint is unauthenticated, only use this with non-public deck instances")
 	fs.BoolVar(&o.allowInsecure, "allow-insecure", false, "Allows insecure requests for CSRF and GitHub oauth.")
 	fs.BoolVar(&o.dryRun, "dry-run", false, "Whether or not to make mutating API calls to GitHub.")
 	fs.Var(&o.tenantIDs, "tenant-id", "The tenantID(s) used by the ProwJobs that should be displayed by this instance of Deck. This flag can be repeated.")
@@ -309,6 +311,9 @@ func main() {
 	mux.Handle("/static/", http.StripPrefix("/static", staticHandlerFromDir(o.staticFilesLocation)))
 	mux.Handle("/config", gziphandler.GzipHandler(handleConfig(cfg, logrus.WithField("handler", "/config"))))
 	mux.Handle("/plugin-config", gziphandler.GzipHandler(handlePluginConfig(pluginAgent, logrus.WithField("handler", "/plugin-config"))))
+	if o.bpValidation {
+		mux.Handle("/branch-protection/validate", gziphandler.GzipHandler(handleBranchProtectionValidation(logrus.WithField("handler", "/branch-protection/validate"))))
+	}
 	mux.Handle("/favicon.ico", gziphandler.GzipHandler(handleFavicon(o.staticFilesLocation, cfg)))
 
 	// Set up handlers for template pages.
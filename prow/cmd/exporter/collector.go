@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,8 +29,10 @@ import (
 
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
 
 	prowapi "sigs.k8s.io/prow/prow/apis/prowjobs/v1"
+	"sigs.k8s.io/prow/prow/config"
 	"sigs.k8s.io/prow/prow/kube"
 )
 
@@ -37,6 +40,52 @@ type lister interface {
 	List(selector labels.Selector) ([]*prowapi.ProwJob, error)
 }
 
+// jobLister only lists the ProwJobs of a single job, so that the collectors
+// aggregate nothing else.
+type jobLister struct {
+	lister lister
+	job    string
+}
+
+func (l jobLister) List(selector labels.Selector) ([]*prowapi.ProwJob, error) {
+	prowJobs, err := l.lister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	var filtered []*prowapi.ProwJob
+	for _, pj := range prowJobs {
+		if pj.Spec.Job == l.job {
+			filtered = append(filtered, pj)
+		}
+	}
+	return filtered, nil
+}
+
+// jobInformer only notifies its event handlers of the ProwJobs of a single job,
+// so that the histograms fed by them record nothing else.
+type jobInformer struct {
+	cache.SharedIndexInformer
+	job string
+}
+
+func (i jobInformer) AddEventHandler(handler cache.ResourceEventHandler) {
+	i.SharedIndexInformer.AddEventHandler(i.filter(handler))
+}
+
+func (i jobInformer) AddEventHandlerWithResyncPeriod(handler cache.ResourceEventHandler, resyncPeriod time.Duration) {
+	i.SharedIndexInformer.AddEventHandlerWithResyncPeriod(i.filter(handler), resyncPeriod)
+}
+
+func (i jobInformer) filter(handler cache.ResourceEventHandler) cache.ResourceEventHandler {
+	return cache.FilteringResourceEventHandler{
+		FilterFunc: func(obj interface{}) bool {
+			pj, ok := obj.(*prowapi.ProwJob)
+			return ok && pj.Spec.Job == i.job
+		},
+		Handler: handler,
+	}
+}
+
 // https://godoc.org/github.com/prometheus/client_golang/prometheus#Collector
 type prowJobCollector struct {
 	lister lister
@@ -94,6 +143,160 @@ func (pjc prowJobCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 }
 
+var prowJobResultsDesc = prometheus.NewDesc(
+	"prow_job_results",
+	"Number of prow jobs that completed with the given state within the rolling window.",
+	[]string{"job_name", "job_namespace", "state"}, nil,
+)
+
+// prowJobResultCollector counts jobs by result over a rolling window ending at collection time.
+type prowJobResultCollector struct {
+	lister lister
+	window time.Duration
+	now    func() time.Time
+}
+
+func (c prowJobResultCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prowJobResultsDesc
+}
+
+func (c prowJobResultCollector) Collect(ch chan<- prometheus.Metric) {
+	logrus.Debug("ProwJobResultCollector collecting ...")
+	prowJobs, err := c.lister.List(labels.Everything())
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list prow jobs")
+		return
+	}
+	type resultKey struct {
+		job, namespace string
+		state          prowapi.ProwJobState
+	}
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	windowStart := now().Add(-c.window)
+	counts := map[resultKey]int{}
+	for _, pj := range prowJobs {
+		if !pj.Complete() || pj.Status.CompletionTime.Time.Before(windowStart) {
+			continue
+		}
+		counts[resultKey{job: pj.Spec.Job, namespace: pj.Namespace, state: pj.Status.State}]++
+	}
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(
+			prowJobResultsDesc,
+			prometheus.GaugeValue,
+			float64(count),
+			key.job, key.namespace, string(key.state),
+		)
+	}
+}
+
+var prowJobOrphansDesc = prometheus.NewDesc(
+	"prow_job_orphans",
+	"Number of pending prow jobs whose pod no longer exists in their build cluster.",
+	[]string{"job_name", "job_namespace", "cluster"}, nil,
+)
+
+// podSet returns the names of the existing pods, keyed by build cluster alias.
+// Jobs running in clusters missing from the result are never counted as orphans.
+type podSet func() (map[string]sets.Set[string], error)
+
+// prowJobOrphanCollector counts pending jobs whose backing pod is gone.
+type prowJobOrphanCollector struct {
+	lister lister
+	pods   podSet
+}
+
+func (c prowJobOrphanCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prowJobOrphansDesc
+}
+
+func (c prowJobOrphanCollector) Collect(ch chan<- prometheus.Metric) {
+	logrus.Debug("ProwJobOrphanCollector collecting ...")
+	prowJobs, err := c.lister.List(labels.Everything())
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list prow jobs")
+		return
+	}
+	pods, err := c.pods()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to list pods")
+		return
+	}
+	type orphanKey struct {
+		job, namespace, cluster string
+	}
+	counts := map[orphanKey]int{}
+	for _, pj := range prowJobs {
+		if pj.Spec.Agent != prowapi.KubernetesAgent || pj.Status.State != prowapi.PendingState {
+			continue
+		}
+		podName := pj.Status.PodName
+		if podName == "" {
+			podName = pj.Name
+		}
+		clusterPods, known := pods[pj.ClusterAlias()]
+		if !known || clusterPods.Has(podName) {
+			continue
+		}
+		counts[orphanKey{job: pj.Spec.Job, namespace: pj.Namespace, cluster: pj.ClusterAlias()}]++
+	}
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(
+			prowJobOrphansDesc,
+			prometheus.GaugeValue,
+			float64(count),
+			key.job, key.namespace, key.cluster,
+		)
+	}
+}
+
+var branchProtectionReposDesc = prometheus.NewDesc(
+	"prow_branch_protection_repos",
+	"Number of repos configured in the branch protection config, by whether their protection is enabled.",
+	[]string{"state"}, nil,
+)
+
+var branchProtectionApprovalsDesc = prometheus.NewDesc(
+	"prow_branch_protection_required_approvals",
+	"Number of protected branches configured in the branch protection config, by the number of approving reviews they require.",
+	[]string{"approvals"}, nil,
+)
+
+// branchProtectionCollector counts the configured repos of the branch
+// protection config by protection state, and the configured protected branches
+// by required approvals.
+type branchProtectionCollector struct {
+	config config.Getter
+}
+
+func (c branchProtectionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- branchProtectionReposDesc
+	ch <- branchProtectionApprovalsDesc
+}
+
+func (c branchProtectionCollector) Collect(ch chan<- prometheus.Metric) {
+	logrus.Debug("BranchProtectionCollector collecting ...")
+	for state, count := range c.config().BranchProtection.CountReposByProtection() {
+		ch <- prometheus.MustNewConstMetric(
+			branchProtectionReposDesc,
+			prometheus.GaugeValue,
+			float64(count),
+			string(state),
+		)
+	}
+	for approvals, count := range c.config().BranchProtection.CountBranchesByApprovals() {
+		ch <- prometheus.MustNewConstMetric(
+			branchProtectionApprovalsDesc,
+			prometheus.GaugeValue,
+			float64(count),
+			strconv.Itoa(approvals),
+		)
+	}
+}
+
 func getLatest(jobs []*prowapi.ProwJob) map[string]*prowapi.ProwJob {
 	latest := map[string]time.Time{}
 	latestJobs := map[string]*prowapi.ProwJob{}
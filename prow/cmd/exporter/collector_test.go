@@ -18,18 +18,24 @@ package main
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/sirupsen/logrus"
 
 	prowapi "sigs.k8s.io/prow/prow/apis/prowjobs/v1"
+	"sigs.k8s.io/prow/prow/config"
+	"sigs.k8s.io/prow/prow/metrics/prowjobs"
 )
 
 func TestKubeLabelsToPrometheusLabels(t *testing.T) {
@@ -459,3 +465,202 @@ func TestGetLatest(t *testing.T) {
 		})
 	}
 }
+
+type jobsLister []*prowapi.ProwJob
+
+func (l jobsLister) List(selector labels.Selector) ([]*prowapi.ProwJob, error) {
+	return l, nil
+}
+
+func TestProwJobResultCollector(t *testing.T) {
+	now := time.Date(2023, time.January, 1, 12, 0, 0, 0, time.UTC)
+	job := func(name string, state prowapi.ProwJobState, completedAgo *time.Duration) *prowapi.ProwJob {
+		pj := &prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default"},
+			Spec:       prowapi.ProwJobSpec{Job: name},
+			Status:     prowapi.ProwJobStatus{State: state},
+		}
+		if completedAgo != nil {
+			completionTime := metav1.NewTime(now.Add(-*completedAgo))
+			pj.Status.CompletionTime = &completionTime
+		}
+		return pj
+	}
+	inside, outside := 10*time.Minute, 2*time.Hour
+
+	c := prowJobResultCollector{
+		lister: jobsLister{
+			job("foo", prowapi.SuccessState, &inside),
+			job("foo", prowapi.SuccessState, &inside),
+			job("foo", prowapi.FailureState, &inside),
+			job("foo", prowapi.FailureState, &outside),
+			job("bar", prowapi.SuccessState, &outside),
+			job("bar", prowapi.ErrorState, &inside),
+			job("bar", prowapi.PendingState, nil),
+		},
+		window: time.Hour,
+		now:    func() time.Time { return now },
+	}
+	expected := `
+# HELP prow_job_results Number of prow jobs that completed with the given state within the rolling window.
+# TYPE prow_job_results gauge
+prow_job_results{job_name="bar",job_namespace="default",state="error"} 1
+prow_job_results{job_name="foo",job_namespace="default",state="failure"} 1
+prow_job_results{job_name="foo",job_namespace="default",state="success"} 2
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestJobLister(t *testing.T) {
+	job := func(name string, state prowapi.ProwJobState) *prowapi.ProwJob {
+		completionTime := metav1.Now()
+		return &prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default"},
+			Spec:       prowapi.ProwJobSpec{Job: name},
+			Status:     prowapi.ProwJobStatus{State: state, CompletionTime: &completionTime},
+		}
+	}
+
+	c := prowJobResultCollector{
+		lister: jobLister{
+			lister: jobsLister{
+				job("foo", prowapi.SuccessState),
+				job("foo", prowapi.FailureState),
+				job("foo", prowapi.FailureState),
+				job("bar", prowapi.FailureState),
+				job("foo-bar", prowapi.SuccessState),
+			},
+			job: "foo",
+		},
+		window: time.Hour,
+	}
+	expected := `
+# HELP prow_job_results Number of prow jobs that completed with the given state within the rolling window.
+# TYPE prow_job_results gauge
+prow_job_results{job_name="foo",job_namespace="default",state="failure"} 2
+prow_job_results{job_name="foo",job_namespace="default",state="success"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+// fakeInformer records the event handlers added to it.
+type fakeInformer struct {
+	cache.SharedIndexInformer
+	handlers []cache.ResourceEventHandler
+}
+
+func (f *fakeInformer) AddEventHandler(handler cache.ResourceEventHandler) {
+	f.handlers = append(f.handlers, handler)
+}
+
+func TestJobInformer(t *testing.T) {
+	update := func(name string) (*prowapi.ProwJob, *prowapi.ProwJob) {
+		startTime := metav1.NewTime(time.Now().Add(-time.Minute))
+		oldJob := &prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default"},
+			Spec:       prowapi.ProwJobSpec{Job: name, Type: prowapi.PeriodicJob},
+			Status:     prowapi.ProwJobStatus{State: prowapi.TriggeredState, StartTime: startTime},
+		}
+		newJob := oldJob.DeepCopy()
+		pendingTime := metav1.Now()
+		newJob.Status.State = prowapi.PendingState
+		newJob.Status.PendingTime = &pendingTime
+		return oldJob, newJob
+	}
+
+	informer := &fakeInformer{}
+	histogramVec := prowjobs.NewProwJobSchedulingLatencyHistogramVec(jobInformer{SharedIndexInformer: informer, job: "foo"})
+	for _, name := range []string{"foo", "bar", "foo-bar"} {
+		for _, handler := range informer.handlers {
+			handler.OnUpdate(update(name))
+		}
+	}
+	if got := testutil.CollectAndCount(histogramVec); got != 1 {
+		t.Errorf("Expected the latency of a single job to be recorded, got %d", got)
+	}
+}
+
+func TestProwJobOrphanCollector(t *testing.T) {
+	job := func(name, cluster, podName string, agent prowapi.ProwJobAgent, state prowapi.ProwJobState) *prowapi.ProwJob {
+		return &prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       prowapi.ProwJobSpec{Job: "foo", Agent: agent, Cluster: cluster},
+			Status:     prowapi.ProwJobStatus{State: state, PodName: podName},
+		}
+	}
+
+	c := prowJobOrphanCollector{
+		lister: jobsLister{
+			job("matched", "", "matched", prowapi.KubernetesAgent, prowapi.PendingState),
+			job("matched-by-name", "", "", prowapi.KubernetesAgent, prowapi.PendingState),
+			job("orphan", "", "orphan", prowapi.KubernetesAgent, prowapi.PendingState),
+			job("other-orphan", "", "", prowapi.KubernetesAgent, prowapi.PendingState),
+			job("build-orphan", "build", "build-orphan", prowapi.KubernetesAgent, prowapi.PendingState),
+			job("unknown-cluster", "unknown", "unknown-cluster", prowapi.KubernetesAgent, prowapi.PendingState),
+			job("completed", "", "completed", prowapi.KubernetesAgent, prowapi.SuccessState),
+			job("triggered", "", "", prowapi.KubernetesAgent, prowapi.TriggeredState),
+			job("tekton", "", "", prowapi.TektonAgent, prowapi.PendingState),
+		},
+		pods: func() (map[string]sets.Set[string], error) {
+			return map[string]sets.Set[string]{
+				prowapi.DefaultClusterAlias: sets.New[string]("matched", "matched-by-name"),
+				"build":                     sets.New[string](),
+			}, nil
+		},
+	}
+	expected := `
+# HELP prow_job_orphans Number of pending prow jobs whose pod no longer exists in their build cluster.
+# TYPE prow_job_orphans gauge
+prow_job_orphans{cluster="build",job_name="foo",job_namespace="default"} 1
+prow_job_orphans{cluster="default",job_name="foo",job_namespace="default"} 2
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBranchProtectionCollector(t *testing.T) {
+	yes, no := true, false
+	one, two := 1, 2
+	cfg := &config.Config{
+		ProwConfig: config.ProwConfig{
+			BranchProtection: config.BranchProtection{
+				Orgs: map[string]config.Org{
+					"org": {
+						Policy: config.Policy{Protect: &yes},
+						Repos: map[string]config.Repo{
+							"protected": {
+								Policy: config.Policy{RequiredPullRequestReviews: &config.ReviewPolicy{Approvals: &two}},
+								Branches: map[string]config.Branch{
+									"main":    {},
+									"release": {},
+									"dev":     {Policy: config.Policy{RequiredPullRequestReviews: &config.ReviewPolicy{Approvals: &one}}},
+								},
+							},
+							"unprotected": {Policy: config.Policy{Protect: &no}},
+						},
+					},
+				},
+			},
+		},
+	}
+	c := branchProtectionCollector{config: func() *config.Config { return cfg }}
+	expected := `
+# HELP prow_branch_protection_repos Number of repos configured in the branch protection config, by whether their protection is enabled.
+# TYPE prow_branch_protection_repos gauge
+prow_branch_protection_repos{state="protected"} 1
+prow_branch_protection_repos{state="unmanaged"} 0
+prow_branch_protection_repos{state="unprotected"} 1
+# HELP prow_branch_protection_required_approvals Number of protected branches configured in the branch protection config, by the number of approving reviews they require.
+# TYPE prow_branch_protection_required_approvals gauge
+prow_branch_protection_required_approvals{approvals="1"} 1
+prow_branch_protection_required_approvals{approvals="2"} 2
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
@@ -17,28 +17,45 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
 	"sigs.k8s.io/prow/prow/pjutil/pprof"
 
 	prowjobinformer "sigs.k8s.io/prow/prow/client/informers/externalversions"
 	prowflagutil "sigs.k8s.io/prow/prow/flagutil"
 	configflagutil "sigs.k8s.io/prow/prow/flagutil/config"
 	"sigs.k8s.io/prow/prow/interrupts"
+	"sigs.k8s.io/prow/prow/kube"
 	"sigs.k8s.io/prow/prow/logrusutil"
 	"sigs.k8s.io/prow/prow/metrics"
 	"sigs.k8s.io/prow/prow/metrics/prowjobs"
 	"sigs.k8s.io/prow/prow/pjutil"
 )
 
+// podListTimeout bounds listing the pods of a build cluster, so that an
+// unreachable cluster doesn't outlast the scrape.
+const podListTimeout = 5 * time.Second
+
 type options struct {
 	config                 configflagutil.ConfigOptions
 	kubernetes             prowflagutil.KubernetesOptions
 	instrumentationOptions prowflagutil.InstrumentationOptions
+	resultWindow           time.Duration
+	countOrphans           bool
+	jobName                string
 }
 
 func gatherOptions(fs *flag.FlagSet, args ...string) options {
@@ -47,6 +64,9 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	o.config.AddFlags(fs)
 	o.kubernetes.AddFlags(fs)
 	o.instrumentationOptions.AddFlags(fs)
+	fs.DurationVar(&o.resultWindow, "result-window", time.Hour, "Rolling window over which prow_job_results counts completed jobs.")
+	fs.BoolVar(&o.countOrphans, "count-orphans", false, "Expose prow_job_orphans, listing the pods of the build clusters on every scrape.")
+	fs.StringVar(&o.jobName, "job-name", "", "Only expose the metrics of the prow jobs of this job, e.g. to debug a flaky job. All jobs are exposed if unset.")
 	if err := fs.Parse(os.Args[1:]); err != nil {
 		logrus.WithError(err).Fatalf("cannot parse args: '%s'", os.Args[1:])
 	}
@@ -59,14 +79,30 @@ func (o *options) Validate() error {
 			return err
 		}
 	}
+	if o.resultWindow <= 0 {
+		return errors.New("--result-window must be positive")
+	}
 	return nil
 }
 
-func mustRegister(component string, lister lister) *prometheus.Registry {
+func mustRegister(component string, lister lister, resultWindow time.Duration, pods podSet, jobName string) *prometheus.Registry {
+	if jobName != "" {
+		lister = jobLister{lister: lister, job: jobName}
+	}
 	registry := prometheus.NewRegistry()
-	prometheus.WrapRegistererWith(prometheus.Labels{"collector_name": component}, registry).MustRegister(&prowJobCollector{
+	registerer := prometheus.WrapRegistererWith(prometheus.Labels{"collector_name": component}, registry)
+	registerer.MustRegister(&prowJobCollector{
+		lister: lister,
+	}, &prowJobResultCollector{
 		lister: lister,
+		window: resultWindow,
 	})
+	if pods != nil {
+		registerer.MustRegister(&prowJobOrphanCollector{
+			lister: lister,
+			pods:   pods,
+		})
+	}
 	registry.MustRegister(
 		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
 		collectors.NewGoCollector(),
@@ -101,8 +137,26 @@ func main() {
 
 	go informerFactory.Start(interrupts.Context().Done())
 
-	registry := mustRegister("exporter", pjLister)
-	registry.MustRegister(prowjobs.NewProwJobLifecycleHistogramVec(informerFactory.Prow().V1().ProwJobs().Informer()))
+	var pods podSet
+	if o.countOrphans {
+		buildClusterClients, err := o.kubernetes.BuildClusterCoreV1Clients(false)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to create build cluster clients")
+		}
+		pods = buildClusterPods(buildClusterClients, func() string { return cfg().PodNamespace })
+	}
+
+	var pjInformer cache.SharedIndexInformer = informerFactory.Prow().V1().ProwJobs().Informer()
+	if o.jobName != "" {
+		pjInformer = jobInformer{SharedIndexInformer: pjInformer, job: o.jobName}
+	}
+
+	registry := mustRegister("exporter", pjLister, o.resultWindow, pods, o.jobName)
+	registry.MustRegister(
+		prowjobs.NewProwJobLifecycleHistogramVec(pjInformer),
+		prowjobs.NewProwJobSchedulingLatencyHistogramVec(pjInformer),
+		&branchProtectionCollector{config: cfg},
+	)
 
 	// Expose prometheus metrics
 	metrics.ExposeMetricsWithRegistry("exporter", cfg().PushGateway, o.instrumentationOptions.MetricsPort, registry, nil)
@@ -110,3 +164,38 @@ func main() {
 	logrus.Info("exporter is running ...")
 	health.ServeReady()
 }
+
+// buildClusterPods lists the pods created by prow in each build cluster,
+// concurrently and within podListTimeout. Clusters that cannot be listed are
+// left out, so their jobs aren't reported as orphans.
+func buildClusterPods(clients map[string]corev1.CoreV1Interface, namespace func() string) podSet {
+	selector := labels.Set{kube.CreatedByProw: "true"}.AsSelector().String()
+	return func() (map[string]sets.Set[string], error) {
+		ctx, cancel := context.WithTimeout(context.Background(), podListTimeout)
+		defer cancel()
+		ns := namespace()
+		pods := map[string]sets.Set[string]{}
+		var lock sync.Mutex
+		var wg sync.WaitGroup
+		for cluster, client := range clients {
+			wg.Add(1)
+			go func(cluster string, client corev1.CoreV1Interface) {
+				defer wg.Done()
+				podList, err := client.Pods(ns).List(ctx, metav1.ListOptions{LabelSelector: selector})
+				if err != nil {
+					logrus.WithError(err).WithField("cluster", cluster).Warn("Failed to list pods")
+					return
+				}
+				names := sets.New[string]()
+				for _, pod := range podList.Items {
+					names.Insert(pod.Name)
+				}
+				lock.Lock()
+				pods[cluster] = names
+				lock.Unlock()
+			}(cluster, client)
+		}
+		wg.Wait()
+		return pods, nil
+	}
+}
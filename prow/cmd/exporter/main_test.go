@@ -0,0 +1,68 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	clienttesting "k8s.io/client-go/testing"
+
+	"sigs.k8s.io/prow/prow/kube"
+)
+
+func TestBuildClusterPods(t *testing.T) {
+	pod := func(name string, labels map[string]string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-pods", Labels: labels}}
+	}
+	prowLabels := map[string]string{kube.CreatedByProw: "true"}
+	unreachable := fake.NewSimpleClientset()
+	unreachable.PrependReactor("list", "pods", func(clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("unreachable")
+	})
+	clients := map[string]corev1.CoreV1Interface{
+		"default": fake.NewSimpleClientset(
+			pod("a", prowLabels),
+			pod("b", prowLabels),
+			pod("not-prow", nil),
+		).CoreV1(),
+		"build": fake.NewSimpleClientset(pod("c", prowLabels)).CoreV1(),
+		"empty": fake.NewSimpleClientset().CoreV1(),
+		// Clusters that can't be listed are left out.
+		"unreachable": unreachable.CoreV1(),
+	}
+
+	pods, err := buildClusterPods(clients, func() string { return "test-pods" })()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]sets.Set[string]{
+		"default": sets.New[string]("a", "b"),
+		"build":   sets.New[string]("c"),
+		"empty":   sets.New[string](),
+	}
+	if diff := cmp.Diff(expected, pods); diff != "" {
+		t.Errorf("Unexpected pods (-want +got):\n%s", diff)
+	}
+}
@@ -19,20 +19,30 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 
+	v1 "k8s.io/api/core/v1"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/scheme"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/prow/pkg/flagutil"
 	"sigs.k8s.io/prow/prow/config"
 	"sigs.k8s.io/prow/prow/crier/reporters/pubsub"
 	prowflagutil "sigs.k8s.io/prow/prow/flagutil"
 	configflagutil "sigs.k8s.io/prow/prow/flagutil/config"
+	"sigs.k8s.io/prow/prow/gangway"
 	"sigs.k8s.io/prow/prow/interrupts"
 	"sigs.k8s.io/prow/prow/logrusutil"
 	"sigs.k8s.io/prow/prow/metrics"
@@ -51,7 +61,23 @@ type options struct {
 
 	dryRun                 bool
 	gracePeriod            time.Duration
+	minSchemaVersion       int
+	enableTracing          bool
+	recordEvents           bool
+	dumpConfig             bool
+	requireKnownJobs       bool
+	reportFile             string
+	circuitBreakerFailures int
+	circuitBreakerCooldown time.Duration
+	subscriptions          prowflagutil.Strings
 	instrumentationOptions prowflagutil.InstrumentationOptions
+
+	maxAttributeCombinations  int
+	connectivityProbeInterval time.Duration
+	workerPoolSize            int
+	maxDeliveryAttempts       int
+	messageTimeout            time.Duration
+	maxInfraRetryWatchers     int
 }
 
 func (o *options) validate() error {
@@ -61,6 +87,32 @@ func (o *options) validate() error {
 			errs = append(errs, err)
 		}
 	}
+	if o.circuitBreakerFailures < 0 {
+		errs = append(errs, fmt.Errorf("--circuit-breaker-failures must not be negative, got %d", o.circuitBreakerFailures))
+	}
+	if o.connectivityProbeInterval < 0 {
+		errs = append(errs, fmt.Errorf("--connectivity-probe-interval must not be negative, got %s", o.connectivityProbeInterval))
+	}
+	if o.maxDeliveryAttempts < 0 {
+		errs = append(errs, fmt.Errorf("--max-delivery-attempts must not be negative, got %d", o.maxDeliveryAttempts))
+	}
+	if o.messageTimeout < 0 {
+		errs = append(errs, fmt.Errorf("--message-timeout must not be negative, got %s", o.messageTimeout))
+	}
+	if o.maxInfraRetryWatchers < 1 {
+		errs = append(errs, fmt.Errorf("--max-infra-retry-watchers must be positive, got %d", o.maxInfraRetryWatchers))
+	}
+	if o.workerPoolSize < 0 {
+		errs = append(errs, fmt.Errorf("--worker-pool-size must not be negative, got %d", o.workerPoolSize))
+	}
+	if o.maxAttributeCombinations < 0 {
+		errs = append(errs, fmt.Errorf("--max-attribute-combinations must not be negative, got %d", o.maxAttributeCombinations))
+	}
+	for _, pattern := range o.subscriptions.Strings() {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid --subscription pattern %q: %w", pattern, err))
+		}
+	}
 
 	return utilerrors.NewAggregate(errs)
 }
@@ -69,8 +121,23 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	var o options
 	fs.IntVar(&o.port, "port", 80, "HTTP Port.")
 	fs.BoolVar(&o.dryRun, "dry-run", true, "Dry run for testing. Uses API tokens but does not mutate.")
-	fs.DurationVar(&o.gracePeriod, "grace-period", 180*time.Second, "On shutdown, try to handle remaining events for the specified duration. ")
+	fs.DurationVar(&o.gracePeriod, "grace-period", 180*time.Second, "On shutdown, try to handle remaining events for the specified duration. Subscriptions can override it with the drain_grace_period of their trigger.")
 	fs.StringVar(&o.cookiefilePath, "cookiefile", "", "Path to git http.cookiefile, leave empty for github or anonymous")
+	fs.IntVar(&o.minSchemaVersion, "min-schema-version", 0, "Reject messages whose schema-version attribute is below this version. 0 accepts all messages.")
+	fs.BoolVar(&o.enableTracing, "enable-tracing", false, "Record an OpenTelemetry span for every handled message and write it to stderr.")
+	fs.BoolVar(&o.recordEvents, "record-events", false, "Record a warning Kubernetes Event in the ProwJob namespace whenever a Prow Job fails to be created.")
+	fs.BoolVar(&o.dumpConfig, "dump-config", false, "Log the effective config of the pulled subscriptions at startup, with defaults applied and credentials redacted.")
+	fs.BoolVar(&o.requireKnownJobs, "require-known-jobs", false, "Fail startup if a job pattern of the pubsub maintenance windows matches none of the statically configured jobs.")
+	fs.StringVar(&o.reportFile, "report-file", "", "Append the creation status of triggered jobs as JSON lines to this file instead of reporting them to Pub/Sub, e.g. for integration tests.")
+	fs.IntVar(&o.circuitBreakerFailures, "circuit-breaker-failures", 0, "Pause creating Prow Jobs for --circuit-breaker-cooldown after this many consecutive creation failures, nacking messages meanwhile. 0 disables the circuit breaker.")
+	fs.DurationVar(&o.circuitBreakerCooldown, "circuit-breaker-cooldown", time.Minute, "How long the circuit breaker pauses creating Prow Jobs before testing whether creations succeed again.")
+	fs.IntVar(&o.maxAttributeCombinations, "max-attribute-combinations", 1000, "The number of distinct message attribute combinations tracked per subscription by the attribute cardinality metrics. 0 disables tracking.")
+	fs.IntVar(&o.maxDeliveryAttempts, "max-delivery-attempts", 0, "Ack messages that would be redelivered, e.g. during maintenance windows, once they were delivered this many times and report their jobs as failed. Requires a dead letter policy on the subscription. Disabled if 0.")
+	fs.DurationVar(&o.messageTimeout, "message-timeout", 0, "Cancel handling a message after this duration. It is nacked if no Prow Job creation request was sent yet, and reported as failed otherwise. No timeout if 0.")
+	fs.IntVar(&o.maxInfraRetryWatchers, "max-infra-retry-watchers", 100, "The number of Prow Jobs of triggers with max_infra_retries watched for infra failures at once. Jobs created while that many are watched aren't retried.")
+	fs.IntVar(&o.workerPoolSize, "worker-pool-size", 0, "The number of messages of each subscription handled concurrently by a fixed pool of workers. Every message is handled on its own goroutine if 0.")
+	fs.DurationVar(&o.connectivityProbeInterval, "connectivity-probe-interval", 0, "Serve /healthz/pubsub, checking that Pub/Sub can be reached at most once per this interval. Disabled if 0.")
+	fs.Var(&o.subscriptions, "subscription", "Only pull subscriptions whose name matches this glob pattern. Can be passed multiple times. Pulls all configured subscriptions if unset.")
 	for _, group := range []flagutil.OptionGroup{&o.client, &o.github, &o.instrumentationOptions, &o.config} {
 		group.AddFlags(fs)
 	}
@@ -93,13 +160,30 @@ func main() {
 		logrus.WithError(err).Fatal("Error starting config agent.")
 	}
 
+	if o.requireKnownJobs {
+		if missing := configAgent.Config().MissingPubSubJobs(); len(missing) > 0 {
+			logrus.WithField("jobs", missing).Fatal("Pubsub config references jobs that are not configured.")
+		}
+	}
+
 	prowjobClient, err := o.client.ProwJobClient(configAgent.Config().ProwJobNamespace, o.dryRun)
 	if err != nil {
 		logrus.WithError(err).Fatal("unable to create prow job client")
 	}
 
+	buildClusters, err := o.client.KnownClusters(o.dryRun)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error loading the build cluster kubeconfigs.")
+	}
+
 	promMetrics := subscriber.NewMetrics()
+	promMetrics.LastConfigReloadGauge.SetToCurrentTime()
+	configAgent.AddReloadHook(promMetrics.RecordConfigReload)
 
+	// The pull server may take longer to drain the messages being handled than
+	// interrupts waits for workers, so wait for it separately.
+	drained := make(chan struct{})
+	defer func() { <-drained }()
 	defer interrupts.WaitForGracefulShutdown()
 
 	// Expose prometheus and pprof metrics
@@ -116,10 +200,56 @@ func main() {
 	}
 
 	s := &subscriber.Subscriber{
-		ConfigAgent:   configAgent,
-		Metrics:       promMetrics,
-		ProwJobClient: prowjobClient,
-		Reporter:      pubsub.NewReporter(configAgent.Config), // reuse crier reporter
+		ConfigAgent:      configAgent,
+		Metrics:          promMetrics,
+		ProwJobClient:    prowjobClient,
+		BuildClusters:    sets.KeySet(buildClusters),
+		Reporter:         pubsub.NewReporter(configAgent.Config), // reuse crier reporter
+		MinSchemaVersion: o.minSchemaVersion,
+
+		MaxAttributeCombinations: o.maxAttributeCombinations,
+		MaxDeliveryAttempts:      o.maxDeliveryAttempts,
+		MessageTimeout:           o.messageTimeout,
+		MaxInfraRetryWatchers:    o.maxInfraRetryWatchers,
+	}
+	if o.reportFile != "" {
+		s.Reporter = subscriber.NewFileReporter(o.reportFile)
+	}
+	if o.circuitBreakerFailures > 0 {
+		s.CircuitBreaker = &subscriber.CircuitBreaker{
+			FailureThreshold: o.circuitBreakerFailures,
+			Cooldown:         o.circuitBreakerCooldown,
+		}
+	}
+
+	if o.enableTracing {
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stderr))
+		if err != nil {
+			logrus.WithError(err).Fatal("Error creating trace exporter.")
+		}
+		tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+		interrupts.OnInterrupt(func() {
+			if err := tp.Shutdown(context.Background()); err != nil {
+				logrus.WithError(err).Error("Error flushing traces.")
+			}
+		})
+		s.Tracer = tp.Tracer("sub")
+	}
+
+	// Triggers may store their ProwJobs in the cluster of another kube context.
+	s.NewProwJobClient = func(kubeContext string) (gangway.ProwJobClient, error) {
+		return o.client.ProwJobClientForContext(kubeContext, configAgent.Config().ProwJobNamespace, o.dryRun)
+	}
+
+	if o.recordEvents {
+		kubeClient, err := o.client.InfrastructureClusterClient(o.dryRun)
+		if err != nil {
+			logrus.WithError(err).Fatal("Error getting Kubernetes client.")
+		}
+		eventBroadcaster := record.NewBroadcaster()
+		eventBroadcaster.StartRecordingToSink(&corev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+		interrupts.OnInterrupt(eventBroadcaster.Shutdown)
+		s.EventRecorder = eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "sub"})
 	}
 
 	if o.config.MoonrakerAddress != "" {
@@ -138,6 +268,19 @@ func main() {
 			logrus.WithError(err).Fatal("Error creating InRepoConfigCacheGetter.")
 		}
 		s.InRepoConfigGetter = ircc
+		// Triggers may reference their own GitHub App to fetch inrepoconfig
+		// from repos the default credentials can't access.
+		s.NewInRepoConfigGetter = func(app config.PubSubGitHubApp) (config.InRepoConfigGetter, error) {
+			var cacheDir string
+			if o.config.InRepoConfigCacheDirBase != "" {
+				cacheDir = filepath.Join(o.config.InRepoConfigCacheDirBase, "github-app-"+app.AppID)
+			}
+			gitClient, err := o.github.WithGitHubApp(app.AppID, app.PrivateKeyPath).GitClientFactory("", &cacheDir, o.dryRun, false)
+			if err != nil {
+				return nil, fmt.Errorf("error getting Git client: %w", err)
+			}
+			return config.NewInRepoConfigCache(o.config.InRepoConfigCacheSize, configAgent, gitClient)
+		}
 	}
 
 	subMux := http.NewServeMux()
@@ -147,7 +290,21 @@ func main() {
 	// Setting up Pull Server
 	logrus.Info("Setting up Pull Server")
 	pullServer := subscriber.NewPullServer(s)
+	pullServer.Subscriptions = o.subscriptions.Strings()
+	pullServer.GracePeriod = o.gracePeriod
+	pullServer.WorkerPoolSize = o.workerPoolSize
+	if o.connectivityProbeInterval > 0 {
+		subMux.Handle("/healthz/pubsub", subscriber.NewConnectivityProbe(pullServer, o.connectivityProbeInterval))
+	}
+	if o.dumpConfig {
+		effectiveConfig, err := pullServer.EffectiveConfig()
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to serialize the effective subscription config")
+		}
+		logrus.WithField("config", string(effectiveConfig)).Info("Effective subscription config")
+	}
 	interrupts.Run(func(ctx context.Context) {
+		defer close(drained)
 		if err := pullServer.Run(ctx); err != nil {
 			logrus.WithError(err).Fatal("Failed to run Pull Server")
 		}
@@ -18,12 +18,14 @@ package main
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"sigs.k8s.io/prow/prow/cmd/webhook-server/secretmanager"
 )
@@ -85,16 +87,18 @@ func (g *GCPClient) checkSecret(ctx context.Context, secretName string) error {
 
 // for integration testing purposes. Not to be used in prod
 type localFSClient struct {
-	path   string
-	expiry int
-	dns    []string
+	path               string
+	expiry             time.Duration
+	signatureAlgorithm x509.SignatureAlgorithm
+	dns                []string
 }
 
-func NewLocalFSClient(path string, expiry int, dns []string) *localFSClient {
+func NewLocalFSClient(path string, expiry time.Duration, signatureAlgorithm x509.SignatureAlgorithm, dns []string) *localFSClient {
 	return &localFSClient{
-		path:   path,
-		expiry: expiry,
-		dns:    dns,
+		path:               path,
+		expiry:             expiry,
+		signatureAlgorithm: signatureAlgorithm,
+		dns:                dns,
 	}
 }
 
@@ -113,7 +117,7 @@ func (l *localFSClient) AddSecretVersion(ctx context.Context, secretName string,
 	privKeyFile := filepath.Join(l.path, privKeyFile)
 	caBundleFile := filepath.Join(l.path, caBundleFile)
 
-	serverCertPerm, serverPrivKey, caPem, _, err := genSecretData(l.expiry, l.dns)
+	serverCertPerm, serverPrivKey, caPem, _, err := genSecretData(l.expiry, l.signatureAlgorithm, l.dns)
 	if err != nil {
 		return err
 	}
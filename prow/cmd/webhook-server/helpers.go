@@ -28,13 +28,16 @@ import (
 	"fmt"
 	stdio "io"
 	"math/big"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	admregistration "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/prow/prow/config"
 	"sigs.k8s.io/prow/prow/io"
 	"sigs.k8s.io/prow/prow/plank"
@@ -49,12 +52,120 @@ const (
 	prowJobValidatingWebhookName = "prow-job-validating-webhook-config.prow.k8s.io"
 	mutatePath                   = "/mutate"
 	validatePath                 = "/validate"
+	admissionWebhookLabel        = "admission-webhook"
+	prowJobsResource             = "prowjobs"
 )
 
+// resourcePath returns the path the admission reviews of the given resource are
+// served on, e.g. /validate-prowjobs, so that the admission rules of every
+// resource are handled separately.
+func resourcePath(basePath, resource string) string {
+	return basePath + "-" + resource
+}
+
+// unlabeledProwJobsMode determines how the validating webhook handles ProwJobs
+// created without the admission-webhook label.
+type unlabeledProwJobsMode string
+
+const (
+	// unlabeledProwJobsIgnore doesn't send unlabeled ProwJobs to the validating webhook.
+	unlabeledProwJobsIgnore unlabeledProwJobsMode = "ignore"
+	// unlabeledProwJobsWarn sends all ProwJobs to the validating webhook, which
+	// logs and counts unlabeled ones but admits them without validation.
+	unlabeledProwJobsWarn unlabeledProwJobsMode = "warn"
+	// unlabeledProwJobsValidate sends all ProwJobs to the validating webhook,
+	// which validates unlabeled ones too.
+	unlabeledProwJobsValidate unlabeledProwJobsMode = "validate"
+)
+
+// mutation is a default the mutating webhook applies to created ProwJobs.
+type mutation string
+
+const (
+	// mutationDecoration applies the default decoration config of plank.
+	mutationDecoration mutation = "decoration"
+	// mutationCluster sets the cluster of ProwJobs without one to the default
+	// build cluster.
+	mutationCluster mutation = "cluster"
+)
+
+// signatureAlgorithms are the supported algorithms for signing the CA and server certs.
+var signatureAlgorithms = map[string]x509.SignatureAlgorithm{
+	x509.SHA256WithRSA.String(): x509.SHA256WithRSA,
+	x509.SHA384WithRSA.String(): x509.SHA384WithRSA,
+	x509.SHA512WithRSA.String(): x509.SHA512WithRSA,
+}
+
+// parseSignatureAlgorithm returns the supported signature algorithm with the given name.
+func parseSignatureAlgorithm(name string) (x509.SignatureAlgorithm, error) {
+	if alg, ok := signatureAlgorithms[name]; ok {
+		return alg, nil
+	}
+	names := make([]string, 0, len(signatureAlgorithms))
+	for n := range signatureAlgorithms {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported signature algorithm %q, must be one of %s", name, strings.Join(names, ", "))
+}
+
+// parseReinvocationPolicy returns the mutating webhook reinvocation policy of the given name.
+func parseReinvocationPolicy(name string) (admregistration.ReinvocationPolicyType, error) {
+	switch policy := admregistration.ReinvocationPolicyType(name); policy {
+	case admregistration.NeverReinvocationPolicy, admregistration.IfNeededReinvocationPolicy:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unsupported reinvocation policy %q, must be one of %s or %s", name, admregistration.NeverReinvocationPolicy, admregistration.IfNeededReinvocationPolicy)
+}
+
+// parseUnlabeledProwJobsMode returns the unlabeled ProwJobs mode of the given name.
+func parseUnlabeledProwJobsMode(name string) (unlabeledProwJobsMode, error) {
+	switch mode := unlabeledProwJobsMode(name); mode {
+	case unlabeledProwJobsIgnore, unlabeledProwJobsWarn, unlabeledProwJobsValidate:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unsupported unlabeled prowjobs mode %q, must be one of %s, %s or %s", name, unlabeledProwJobsIgnore, unlabeledProwJobsWarn, unlabeledProwJobsValidate)
+}
+
+// parseValidatingOperations returns the operations the validating webhook is registered for.
+func parseValidatingOperations(names []string) ([]admregistration.OperationType, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("at least one validating webhook operation must be specified")
+	}
+	operations := make([]admregistration.OperationType, 0, len(names))
+	for _, name := range names {
+		switch operation := admregistration.OperationType(name); operation {
+		case admregistration.Create, admregistration.Update, admregistration.Delete:
+			operations = append(operations, operation)
+		default:
+			return nil, fmt.Errorf("unsupported validating webhook operation %q, must be one of %s, %s or %s", name, admregistration.Create, admregistration.Update, admregistration.Delete)
+		}
+	}
+	return operations, nil
+}
+
+// parseMutations returns the mutations the mutating webhook applies. Empty
+// names are ignored, so that all mutations can be disabled.
+func parseMutations(names []string) (sets.Set[mutation], error) {
+	mutations := sets.New[mutation]()
+	for _, name := range names {
+		switch m := mutation(name); m {
+		case "":
+		case mutationDecoration, mutationCluster:
+			mutations.Insert(m)
+		default:
+			return nil, fmt.Errorf("unsupported mutation %q, must be one of %s or %s", name, mutationDecoration, mutationCluster)
+		}
+	}
+	return mutations, nil
+}
+
 // for unit testing purposes
 var genCertFunc = genCert
 
-func genCert(expiry int, dnsNames []string) (string, string, string, error) {
+// genCert generates a CA and a server cert signed by it with the given signature
+// algorithm, both valid for the given expiry.
+func genCert(expiry time.Duration, signatureAlgorithm x509.SignatureAlgorithm, dnsNames []string) (string, string, string, error) {
 	//https://gist.github.com/velotiotech/2e0cfd15043513d253cad7c9126d2026#file-initcontainer_main-go
 	var caPEM, serverCertPEM, serverPrivKeyPEM *bytes.Buffer
 	// CA config
@@ -64,7 +175,8 @@ func genCert(expiry int, dnsNames []string) (string, string, string, error) {
 			Organization: []string{org},
 		},
 		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(expiry, 0, 0),
+		NotAfter:              time.Now().Add(expiry),
+		SignatureAlgorithm:    signatureAlgorithm,
 		IsCA:                  true,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
 		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
@@ -101,11 +213,12 @@ func genCert(expiry int, dnsNames []string) (string, string, string, error) {
 			CommonName:   "admission-webhook-service.default.svc", //this field doesn't affect the server cert config
 			Organization: []string{org},
 		},
-		NotBefore:    time.Now(),
-		NotAfter:     time.Now().AddDate(expiry, 0, 0),
-		SubjectKeyId: []byte{1, 2, 3, 4, 6}, //unique identifier for cert
-		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
-		KeyUsage:     x509.KeyUsageDigitalSignature,
+		NotBefore:          time.Now(),
+		NotAfter:           time.Now().Add(expiry),
+		SignatureAlgorithm: signatureAlgorithm,
+		SubjectKeyId:       []byte{1, 2, 3, 4, 6}, //unique identifier for cert
+		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		KeyUsage:           x509.KeyUsageDigitalSignature,
 	}
 
 	// server private key
@@ -143,8 +256,39 @@ func genCert(expiry int, dnsNames []string) (string, string, string, error) {
 
 }
 
-func isCertValid(cert string) error {
+// serviceDNSName returns the in-cluster DNS name that the API server uses to
+// reach the admission webhook service.
+func serviceDNSName(namespace, service string) string {
+	return fmt.Sprintf("%s.%s.svc", service, namespace)
+}
+
+// validateDNSNames ensures that the server cert SANs include the DNS name of the
+// service that Kubernetes routes admission requests to, otherwise the API server
+// will fail to verify the cert.
+func validateDNSNames(dnsNames []string, namespace, service string) error {
+	want := serviceDNSName(namespace, service)
+	for _, name := range dnsNames {
+		if name == want || name == want+".cluster.local" {
+			return nil
+		}
+	}
+	return fmt.Errorf("dns names %v do not include the webhook service name %q", dnsNames, want)
+}
+
+// yearsToDuration converts a number of years from now into a duration, so that
+// year-based expiries keep accounting for leap years.
+func yearsToDuration(years int) time.Duration {
+	now := time.Now()
+	return now.AddDate(years, 0, 0).Sub(now)
+}
+
+// isCertValid returns an error if the cert expired or if its SANs differ from
+// the given DNS names, in which case it needs to be regenerated.
+func isCertValid(cert string, dnsNames []string) error {
 	block, _ := pem.Decode([]byte(cert))
+	if block == nil {
+		return fmt.Errorf("no PEM encoded certificate found")
+	}
 	certificate, err := x509.ParseCertificate(block.Bytes)
 	if err != nil {
 		return err
@@ -152,9 +296,57 @@ func isCertValid(cert string) error {
 	if time.Now().After(certificate.NotAfter) {
 		return fmt.Errorf("certificated expired at %v", certificate.NotAfter)
 	}
+	if want, got := sets.New[string](dnsNames...), sets.New[string](certificate.DNSNames...); !want.Equal(got) {
+		return fmt.Errorf("certificate SANs %v differ from the configured dns names %v", sets.List(got), sets.List(want))
+	}
 	return nil
 }
 
+// caBundle returns the CA bundle to register the webhooks with: the current CA,
+// followed by the unexpired CAs of the previous bundle until the overlap after
+// the current CA was issued has passed. This keeps admission requests served
+// with a certificate of the previous CA working while the rotation rolls out.
+func caBundle(caPem, previousBundle string, overlap time.Duration, now time.Time) (string, error) {
+	if overlap <= 0 || caPem == "" {
+		return caPem, nil
+	}
+	block, _ := pem.Decode([]byte(caPem))
+	if block == nil {
+		return "", fmt.Errorf("no PEM encoded CA certificate found")
+	}
+	ca, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("error parsing CA certificate: %v", err)
+	}
+	if !now.Before(ca.NotBefore.Add(overlap)) {
+		return caPem, nil
+	}
+
+	bundle := caPem
+	seen := map[string]bool{string(block.Bytes): true}
+	rest := []byte(previousBundle)
+	for {
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if seen[string(block.Bytes)] {
+			continue
+		}
+		seen[string(block.Bytes)] = true
+		previous, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			logrus.WithError(err).Warn("Dropping unparseable certificate from the previous CA bundle.")
+			continue
+		}
+		if now.After(previous.NotAfter) {
+			continue
+		}
+		bundle += string(pem.EncodeToMemory(block))
+	}
+	return bundle, nil
+}
+
 func createSecret(client ClientInterface, ctx context.Context, clientoptions clientOptions) (string, string, string, error) {
 	if err := client.CreateSecret(ctx, clientoptions.secretID); err != nil {
 		return "", "", "", fmt.Errorf("unable to create secret %v", err)
@@ -168,7 +360,7 @@ func createSecret(client ClientInterface, ctx context.Context, clientoptions cli
 }
 
 func updateSecret(client ClientInterface, ctx context.Context, clientoptions clientOptions) (string, string, string, error) {
-	serverCertPerm, serverPrivKey, caPem, secretData, err := genSecretData(clientoptions.expiryInYears, clientoptions.dnsNames.Strings())
+	serverCertPerm, serverPrivKey, caPem, secretData, err := genSecretData(clientoptions.expiry, clientoptions.signatureAlgorithm, clientoptions.dnsNames.Strings())
 	if err != nil {
 		return "", "", "", err
 	}
@@ -180,8 +372,8 @@ func updateSecret(client ClientInterface, ctx context.Context, clientoptions cli
 	return serverCertPerm, serverPrivKey, caPem, nil
 }
 
-func genSecretData(expiry int, dns []string) (string, string, string, []byte, error) {
-	serverCertPerm, serverPrivKey, caPem, err := genCertFunc(expiry, dns)
+func genSecretData(expiry time.Duration, signatureAlgorithm x509.SignatureAlgorithm, dns []string) (string, string, string, []byte, error) {
+	serverCertPerm, serverPrivKey, caPem, err := genCertFunc(expiry, signatureAlgorithm, dns)
 	if err != nil {
 		return "", "", "", nil, fmt.Errorf("could not generate ca credentials")
 	}
@@ -199,13 +391,13 @@ func genSecretData(expiry int, dns []string) (string, string, string, []byte, er
 	return serverCertPerm, serverPrivKey, caPem, secretData, nil
 }
 
-func ensureValidatingWebhookConfig(ctx context.Context, caPem string, client ctrlruntimeclient.Client) error {
-	operations := []admregistration.OperationType{"CREATE", "UPDATE"}
+// newValidatingWebhookConfig generates the ValidatingWebhookConfiguration for the prowjob validating webhook.
+func newValidatingWebhookConfig(caPem string, operations []admregistration.OperationType, unlabeledProwJobs unlabeledProwJobsMode, namespaceSelector *v1.LabelSelector) *admregistration.ValidatingWebhookConfiguration {
 	scope := admregistration.ScopeType("*")
-	path := validatePath
+	path := resourcePath(validatePath, prowJobsResource)
 	sideEffects := admregistration.SideEffectClass("None")
 
-	validatingWebhookConfig := &admregistration.ValidatingWebhookConfiguration{
+	return &admregistration.ValidatingWebhookConfiguration{
 		TypeMeta: v1.TypeMeta{
 			Kind:       "ValidatingWebhookConfiguration",
 			APIVersion: "admissionregistration.k8s.io/v1",
@@ -215,19 +407,16 @@ func ensureValidatingWebhookConfig(ctx context.Context, caPem string, client ctr
 		},
 		Webhooks: []admregistration.ValidatingWebhook{
 			{
-				Name: prowJobValidatingWebhookName,
-				ObjectSelector: &v1.LabelSelector{
-					MatchLabels: map[string]string{
-						"admission-webhook": "enabled", // for now till there is more confidence, ensures only prowjobs with this label are affected
-					},
-				},
+				Name:              prowJobValidatingWebhookName,
+				ObjectSelector:    validatingObjectSelector(unlabeledProwJobs),
+				NamespaceSelector: namespaceSelector,
 				Rules: []admregistration.RuleWithOperations{
 					{
 						Operations: operations,
 						Rule: admregistration.Rule{
 							APIGroups:   []string{"prow.k8s.io"},
 							APIVersions: []string{"v1"},
-							Resources:   []string{"prowjobs"},
+							Resources:   []string{prowJobsResource},
 							Scope:       &scope,
 						},
 					},
@@ -246,6 +435,49 @@ func ensureValidatingWebhookConfig(ctx context.Context, caPem string, client ctr
 		},
 	}
 
+}
+
+// validatingObjectSelector returns the selector of the ProwJobs sent to the validating webhook.
+func validatingObjectSelector(unlabeledProwJobs unlabeledProwJobsMode) *v1.LabelSelector {
+	if unlabeledProwJobs == unlabeledProwJobsWarn || unlabeledProwJobs == unlabeledProwJobsValidate {
+		// Match everything so that unlabeled ProwJobs can't escape validation unnoticed.
+		return &v1.LabelSelector{}
+	}
+	return &v1.LabelSelector{
+		MatchLabels: map[string]string{
+			admissionWebhookLabel: "enabled", // for now till there is more confidence, ensures only prowjobs with this label are affected
+		},
+	}
+}
+
+// validatingNamespaceSelector returns the selector of the namespaces whose
+// ProwJobs are sent to the validating webhook, matching the included namespaces
+// but not the excluded ones. All namespaces are matched if neither is set.
+func validatingNamespaceSelector(included, excluded []string) *v1.LabelSelector {
+	if len(included) == 0 && len(excluded) == 0 {
+		return nil
+	}
+	selector := &v1.LabelSelector{}
+	if len(included) > 0 {
+		selector.MatchExpressions = append(selector.MatchExpressions, v1.LabelSelectorRequirement{
+			Key:      corev1.LabelMetadataName,
+			Operator: v1.LabelSelectorOpIn,
+			Values:   sets.List(sets.New[string](included...)),
+		})
+	}
+	if len(excluded) > 0 {
+		selector.MatchExpressions = append(selector.MatchExpressions, v1.LabelSelectorRequirement{
+			Key:      corev1.LabelMetadataName,
+			Operator: v1.LabelSelectorOpNotIn,
+			Values:   sets.List(sets.New[string](excluded...)),
+		})
+	}
+	return selector
+}
+
+func ensureValidatingWebhookConfig(ctx context.Context, caPem string, operations []admregistration.OperationType, unlabeledProwJobs unlabeledProwJobsMode, namespaceSelector *v1.LabelSelector, client ctrlruntimeclient.Client) error {
+	validatingWebhookConfig := newValidatingWebhookConfig(caPem, operations, unlabeledProwJobs, namespaceSelector)
+
 	createOptions := &ctrlruntimeclient.CreateOptions{
 		FieldManager: "webhook-server", // indicates the configuration was created by the webhook server
 	}
@@ -253,7 +485,7 @@ func ensureValidatingWebhookConfig(ctx context.Context, caPem string, client ctr
 	err := client.Create(ctx, validatingWebhookConfig, createOptions)
 	if err != nil && strings.Contains(err.Error(), configAlreadyExistsError) {
 		logrus.Info("ValidatingWebhookConfiguration already exists, proceeding to patch")
-		if err := patchValidatingWebhookConfig(ctx, caPem, client); err != nil {
+		if err := patchValidatingWebhookConfig(ctx, caPem, operations, unlabeledProwJobs, namespaceSelector, client); err != nil {
 			return fmt.Errorf("failed to patch validating webhook config: %w", err)
 		}
 	} else if err != nil {
@@ -263,7 +495,7 @@ func ensureValidatingWebhookConfig(ctx context.Context, caPem string, client ctr
 	return nil
 }
 
-func patchValidatingWebhookConfig(ctx context.Context, caPem string, client ctrlruntimeclient.Client) error {
+func patchValidatingWebhookConfig(ctx context.Context, caPem string, operations []admregistration.OperationType, unlabeledProwJobs unlabeledProwJobsMode, namespaceSelector *v1.LabelSelector, client ctrlruntimeclient.Client) error {
 	key := types.NamespacedName{
 		Namespace: defaultNamespace,
 		Name:      prowJobValidatingWebhookName,
@@ -278,19 +510,26 @@ func patchValidatingWebhookConfig(ctx context.Context, caPem string, client ctrl
 	}
 	oldValidatingWebhook := validatingWebhookConfig.DeepCopy()
 	validatingWebhookConfig.Webhooks[0].ClientConfig.CABundle = []byte(caPem)
+	// Webhooks registered before the per-resource paths were served on the shared path.
+	path := resourcePath(validatePath, prowJobsResource)
+	validatingWebhookConfig.Webhooks[0].ClientConfig.Service.Path = &path
+	validatingWebhookConfig.Webhooks[0].Rules[0].Operations = operations
+	validatingWebhookConfig.Webhooks[0].ObjectSelector = validatingObjectSelector(unlabeledProwJobs)
+	validatingWebhookConfig.Webhooks[0].NamespaceSelector = namespaceSelector
 	if err := client.Patch(ctx, &validatingWebhookConfig, ctrlruntimeclient.MergeFrom(oldValidatingWebhook), patchOptions); err != nil {
 		return fmt.Errorf("failed to patch validating webhook config: %w", err)
 	}
 	return nil
 }
 
-func ensureMutatingWebhookConfig(ctx context.Context, caPem string, client ctrlruntimeclient.Client) error {
+// newMutatingWebhookConfig generates the MutatingWebhookConfiguration for the prowjob mutating webhook.
+func newMutatingWebhookConfig(caPem string, reinvocationPolicy admregistration.ReinvocationPolicyType) *admregistration.MutatingWebhookConfiguration {
 	operations := []admregistration.OperationType{"CREATE"}
 	scope := admregistration.ScopeType("*")
-	path := mutatePath
+	path := resourcePath(mutatePath, prowJobsResource)
 	sideEffects := admregistration.SideEffectClass("None")
 
-	mutatingWebhookConfig := &admregistration.MutatingWebhookConfiguration{
+	return &admregistration.MutatingWebhookConfiguration{
 		TypeMeta: v1.TypeMeta{
 			Kind:       "MutatingWebhookConfiguration",
 			APIVersion: "admissionregistration.k8s.io/v1",
@@ -303,8 +542,8 @@ func ensureMutatingWebhookConfig(ctx context.Context, caPem string, client ctrlr
 				Name: prowJobMutatingWebhookName,
 				ObjectSelector: &v1.LabelSelector{
 					MatchLabels: map[string]string{
-						"admission-webhook": "enabled", //for now till there is more confidence, ensures only prowjobs with this label are affected
-						"default-me":        "enabled", //for now till there is more confidence, ensures only prowjobs with this label are affected
+						admissionWebhookLabel: "enabled", //for now till there is more confidence, ensures only prowjobs with this label are affected
+						"default-me":          "enabled", //for now till there is more confidence, ensures only prowjobs with this label are affected
 					},
 				},
 				Rules: []admregistration.RuleWithOperations{
@@ -313,7 +552,7 @@ func ensureMutatingWebhookConfig(ctx context.Context, caPem string, client ctrlr
 						Rule: admregistration.Rule{
 							APIGroups:   []string{"prow.k8s.io"},
 							APIVersions: []string{"v1"},
-							Resources:   []string{"prowjobs"},
+							Resources:   []string{prowJobsResource},
 							Scope:       &scope,
 						},
 					},
@@ -328,9 +567,14 @@ func ensureMutatingWebhookConfig(ctx context.Context, caPem string, client ctrlr
 				},
 				SideEffects:             &sideEffects,
 				AdmissionReviewVersions: []string{"v1"},
+				ReinvocationPolicy:      &reinvocationPolicy,
 			},
 		},
 	}
+}
+
+func ensureMutatingWebhookConfig(ctx context.Context, caPem string, reinvocationPolicy admregistration.ReinvocationPolicyType, client ctrlruntimeclient.Client) error {
+	mutatingWebhookConfig := newMutatingWebhookConfig(caPem, reinvocationPolicy)
 
 	createOptions := &ctrlruntimeclient.CreateOptions{
 		FieldManager: "webhook-server",
@@ -339,7 +583,7 @@ func ensureMutatingWebhookConfig(ctx context.Context, caPem string, client ctrlr
 	err := client.Create(ctx, mutatingWebhookConfig, createOptions)
 	if err != nil && strings.Contains(err.Error(), configAlreadyExistsError) {
 		logrus.Info("MutatingWebhookConfiguration already exists, proceeding to patch")
-		if err := patchMutatingWebhookConfig(ctx, caPem, client); err != nil {
+		if err := patchMutatingWebhookConfig(ctx, caPem, reinvocationPolicy, client); err != nil {
 			return fmt.Errorf("failed to patch mutating webhook config: %w", err)
 		}
 	} else if err != nil {
@@ -349,7 +593,7 @@ func ensureMutatingWebhookConfig(ctx context.Context, caPem string, client ctrlr
 	return nil
 }
 
-func patchMutatingWebhookConfig(ctx context.Context, caPem string, client ctrlruntimeclient.Client) error {
+func patchMutatingWebhookConfig(ctx context.Context, caPem string, reinvocationPolicy admregistration.ReinvocationPolicyType, client ctrlruntimeclient.Client) error {
 	key := types.NamespacedName{
 		Namespace: defaultNamespace,
 		Name:      prowJobMutatingWebhookName,
@@ -364,6 +608,9 @@ func patchMutatingWebhookConfig(ctx context.Context, caPem string, client ctrlru
 	}
 	oldMutatingWebhook := mutatingWebhookConfig.DeepCopy()
 	mutatingWebhookConfig.Webhooks[0].ClientConfig.CABundle = []byte(caPem)
+	path := resourcePath(mutatePath, prowJobsResource)
+	mutatingWebhookConfig.Webhooks[0].ClientConfig.Service.Path = &path
+	mutatingWebhookConfig.Webhooks[0].ReinvocationPolicy = &reinvocationPolicy
 	if err := client.Patch(ctx, &mutatingWebhookConfig, ctrlruntimeclient.MergeFrom(oldMutatingWebhook), patchOptions); err != nil {
 		return fmt.Errorf("failed to patch mutating webhook config: %w", err)
 	}
@@ -409,23 +656,31 @@ func checkWebhooksExist(ctx context.Context, client ctrlruntimeclient.Client) (s
 	return "", "", false, nil
 }
 
-func reconcileWebhooks(ctx context.Context, caPem string, cl ctrlruntimeclient.Client) error {
+func reconcileWebhooks(ctx context.Context, caPem string, clientoptions clientOptions, cl ctrlruntimeclient.Client) error {
 	mutatingCAPem, validatingCAPem, exist, err := checkWebhooksExist(ctx, cl)
 	if err != nil {
 		return err
 	}
-	if exist && (validatingCAPem != caPem || mutatingCAPem != caPem) {
-		if err := patchValidatingWebhookConfig(ctx, caPem, cl); err != nil {
+	// Keep trusting the previous CA for a while after a rotation.
+	caPem, err = caBundle(caPem, validatingCAPem, clientoptions.caOverlap, time.Now())
+	if err != nil {
+		return err
+	}
+	if exist {
+		// Besides the certificates, the reinvocation policy or the validating
+		// rules may have changed since the webhooks were created. The patches
+		// are no-ops if nothing changed.
+		if err := patchValidatingWebhookConfig(ctx, caPem, clientoptions.validatingOperations, clientoptions.unlabeledProwJobs, clientoptions.namespaceSelector, cl); err != nil {
 			return fmt.Errorf("unable to patch ValidatingWebhookConfig %v", err)
 		}
-		if err := patchMutatingWebhookConfig(ctx, caPem, cl); err != nil {
+		if err := patchMutatingWebhookConfig(ctx, caPem, clientoptions.reinvocationPolicy, cl); err != nil {
 			return fmt.Errorf("unable to patch MutatingWebhookConfig %v", err)
 		}
-	} else if !exist {
-		if err = ensureValidatingWebhookConfig(ctx, caPem, cl); err != nil {
+	} else {
+		if err = ensureValidatingWebhookConfig(ctx, caPem, clientoptions.validatingOperations, clientoptions.unlabeledProwJobs, clientoptions.namespaceSelector, cl); err != nil {
 			return fmt.Errorf("unable to generate ValidatingWebhookConfig %v", err)
 		}
-		if err = ensureMutatingWebhookConfig(ctx, caPem, cl); err != nil {
+		if err = ensureMutatingWebhookConfig(ctx, caPem, clientoptions.reinvocationPolicy, cl); err != nil {
 			return fmt.Errorf("unable to generate MutatingWebhookConfig %v", err)
 		}
 	}
@@ -18,11 +18,19 @@ package main
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	admregistration "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/prow/prow/flagutil"
 )
 
@@ -30,31 +38,31 @@ func TestCreateSecret(t *testing.T) {
 	tests := []struct {
 		name     string
 		dnsNames []string
-		expiry   int
+		expiry   time.Duration
 		want     [3]string
 		wantErr  bool
 	}{
 		{
 			name:     "base",
 			dnsNames: []string{"bar"},
-			expiry:   10,
+			expiry:   10 * time.Hour,
 			want:     [3]string{"bar10a", "bar10b", "bar10c"},
 		},
 		{
 			name:     "noDnsNames",
 			dnsNames: []string{},
-			expiry:   10,
+			expiry:   10 * time.Hour,
 			want:     [3]string{"", "", ""},
 			wantErr:  true,
 		},
 	}
 
 	oldGenCertFunc := genCertFunc
-	genCertFunc = func(expiry int, dnsNames []string) (string, string, string, error) {
+	genCertFunc = func(expiry time.Duration, signatureAlgorithm x509.SignatureAlgorithm, dnsNames []string) (string, string, string, error) {
 		if len(dnsNames) == 0 {
 			return "", "", "", errors.New("dnsNames was not configured")
 		}
-		baseName := dnsNames[0] + strconv.Itoa(expiry)
+		baseName := dnsNames[0] + strconv.Itoa(int(expiry.Hours()))
 		return fmt.Sprintf("%s%s", baseName, "a"), fmt.Sprintf("%s%s", baseName, "b"), fmt.Sprintf("%s%s", baseName, "c"), nil
 	}
 	t.Cleanup(func() {
@@ -67,9 +75,9 @@ func TestCreateSecret(t *testing.T) {
 			// t.Parallel()
 			ctx := context.Background()
 			clientoptions := clientOptions{
-				secretID:      secretID,
-				dnsNames:      flagutil.NewStrings(tc.dnsNames...),
-				expiryInYears: tc.expiry,
+				secretID: secretID,
+				dnsNames: flagutil.NewStrings(tc.dnsNames...),
+				expiry:   tc.expiry,
 			}
 			f := newFakeClient()
 
@@ -96,7 +104,7 @@ func TestUpdateSecret(t *testing.T) {
 	tests := []struct {
 		name     string
 		dnsNames []string
-		expiry   int
+		expiry   time.Duration
 		want     [3]string
 		wantCert string
 		wantErr  bool
@@ -104,24 +112,24 @@ func TestUpdateSecret(t *testing.T) {
 		{
 			name:     "base",
 			dnsNames: []string{"bar"},
-			expiry:   10,
+			expiry:   10 * time.Hour,
 			want:     [3]string{"bar10a", "bar10b", "bar10c"},
 			wantCert: "{\"caBundle.pem\":\"bar10c\",\"certFile.pem\":\"bar10a\",\"privKeyFile.pem\":\"bar10b\"}",
 		},
 		{
 			name:     "noDnsNames",
 			dnsNames: []string{},
-			expiry:   10,
+			expiry:   10 * time.Hour,
 			wantErr:  true,
 		},
 	}
 
 	oldGenCertFunc := genCertFunc
-	genCertFunc = func(expiry int, dnsNames []string) (string, string, string, error) {
+	genCertFunc = func(expiry time.Duration, signatureAlgorithm x509.SignatureAlgorithm, dnsNames []string) (string, string, string, error) {
 		if len(dnsNames) == 0 {
 			return "", "", "", errors.New("dnsNames was not configured")
 		}
-		baseName := dnsNames[0] + strconv.Itoa(expiry)
+		baseName := dnsNames[0] + strconv.Itoa(int(expiry.Hours()))
 		return fmt.Sprintf("%s%s", baseName, "a"), fmt.Sprintf("%s%s", baseName, "b"), fmt.Sprintf("%s%s", baseName, "c"), nil
 	}
 	t.Cleanup(func() {
@@ -138,9 +146,9 @@ func TestUpdateSecret(t *testing.T) {
 
 			ctx := context.Background()
 			clientoptions := clientOptions{
-				secretID:      secretID,
-				dnsNames:      flagutil.NewStrings(tc.dnsNames...),
-				expiryInYears: tc.expiry,
+				secretID: secretID,
+				dnsNames: flagutil.NewStrings(tc.dnsNames...),
+				expiry:   tc.expiry,
 			}
 
 			gotCert, gotPrivKey, gotCaPem, gotErr := updateSecret(f, ctx, clientoptions)
@@ -163,3 +171,353 @@ func TestUpdateSecret(t *testing.T) {
 	}
 
 }
+
+func TestValidateDNSNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		dnsNames []string
+		wantErr  bool
+	}{
+		{
+			name:     "service name",
+			dnsNames: []string{"prowjob-admission-webhook.default.svc"},
+		},
+		{
+			name:     "fully qualified service name",
+			dnsNames: []string{"foo", "prowjob-admission-webhook.default.svc.cluster.local"},
+		},
+		{
+			name:     "wrong namespace",
+			dnsNames: []string{"prowjob-admission-webhook.prow.svc"},
+			wantErr:  true,
+		},
+		{
+			name:    "no dns names",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateDNSNames(tc.dnsNames, defaultNamespace, prowjobAdmissionServiceName)
+			if tc.wantErr && err == nil {
+				t.Fatalf("Want error, but got nil")
+			} else if !tc.wantErr && err != nil {
+				t.Fatalf("Want no error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestGenCertExpiry(t *testing.T) {
+	expiry := 90 * 24 * time.Hour
+	start := time.Now()
+	serverCert, _, caPem, err := genCert(expiry, x509.SHA256WithRSA, []string{"prowjob-admission-webhook.default.svc"})
+	if err != nil {
+		t.Fatalf("Want no error, got: %v", err)
+	}
+	for name, cert := range map[string]string{"server": serverCert, "ca": caPem} {
+		block, _ := pem.Decode([]byte(cert))
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatalf("could not parse %s cert: %v", name, err)
+		}
+		// x509 truncates times to the second.
+		if notAfter := start.Add(expiry).Truncate(time.Second); certificate.NotAfter.Before(notAfter) || certificate.NotAfter.After(notAfter.Add(time.Minute)) {
+			t.Errorf("%s cert expires at %v, want about %v", name, certificate.NotAfter, notAfter)
+		}
+	}
+}
+
+func TestGenCertSignatureAlgorithm(t *testing.T) {
+	for _, name := range []string{"SHA256-RSA", "SHA384-RSA"} {
+		t.Run(name, func(t *testing.T) {
+			signatureAlgorithm, err := parseSignatureAlgorithm(name)
+			if err != nil {
+				t.Fatalf("Want no error, got: %v", err)
+			}
+			serverCert, _, caPem, err := genCert(time.Hour, signatureAlgorithm, []string{"prowjob-admission-webhook.default.svc"})
+			if err != nil {
+				t.Fatalf("Want no error, got: %v", err)
+			}
+			for certName, cert := range map[string]string{"server": serverCert, "ca": caPem} {
+				block, _ := pem.Decode([]byte(cert))
+				certificate, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					t.Fatalf("could not parse %s cert: %v", certName, err)
+				}
+				if certificate.SignatureAlgorithm != signatureAlgorithm {
+					t.Errorf("%s cert signed with %v, want %v", certName, certificate.SignatureAlgorithm, signatureAlgorithm)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSignatureAlgorithm(t *testing.T) {
+	if _, err := parseSignatureAlgorithm("MD5-RSA"); err == nil {
+		t.Error("Expected an error for an unsupported signature algorithm")
+	}
+}
+
+func TestParseReinvocationPolicy(t *testing.T) {
+	if _, err := parseReinvocationPolicy("Always"); err == nil {
+		t.Error("Expected an error for an unsupported reinvocation policy")
+	}
+}
+
+func TestNewMutatingWebhookConfigReinvocationPolicy(t *testing.T) {
+	for _, name := range []string{"Never", "IfNeeded"} {
+		t.Run(name, func(t *testing.T) {
+			policy, err := parseReinvocationPolicy(name)
+			if err != nil {
+				t.Fatalf("Failed to parse reinvocation policy: %v", err)
+			}
+			config := newMutatingWebhookConfig("ca", policy)
+			got := config.Webhooks[0].ReinvocationPolicy
+			if got == nil || *got != admregistration.ReinvocationPolicyType(name) {
+				t.Errorf("Expected reinvocation policy %s, got %v", name, got)
+			}
+		})
+	}
+}
+
+func TestParseValidatingOperations(t *testing.T) {
+	if _, err := parseValidatingOperations(nil); err == nil {
+		t.Error("Expected an error for no operations")
+	}
+	if _, err := parseValidatingOperations([]string{"CREATE", "CONNECT"}); err == nil {
+		t.Error("Expected an error for an unsupported operation")
+	}
+}
+
+func TestNewValidatingWebhookConfigOperations(t *testing.T) {
+	testCases := []struct {
+		name     string
+		names    []string
+		expected []admregistration.OperationType
+	}{
+		{
+			name:     "default",
+			names:    []string{"CREATE", "UPDATE"},
+			expected: []admregistration.OperationType{admregistration.Create, admregistration.Update},
+		},
+		{
+			name:     "delete",
+			names:    []string{"CREATE", "UPDATE", "DELETE"},
+			expected: []admregistration.OperationType{admregistration.Create, admregistration.Update, admregistration.Delete},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			operations, err := parseValidatingOperations(tc.names)
+			if err != nil {
+				t.Fatalf("Failed to parse validating operations: %v", err)
+			}
+			config := newValidatingWebhookConfig("ca", operations, unlabeledProwJobsIgnore, nil)
+			if got := config.Webhooks[0].Rules[0].Operations; !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Expected operations %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidatingObjectSelector(t *testing.T) {
+	testCases := []struct {
+		mode           unlabeledProwJobsMode
+		matchUnlabeled bool
+	}{
+		{mode: unlabeledProwJobsIgnore},
+		{mode: unlabeledProwJobsWarn, matchUnlabeled: true},
+		{mode: unlabeledProwJobsValidate, matchUnlabeled: true},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			config := newValidatingWebhookConfig("ca", []admregistration.OperationType{admregistration.Create}, tc.mode, nil)
+			selector, err := v1.LabelSelectorAsSelector(config.Webhooks[0].ObjectSelector)
+			if err != nil {
+				t.Fatalf("Invalid object selector: %v", err)
+			}
+			if !selector.Matches(labels.Set{admissionWebhookLabel: "enabled"}) {
+				t.Error("Expected labeled prowjobs to be matched")
+			}
+			if matched := selector.Matches(labels.Set{}); matched != tc.matchUnlabeled {
+				t.Errorf("Expected unlabeled prowjobs to be matched to be %t, got %t", tc.matchUnlabeled, matched)
+			}
+		})
+	}
+	if _, err := parseUnlabeledProwJobsMode("deny"); err == nil {
+		t.Error("Expected an error for an unsupported unlabeled prowjobs mode")
+	}
+}
+
+func TestValidatingNamespaceSelector(t *testing.T) {
+	testCases := []struct {
+		name      string
+		included  []string
+		excluded  []string
+		expected  *v1.LabelSelector
+		matched   []string
+		unmatched []string
+	}{
+		{
+			name:    "all namespaces by default",
+			matched: []string{"default", "test-pods"},
+		},
+		{
+			name:     "only included namespaces",
+			included: []string{"test-pods", "default", "test-pods"},
+			expected: &v1.LabelSelector{
+				MatchExpressions: []v1.LabelSelectorRequirement{
+					{Key: "kubernetes.io/metadata.name", Operator: v1.LabelSelectorOpIn, Values: []string{"default", "test-pods"}},
+				},
+			},
+			matched:   []string{"default", "test-pods"},
+			unmatched: []string{"other"},
+		},
+		{
+			name:     "all but excluded namespaces",
+			excluded: []string{"kube-system"},
+			expected: &v1.LabelSelector{
+				MatchExpressions: []v1.LabelSelectorRequirement{
+					{Key: "kubernetes.io/metadata.name", Operator: v1.LabelSelectorOpNotIn, Values: []string{"kube-system"}},
+				},
+			},
+			matched:   []string{"default"},
+			unmatched: []string{"kube-system"},
+		},
+		{
+			name:     "included and excluded namespaces",
+			included: []string{"default", "test-pods"},
+			excluded: []string{"test-pods"},
+			expected: &v1.LabelSelector{
+				MatchExpressions: []v1.LabelSelectorRequirement{
+					{Key: "kubernetes.io/metadata.name", Operator: v1.LabelSelectorOpIn, Values: []string{"default", "test-pods"}},
+					{Key: "kubernetes.io/metadata.name", Operator: v1.LabelSelectorOpNotIn, Values: []string{"test-pods"}},
+				},
+			},
+			matched:   []string{"default"},
+			unmatched: []string{"test-pods", "other"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := validatingNamespaceSelector(tc.included, tc.excluded)
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("Expected namespace selector %v, got %v", tc.expected, got)
+			}
+			config := newValidatingWebhookConfig("ca", []admregistration.OperationType{admregistration.Create}, unlabeledProwJobsIgnore, got)
+			// A nil selector matches all namespaces.
+			selector := labels.Everything()
+			if config.Webhooks[0].NamespaceSelector != nil {
+				var err error
+				if selector, err = v1.LabelSelectorAsSelector(config.Webhooks[0].NamespaceSelector); err != nil {
+					t.Fatalf("Invalid namespace selector: %v", err)
+				}
+			}
+			for _, ns := range tc.matched {
+				if !selector.Matches(labels.Set{"kubernetes.io/metadata.name": ns}) {
+					t.Errorf("Expected namespace %q to be matched", ns)
+				}
+			}
+			for _, ns := range tc.unmatched {
+				if selector.Matches(labels.Set{"kubernetes.io/metadata.name": ns}) {
+					t.Errorf("Expected namespace %q not to be matched", ns)
+				}
+			}
+		})
+	}
+}
+
+func TestCABundle(t *testing.T) {
+	_, _, oldCA, err := genCert(time.Hour, x509.SHA256WithRSA, []string{"prowjob-admission-webhook.default.svc"})
+	if err != nil {
+		t.Fatalf("Failed to generate the old CA: %v", err)
+	}
+	_, _, newCA, err := genCert(24*time.Hour, x509.SHA256WithRSA, []string{"prowjob-admission-webhook.default.svc"})
+	if err != nil {
+		t.Fatalf("Failed to generate the new CA: %v", err)
+	}
+	now := time.Now()
+
+	testCases := []struct {
+		name     string
+		previous string
+		overlap  time.Duration
+		now      time.Time
+		expected []string
+	}{
+		{
+			name:     "both CAs are present during the overlap",
+			previous: oldCA,
+			overlap:  10 * time.Minute,
+			now:      now,
+			expected: []string{newCA, oldCA},
+		},
+		{
+			name:     "previous CA is dropped after the overlap",
+			previous: oldCA,
+			overlap:  10 * time.Minute,
+			now:      now.Add(20 * time.Minute),
+			expected: []string{newCA},
+		},
+		{
+			name:     "previous CA is dropped without overlap",
+			previous: oldCA,
+			now:      now,
+			expected: []string{newCA},
+		},
+		{
+			name:     "expired previous CA is dropped during the overlap",
+			previous: oldCA,
+			overlap:  3 * time.Hour,
+			now:      now.Add(2 * time.Hour),
+			expected: []string{newCA},
+		},
+		{
+			name:     "CAs are not duplicated",
+			previous: newCA + oldCA,
+			overlap:  10 * time.Minute,
+			now:      now,
+			expected: []string{newCA, oldCA},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bundle, err := caBundle(newCA, tc.previous, tc.overlap, tc.now)
+			if err != nil {
+				t.Fatalf("Failed to build the CA bundle: %v", err)
+			}
+			if expected := strings.Join(tc.expected, ""); bundle != expected {
+				t.Errorf("Expected the bundle to contain %d CAs, got:\n%s", len(tc.expected), bundle)
+			}
+		})
+	}
+}
+
+func TestWebhookConfigPaths(t *testing.T) {
+	validating := newValidatingWebhookConfig("ca", []admregistration.OperationType{admregistration.Create}, unlabeledProwJobsIgnore, nil)
+	for _, webhook := range validating.Webhooks {
+		for _, rule := range webhook.Rules {
+			for _, resource := range rule.Resources {
+				if expected, got := "/validate-"+resource, webhook.ClientConfig.Service.Path; got == nil || *got != expected {
+					t.Errorf("Expected validating webhook %s to serve %s on %s, got %v", webhook.Name, resource, expected, got)
+				}
+			}
+		}
+	}
+	mutating := newMutatingWebhookConfig("ca", admregistration.NeverReinvocationPolicy)
+	for _, webhook := range mutating.Webhooks {
+		for _, rule := range webhook.Rules {
+			for _, resource := range rule.Resources {
+				if expected, got := "/mutate-"+resource, webhook.ClientConfig.Service.Path; got == nil || *got != expected {
+					t.Errorf("Expected mutating webhook %s to serve %s on %s, got %v", webhook.Name, resource, expected, got)
+				}
+			}
+		}
+	}
+	if path := resourcePath(validatePath, prowJobsResource); path != "/validate-prowjobs" {
+		t.Errorf("Expected ProwJobs to be validated on /validate-prowjobs, got %s", path)
+	}
+}
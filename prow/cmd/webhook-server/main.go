@@ -19,6 +19,7 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -29,6 +30,9 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+	admregistration "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/prow/pkg/flagutil"
 	"sigs.k8s.io/prow/prow/cmd/webhook-server/secretmanager"
 	"sigs.k8s.io/prow/prow/config"
@@ -36,6 +40,7 @@ import (
 	configflagutil "sigs.k8s.io/prow/prow/flagutil/config"
 	"sigs.k8s.io/prow/prow/interrupts"
 	"sigs.k8s.io/prow/prow/logrusutil"
+	"sigs.k8s.io/prow/prow/metrics"
 	"sigs.k8s.io/prow/prow/pjutil"
 	"sigs.k8s.io/prow/prow/plank"
 	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -55,29 +60,52 @@ type ClientInterface interface {
 }
 
 type options struct {
-	kubernetes     prowflagutil.KubernetesOptions
-	secretID       string
-	projectId      string
-	expiryInYears  int
-	dnsNames       prowflagutil.Strings
-	fileSystemPath string
-	config         configflagutil.ConfigOptions
-	storage        prowflagutil.StorageClientOptions
-	time           int
-	dryRun         bool
+	kubernetes               prowflagutil.KubernetesOptions
+	secretID                 string
+	projectId                string
+	expiryInYears            int
+	expiry                   time.Duration
+	sigAlgName               string
+	sigAlg                   x509.SignatureAlgorithm
+	reinvocationPolicyName   string
+	reinvocationPolicy       admregistration.ReinvocationPolicyType
+	validatingOperationNames prowflagutil.Strings
+	validatingOperations     []admregistration.OperationType
+	unlabeledProwJobsName    string
+	unlabeledProwJobs        unlabeledProwJobsMode
+	validatingNamespaces     prowflagutil.Strings
+	excludedNamespaces       prowflagutil.Strings
+	mutationNames            prowflagutil.Strings
+	mutations                sets.Set[mutation]
+	caOverlap                time.Duration
+	dnsNames                 prowflagutil.Strings
+	fileSystemPath           string
+	config                   configflagutil.ConfigOptions
+	storage                  prowflagutil.StorageClientOptions
+	instrumentationOptions   prowflagutil.InstrumentationOptions
+	time                     int
+	dryRun                   bool
 }
 
 type clientOptions struct {
-	secretID      string
-	expiryInYears int
-	dnsNames      prowflagutil.Strings
+	secretID             string
+	expiry               time.Duration
+	signatureAlgorithm   x509.SignatureAlgorithm
+	dnsNames             prowflagutil.Strings
+	reinvocationPolicy   admregistration.ReinvocationPolicyType
+	validatingOperations []admregistration.OperationType
+	unlabeledProwJobs    unlabeledProwJobsMode
+	namespaceSelector    *v1.LabelSelector
+	caOverlap            time.Duration
 }
 
 type webhookAgent struct {
-	storage  prowflagutil.StorageClientOptions
-	statuses map[string]plank.ClusterStatus
-	mu       sync.Mutex
-	plank    config.Plank
+	storage           prowflagutil.StorageClientOptions
+	statuses          map[string]plank.ClusterStatus
+	mu                sync.Mutex
+	plank             config.Plank
+	unlabeledProwJobs unlabeledProwJobsMode
+	mutations         sets.Set[mutation]
 }
 
 func (o *options) DefaultAndValidate() error {
@@ -88,6 +116,43 @@ func (o *options) DefaultAndValidate() error {
 	if o.expiryInYears < 0 {
 		return fmt.Errorf("invalid expiry years")
 	}
+	if o.expiry < 0 {
+		return fmt.Errorf("invalid expiry")
+	}
+	if o.expiry == 0 {
+		o.expiry = yearsToDuration(o.expiryInYears)
+	}
+	if o.caOverlap < 0 {
+		return fmt.Errorf("invalid ca overlap")
+	}
+	sigAlg, err := parseSignatureAlgorithm(o.sigAlgName)
+	if err != nil {
+		return err
+	}
+	o.sigAlg = sigAlg
+	reinvocationPolicy, err := parseReinvocationPolicy(o.reinvocationPolicyName)
+	if err != nil {
+		return err
+	}
+	o.reinvocationPolicy = reinvocationPolicy
+	validatingOperations, err := parseValidatingOperations(o.validatingOperationNames.Strings())
+	if err != nil {
+		return err
+	}
+	o.validatingOperations = validatingOperations
+	unlabeledProwJobs, err := parseUnlabeledProwJobsMode(o.unlabeledProwJobsName)
+	if err != nil {
+		return err
+	}
+	o.unlabeledProwJobs = unlabeledProwJobs
+	if overlap := o.validatingNamespaces.StringSet().Intersection(o.excludedNamespaces.StringSet()); overlap.Len() > 0 {
+		return fmt.Errorf("namespaces are both validated and excluded: %v", sets.List(overlap))
+	}
+	mutations, err := parseMutations(o.mutationNames.Strings())
+	if err != nil {
+		return err
+	}
+	o.mutations = mutations
 	if o.projectId == "" && o.fileSystemPath == "" {
 		return fmt.Errorf("both projectid and filesystem path cannot be specified")
 	}
@@ -98,21 +163,36 @@ func (o *options) DefaultAndValidate() error {
 		return fmt.Errorf("secretID must be specified if choosing to use a GCP project")
 	}
 	if o.dnsNames.StringSet().Len() == 0 {
-		o.dnsNames.Add(prowjobAdmissionServiceName + ".default.svc")
+		o.dnsNames.Add(serviceDNSName(defaultNamespace, prowjobAdmissionServiceName))
+	}
+	if err := validateDNSNames(o.dnsNames.Strings(), defaultNamespace, prowjobAdmissionServiceName); err != nil {
+		return err
 	}
 	return nil
 }
 
 func gatherOptions(fs *flag.FlagSet, args ...string) options {
-	var o options
+	o := options{
+		validatingOperationNames: prowflagutil.NewStrings(string(admregistration.Create), string(admregistration.Update)),
+		mutationNames:            prowflagutil.NewStrings(string(mutationDecoration)),
+	}
 	fs.StringVar(&o.projectId, "project-id", "", "Project ID for storing GCP Secrets")
 	fs.StringVar(&o.fileSystemPath, "filesys-path", "./prowjob-webhook-ca-cert", "File system path for storing ca-cert secrets")
 	fs.StringVar(&o.secretID, "secret-id", "", "GCP Project secret name")
 	fs.IntVar(&o.expiryInYears, "expiry-years", 30, "CA certificate expiry in years")
+	fs.DurationVar(&o.expiry, "expiry", 0, "CA certificate expiry as a duration, e.g. 2160h for 90 days. Overrides --expiry-years if set")
+	fs.StringVar(&o.sigAlgName, "signature-algorithm", x509.SHA256WithRSA.String(), "Algorithm used to sign the CA and server certificates, one of SHA256-RSA, SHA384-RSA or SHA512-RSA")
+	fs.StringVar(&o.reinvocationPolicyName, "reinvocation-policy", string(admregistration.NeverReinvocationPolicy), "Reinvocation policy of the mutating webhook, one of Never or IfNeeded")
+	fs.Var(&o.validatingOperationNames, "validating-operation", "Operation on prowjobs the validating webhook is registered for, one of CREATE, UPDATE or DELETE. Can be passed multiple times. Defaults to CREATE and UPDATE. Validating DELETE rejects deleting prowjobs that are not complete.")
+	fs.StringVar(&o.unlabeledProwJobsName, "unlabeled-prowjobs", string(unlabeledProwJobsIgnore), "How the validating webhook handles prowjobs without the admission-webhook: enabled label, one of ignore (not sent to the webhook), warn (logged and counted, but admitted) or validate")
+	fs.Var(&o.validatingNamespaces, "validating-namespace", "Namespace whose prowjobs the validating webhook validates. Can be passed multiple times. Defaults to all namespaces")
+	fs.Var(&o.excludedNamespaces, "excluded-validating-namespace", "Namespace whose prowjobs the validating webhook doesn't validate. Can be passed multiple times")
+	fs.Var(&o.mutationNames, "mutation", "Default the mutating webhook applies to created prowjobs, one of decoration or cluster. Can be passed multiple times, pass an empty value to disable all mutations. Defaults to decoration")
+	fs.DurationVar(&o.caOverlap, "ca-overlap", 0, "How long the webhooks keep trusting the previous CA in addition to the new one after the certificates are rotated, e.g. 1h. The previous CA is dropped on the first start after the overlap, or immediately if unset")
 	fs.BoolVar(&o.dryRun, "dry-run", true, "Whether to mutate any real-world state")
 	fs.IntVar(&o.time, "time", 1, "duration in minutes to fetch build clusters")
 	fs.Var(&o.dnsNames, "dns", "DNS Names CA-Cert config")
-	optionGroups := []flagutil.OptionGroup{&o.kubernetes, &o.config}
+	optionGroups := []flagutil.OptionGroup{&o.kubernetes, &o.config, &o.instrumentationOptions}
 	for _, optionGroup := range optionGroups {
 		optionGroup.AddFlags(fs)
 	}
@@ -143,9 +223,15 @@ func main() {
 	var client ClientInterface
 	statuses := make(map[string]plank.ClusterStatus)
 	clientoptions := &clientOptions{
-		secretID:      o.secretID,
-		dnsNames:      o.dnsNames,
-		expiryInYears: o.expiryInYears,
+		secretID:             o.secretID,
+		dnsNames:             o.dnsNames,
+		expiry:               o.expiry,
+		signatureAlgorithm:   o.sigAlg,
+		reinvocationPolicy:   o.reinvocationPolicy,
+		validatingOperations: o.validatingOperations,
+		unlabeledProwJobs:    o.unlabeledProwJobs,
+		namespaceSelector:    validatingNamespaceSelector(o.validatingNamespaces.Strings(), o.excludedNamespaces.Strings()),
+		caOverlap:            o.caOverlap,
 	}
 	if o.projectId != "" {
 		secretManagerClient, err := secretmanager.NewClient(o.projectId, false)
@@ -162,7 +248,7 @@ func main() {
 		if err != nil {
 			logrus.WithError(err).Fatal("Unable to generate absolute file path")
 		}
-		client = NewLocalFSClient(absPath, o.expiryInYears, o.dnsNames.Strings())
+		client = NewLocalFSClient(absPath, o.expiry, o.sigAlg, o.dnsNames.Strings())
 	}
 	certFile, privKeyFile, err = handleSecrets(client, ctx, *clientoptions, cl)
 	if err != nil {
@@ -173,16 +259,33 @@ func main() {
 		logrus.WithError(err).Fatal("could not create config agent")
 	}
 	cfg := configAgent.Config()
+	metrics.ExposeMetrics("webhook-server", cfg.PushGateway, o.instrumentationOptions.MetricsPort)
 	wa := &webhookAgent{
-		storage:  o.storage,
-		statuses: statuses,
-		plank:    cfg.Plank,
+		storage:           o.storage,
+		statuses:          statuses,
+		plank:             cfg.Plank,
+		unlabeledProwJobs: o.unlabeledProwJobs,
+		mutations:         o.mutations,
 	}
 	interrupts.Run(func(ctx context.Context) {
 		wa.fetchClusters(time.Duration(o.time*int(time.Minute)), ctx, &wa.statuses, configAgent)
 	})
 
 	mux := http.NewServeMux()
+	validateHandlers := map[string]http.HandlerFunc{
+		prowJobsResource: wa.serveValidate,
+	}
+	mutateHandlers := map[string]http.HandlerFunc{
+		prowJobsResource: wa.serveMutate,
+	}
+	for resource, handler := range validateHandlers {
+		mux.HandleFunc(resourcePath(validatePath, resource), handler)
+	}
+	for resource, handler := range mutateHandlers {
+		mux.HandleFunc(resourcePath(mutatePath, resource), handler)
+	}
+	// The shared paths are kept for webhook configurations that haven't been
+	// patched to the per-resource paths yet.
 	mux.HandleFunc(validatePath, wa.serveValidate)
 	mux.HandleFunc(mutatePath, wa.serveMutate)
 	s := http.Server{
@@ -202,36 +305,11 @@ func main() {
 // get or creates the necessary ca secret files and returns the ca-cert file name, priv-key file name and tempDir name
 // for use by the http listenAndServe
 func handleSecrets(client ClientInterface, ctx context.Context, clientoptions clientOptions, cl ctrlruntimeclient.Client) (string, string, error) {
-	var cert string
-	var privKey string
-	var caPem string
-	secretsMap := make(map[string]string)
-	data, exist, err := client.GetSecretValue(ctx, clientoptions.secretID, "latest")
+	cert, privKey, caPem, err := getOrRotateSecret(client, ctx, clientoptions)
 	if err != nil {
 		return "", "", err
 	}
-	if !exist {
-		logrus.WithError(err).Info("Secret does not exist, now creating")
-		cert, privKey, caPem, err = createSecret(client, ctx, clientoptions)
-		if err != nil {
-			return "", "", fmt.Errorf("unable to create ca certificate %v", err)
-		}
-	} else {
-		err = json.Unmarshal(data, &secretsMap)
-		if err != nil {
-			return "", "", fmt.Errorf("error marshalling CA cert secret data: %v", err)
-		}
-		cert = secretsMap[certFile]
-		privKey = secretsMap[privKeyFile]
-		if err := isCertValid(cert); err != nil {
-			logrus.WithError(err).Info("Certificate is not valid, will replace.")
-			cert, privKey, caPem, err = updateSecret(client, ctx, clientoptions)
-			if err != nil {
-				return "", "", fmt.Errorf("unable to update secret %v", err)
-			}
-		}
-	}
-	if err = reconcileWebhooks(ctx, caPem, cl); err != nil {
+	if err = reconcileWebhooks(ctx, caPem, clientoptions, cl); err != nil {
 		return "", "", err
 	}
 	tempDir, err := os.MkdirTemp("", "cert")
@@ -248,3 +326,35 @@ func handleSecrets(client ClientInterface, ctx context.Context, clientoptions cl
 	}
 	return certFile, privKeyFile, nil
 }
+
+// getOrRotateSecret returns the cert, private key and CA of the secret, creating
+// the secret if it doesn't exist. The cert is regenerated if it expired or if
+// its SANs differ from the configured DNS names, e.g. after the webhook service
+// moved to another namespace.
+func getOrRotateSecret(client ClientInterface, ctx context.Context, clientoptions clientOptions) (string, string, string, error) {
+	data, exist, err := client.GetSecretValue(ctx, clientoptions.secretID, "latest")
+	if err != nil {
+		return "", "", "", err
+	}
+	if !exist {
+		logrus.WithError(err).Info("Secret does not exist, now creating")
+		cert, privKey, caPem, err := createSecret(client, ctx, clientoptions)
+		if err != nil {
+			return "", "", "", fmt.Errorf("unable to create ca certificate %v", err)
+		}
+		return cert, privKey, caPem, nil
+	}
+	secretsMap := make(map[string]string)
+	if err := json.Unmarshal(data, &secretsMap); err != nil {
+		return "", "", "", fmt.Errorf("error marshalling CA cert secret data: %v", err)
+	}
+	cert, privKey, caPem := secretsMap[certFile], secretsMap[privKeyFile], secretsMap[caBundleFile]
+	if err := isCertValid(cert, clientoptions.dnsNames.Strings()); err != nil {
+		logrus.WithError(err).Info("Certificate is not valid, will replace.")
+		cert, privKey, caPem, err = updateSecret(client, ctx, clientoptions)
+		if err != nil {
+			return "", "", "", fmt.Errorf("unable to update secret %v", err)
+		}
+	}
+	return cert, privKey, caPem, nil
+}
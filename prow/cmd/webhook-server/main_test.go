@@ -18,9 +18,15 @@ package main
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"reflect"
 	"testing"
+	"time"
+
+	"sigs.k8s.io/prow/prow/flagutil"
 )
 
 type secretStore struct {
@@ -103,3 +109,60 @@ func TestGetSecretValue(t *testing.T) {
 		t.Errorf("wrong secret obtained")
 	}
 }
+
+func TestGetOrRotateSecret(t *testing.T) {
+	oldNames := []string{"prowjob-admission-webhook.default.svc"}
+	testCases := []struct {
+		name        string
+		dnsNames    []string
+		regenerated bool
+	}{
+		{
+			name:     "same SANs",
+			dnsNames: oldNames,
+		},
+		{
+			name:        "service moved to another namespace",
+			dnsNames:    []string{"prowjob-admission-webhook.prow.svc"},
+			regenerated: true,
+		},
+		{
+			name:        "additional SAN",
+			dnsNames:    append([]string{"prowjob-admission-webhook.default.svc.cluster.local"}, oldNames...),
+			regenerated: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFakeClient()
+			oldCert, _, _, secretData, err := genSecretData(time.Hour, x509.SHA256WithRSA, oldNames)
+			if err != nil {
+				t.Fatalf("Failed to generate the existing secret: %v", err)
+			}
+			f.project.store[secretID] = string(secretData)
+
+			clientoptions := clientOptions{
+				secretID:           secretID,
+				dnsNames:           flagutil.NewStrings(tc.dnsNames...),
+				expiry:             time.Hour,
+				signatureAlgorithm: x509.SHA256WithRSA,
+			}
+			cert, _, _, err := getOrRotateSecret(f, ctx, clientoptions)
+			if err != nil {
+				t.Fatalf("Want no error, got: %v", err)
+			}
+			if regenerated := cert != oldCert; regenerated != tc.regenerated {
+				t.Fatalf("Expected the cert to be regenerated to be %t, got %t", tc.regenerated, regenerated)
+			}
+			block, _ := pem.Decode([]byte(cert))
+			certificate, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				t.Fatalf("Failed to parse the cert: %v", err)
+			}
+			if !reflect.DeepEqual(certificate.DNSNames, tc.dnsNames) {
+				t.Errorf("Expected the cert SANs to be %v, got %v", tc.dnsNames, certificate.DNSNames)
+			}
+		})
+	}
+}
@@ -27,8 +27,10 @@ import (
 	"k8s.io/api/admission/v1beta1"
 	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/prow/prow/apis/prowjobs/v1"
 	"sigs.k8s.io/prow/prow/config"
+	"sigs.k8s.io/prow/prow/kube"
 )
 
 func (wa *webhookAgent) serveMutate(w http.ResponseWriter, r *http.Request) {
@@ -55,7 +57,7 @@ func (wa *webhookAgent) serveMutate(w http.ResponseWriter, r *http.Request) {
 	}
 	var mutatedProwJobPatch []byte
 	if admissionRequest.Operation == "CREATE" {
-		mutatedProwJobPatch, err = generateMutatingPatch(&prowJob, wa.plank)
+		mutatedProwJobPatch, err = generateMutatingPatch(&prowJob, wa.plank, wa.mutations)
 		if err != nil {
 			logrus.WithError(err).Info("unable to return mutated prowjob patch")
 			http.Error(w, fmt.Sprintf("unable to return mutated prowjob patch %v", err), http.StatusInternalServerError)
@@ -91,20 +93,27 @@ func createMutatingAdmissionResponse(uid types.UID, patch []byte) *v1beta1.Admis
 	}
 }
 
-func generateMutatingPatch(prowJob *v1.ProwJob, plank config.Plank) ([]byte, error) {
-	var defDecorationConfig *v1.DecorationConfig
+// generateMutatingPatch returns a JSON patch applying the given mutations to
+// the ProwJob.
+func generateMutatingPatch(prowJob *v1.ProwJob, plank config.Plank, mutations sets.Set[mutation]) ([]byte, error) {
 	var patchBytes []byte
-	if prowJob.Spec.Type == v1.PeriodicJob {
-		var repo string
-		if len(prowJob.Spec.ExtraRefs) > 0 {
-			repo = fmt.Sprintf("%s/%s", prowJob.Spec.ExtraRefs[0].Org, prowJob.Spec.ExtraRefs[0].Repo)
+	prowJobCopy := prowJob.DeepCopy()
+	if mutations.Has(mutationCluster) && prowJobCopy.Spec.Cluster == "" {
+		prowJobCopy.Spec.Cluster = kube.DefaultClusterAlias
+	}
+	if mutations.Has(mutationDecoration) {
+		var defDecorationConfig *v1.DecorationConfig
+		if prowJobCopy.Spec.Type == v1.PeriodicJob {
+			var repo string
+			if len(prowJobCopy.Spec.ExtraRefs) > 0 {
+				repo = fmt.Sprintf("%s/%s", prowJobCopy.Spec.ExtraRefs[0].Org, prowJobCopy.Spec.ExtraRefs[0].Repo)
+			}
+			defDecorationConfig = plank.GuessDefaultDecorationConfigWithJobDC(repo, prowJobCopy.Spec.Cluster, prowJobCopy.Spec.DecorationConfig)
+		} else {
+			defDecorationConfig = plank.GuessDefaultDecorationConfig(prowJobCopy.Spec.Refs.Repo, prowJobCopy.Spec.Cluster)
 		}
-		defDecorationConfig = plank.GuessDefaultDecorationConfigWithJobDC(repo, prowJob.Spec.Cluster, prowJob.Spec.DecorationConfig)
-	} else {
-		defDecorationConfig = plank.GuessDefaultDecorationConfig(prowJob.Spec.Refs.Repo, prowJob.Spec.Cluster)
+		prowJobCopy.Spec.DecorationConfig = prowJobCopy.Spec.DecorationConfig.ApplyDefault(defDecorationConfig)
 	}
-	prowJobCopy := prowJob.DeepCopy()
-	prowJobCopy.Spec.DecorationConfig = prowJobCopy.Spec.DecorationConfig.ApplyDefault(defDecorationConfig)
 	originalProwJobJSON, err := json.Marshal(prowJob)
 	if err != nil {
 		return nil, fmt.Errorf("unable to marshal prowjob %v", err)
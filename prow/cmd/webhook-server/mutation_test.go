@@ -0,0 +1,97 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"gomodules.xyz/jsonpatch/v2"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/prow/prow/apis/prowjobs/v1"
+	"sigs.k8s.io/prow/prow/config"
+)
+
+func TestGenerateMutatingPatchMutations(t *testing.T) {
+	plank := config.Plank{
+		DefaultDecorationConfigs: []*config.DefaultDecorationConfigEntry{{
+			Config: &v1.DecorationConfig{
+				UtilityImages: &v1.UtilityImages{CloneRefs: "clonerefs"},
+			},
+		}},
+	}
+	testCases := []struct {
+		name      string
+		mutations sets.Set[mutation]
+		expected  []string
+	}{
+		{
+			name:      "decoration only",
+			mutations: sets.New(mutationDecoration),
+			expected:  []string{"/spec/decoration_config"},
+		},
+		{
+			name:      "cluster only",
+			mutations: sets.New(mutationCluster),
+			expected:  []string{"/spec/cluster"},
+		},
+		{
+			name:      "all mutations",
+			mutations: sets.New(mutationDecoration, mutationCluster),
+			expected:  []string{"/spec/cluster", "/spec/decoration_config"},
+		},
+		{
+			name:      "no mutations",
+			mutations: sets.New[mutation](),
+			expected:  []string{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prowJob := &v1.ProwJob{Spec: v1.ProwJobSpec{Type: v1.PeriodicJob, Job: "periodic"}}
+			patchBytes, err := generateMutatingPatch(prowJob, plank, tc.mutations)
+			if err != nil {
+				t.Fatalf("Failed to generate patch: %v", err)
+			}
+			var patch []jsonpatch.Operation
+			if err := json.Unmarshal(patchBytes, &patch); err != nil {
+				t.Fatalf("Failed to unmarshal patch %q: %v", string(patchBytes), err)
+			}
+			paths := sets.New[string]()
+			for _, operation := range patch {
+				paths.Insert(operation.Path)
+			}
+			if got := sets.List(paths); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Expected patched paths %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseMutations(t *testing.T) {
+	mutations, err := parseMutations([]string{""})
+	if err != nil {
+		t.Fatalf("Failed to parse mutations: %v", err)
+	}
+	if mutations.Len() != 0 {
+		t.Errorf("Expected no mutations, got %v", sets.List(mutations))
+	}
+	if _, err := parseMutations([]string{"decoration", "labels"}); err == nil {
+		t.Error("Expected an error for an unsupported mutation")
+	}
+}
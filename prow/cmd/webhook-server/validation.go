@@ -22,6 +22,7 @@ import (
 	"io"
 	"net/http"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 
 	"k8s.io/api/admission/v1beta1"
@@ -35,6 +36,15 @@ import (
 
 var agentsNotSupportingCluster = sets.New[string]("jenkins")
 
+var unlabeledProwJobsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "prow_webhook_unlabeled_prowjobs",
+	Help: "Number of admission requests for prowjobs without the admission-webhook: enabled label.",
+}, []string{"operation"})
+
+func init() {
+	prometheus.MustRegister(unlabeledProwJobsCounter)
+}
+
 const (
 	denied   = "DENIED"
 	accepted = "ACCEPTED"
@@ -55,20 +65,37 @@ func (wa *webhookAgent) serveValidate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	admissionRequest := admissionReview.Request
+	raw := admissionRequest.Object.Raw
+	if admissionRequest.Operation == "DELETE" {
+		// The object being deleted is only sent as the old object.
+		raw = admissionRequest.OldObject.Raw
+	}
 	var prowJob v1.ProwJob
-	err = json.Unmarshal(admissionRequest.Object.Raw, &prowJob)
+	err = json.Unmarshal(raw, &prowJob)
 	if err != nil {
 		logrus.WithError(err).Info("unable to prowjob from request")
 		http.Error(w, fmt.Sprintf("unable to unmarshal prowjob %v", err), http.StatusBadRequest)
 		return
 	}
 	var admissionResponse *v1beta1.AdmissionResponse
-	if admissionRequest.Operation == "CREATE" {
+	if prowJob.Labels[admissionWebhookLabel] != "enabled" {
+		unlabeledProwJobsCounter.WithLabelValues(string(admissionRequest.Operation)).Inc()
+		logrus.WithFields(logrus.Fields{
+			"prowjob":   prowJob.Name,
+			"job":       prowJob.Spec.Job,
+			"operation": admissionRequest.Operation,
+		}).Warnf("Prowjob is missing the %s label.", admissionWebhookLabel)
+	}
+	if !wa.shouldValidate(prowJob) {
+		admissionResponse = createValidatingAdmissionResponse(admissionRequest.UID, nil)
+	} else if admissionRequest.Operation == "CREATE" {
 		if err := validateProwJobClusterOnCreate(prowJob, wa.statuses); err != nil {
 			admissionResponse = createValidatingAdmissionResponse(admissionRequest.UID, err)
 		} else {
 			admissionResponse = createValidatingAdmissionResponse(admissionRequest.UID, nil)
 		}
+	} else if admissionRequest.Operation == "DELETE" {
+		admissionResponse = createValidatingAdmissionResponse(admissionRequest.UID, validateProwJobOnDelete(prowJob))
 	}
 	admissionReview.Response = admissionResponse
 	resp, err := json.Marshal(admissionReview)
@@ -84,6 +111,11 @@ func (wa *webhookAgent) serveValidate(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// shouldValidate returns whether the prowjob is validated, or admitted as is.
+func (wa *webhookAgent) shouldValidate(prowJob v1.ProwJob) bool {
+	return prowJob.Labels[admissionWebhookLabel] == "enabled" || wa.unlabeledProwJobs == unlabeledProwJobsValidate
+}
+
 func validateProwJobClusterOnCreate(prowJob v1.ProwJob, statuses map[string]plank.ClusterStatus) error {
 	if prowJob.Spec.Cluster != "" && prowJob.Spec.Cluster != kube.DefaultClusterAlias && agentsNotSupportingCluster.Has(string(prowJob.Spec.Agent)) {
 		return fmt.Errorf("%s: cannot set cluster field if agent is %s", prowJob.Name, prowJob.Spec.Agent)
@@ -97,6 +129,15 @@ func validateProwJobClusterOnCreate(prowJob v1.ProwJob, statuses map[string]plan
 	return nil
 }
 
+// validateProwJobOnDelete rejects deleting prowjobs that are still running,
+// which must be aborted first so that their pods are cleaned up.
+func validateProwJobOnDelete(prowJob v1.ProwJob) error {
+	if !prowJob.Complete() {
+		return fmt.Errorf("%s: cannot delete prowjob in state %s before it completes", prowJob.Name, prowJob.Status.State)
+	}
+	return nil
+}
+
 func createValidatingAdmissionResponse(uid types.UID, err error) *v1beta1.AdmissionResponse {
 	var ar *v1beta1.AdmissionResponse
 	var result *apiv1.Status
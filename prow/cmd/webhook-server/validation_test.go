@@ -0,0 +1,157 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/api/admission/v1beta1"
+	apiv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/prow/prow/apis/prowjobs/v1"
+	"sigs.k8s.io/prow/prow/plank"
+)
+
+func TestServeValidateUnlabeledProwJobs(t *testing.T) {
+	testCases := []struct {
+		name            string
+		mode            unlabeledProwJobsMode
+		labels          map[string]string
+		expectedAllowed bool
+		expectedCounted bool
+	}{
+		{
+			name:            "labeled prowjob is validated",
+			mode:            unlabeledProwJobsWarn,
+			labels:          map[string]string{admissionWebhookLabel: "enabled"},
+			expectedAllowed: false,
+		},
+		{
+			name:            "unlabeled prowjob is admitted in warn mode",
+			mode:            unlabeledProwJobsWarn,
+			expectedAllowed: true,
+			expectedCounted: true,
+		},
+		{
+			name:            "unlabeled prowjob is validated in validate mode",
+			mode:            unlabeledProwJobsValidate,
+			expectedAllowed: false,
+			expectedCounted: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The unknown cluster fails validation.
+			prowJob := v1.ProwJob{
+				ObjectMeta: apiv1.ObjectMeta{Name: "pj", Labels: tc.labels},
+				Spec:       v1.ProwJobSpec{Agent: v1.KubernetesAgent, Cluster: "unknown"},
+			}
+			raw, err := json.Marshal(prowJob)
+			if err != nil {
+				t.Fatalf("Failed to marshal prowjob: %v", err)
+			}
+			body, err := json.Marshal(v1beta1.AdmissionReview{
+				Request: &v1beta1.AdmissionRequest{
+					UID:       "uid",
+					Operation: v1beta1.Create,
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to marshal admission review: %v", err)
+			}
+
+			before := testutil.ToFloat64(unlabeledProwJobsCounter.WithLabelValues("CREATE"))
+			wa := &webhookAgent{statuses: map[string]plank.ClusterStatus{}, unlabeledProwJobs: tc.mode}
+			recorder := httptest.NewRecorder()
+			wa.serveValidate(recorder, httptest.NewRequest("POST", validatePath, bytes.NewReader(body)))
+
+			var review v1beta1.AdmissionReview
+			if err := json.Unmarshal(recorder.Body.Bytes(), &review); err != nil {
+				t.Fatalf("Failed to unmarshal response %q: %v", recorder.Body.String(), err)
+			}
+			if review.Response == nil {
+				t.Fatal("Expected a response")
+			}
+			if review.Response.Allowed != tc.expectedAllowed {
+				t.Errorf("Expected allowed to be %t, got %t", tc.expectedAllowed, review.Response.Allowed)
+			}
+			if counted := testutil.ToFloat64(unlabeledProwJobsCounter.WithLabelValues("CREATE")) > before; counted != tc.expectedCounted {
+				t.Errorf("Expected unlabeled prowjob to be counted to be %t, got %t", tc.expectedCounted, counted)
+			}
+		})
+	}
+}
+
+func TestServeValidateDelete(t *testing.T) {
+	testCases := []struct {
+		name            string
+		status          v1.ProwJobStatus
+		expectedAllowed bool
+	}{
+		{
+			name:   "running prowjob can't be deleted",
+			status: v1.ProwJobStatus{State: v1.PendingState},
+		},
+		{
+			name:            "completed prowjob can be deleted",
+			status:          v1.ProwJobStatus{State: v1.SuccessState, CompletionTime: &apiv1.Time{}},
+			expectedAllowed: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prowJob := v1.ProwJob{
+				ObjectMeta: apiv1.ObjectMeta{Name: "pj", Labels: map[string]string{admissionWebhookLabel: "enabled"}},
+				Status:     tc.status,
+			}
+			raw, err := json.Marshal(prowJob)
+			if err != nil {
+				t.Fatalf("Failed to marshal prowjob: %v", err)
+			}
+			body, err := json.Marshal(v1beta1.AdmissionReview{
+				Request: &v1beta1.AdmissionRequest{
+					UID:       "uid",
+					Operation: v1beta1.Delete,
+					OldObject: runtime.RawExtension{Raw: raw},
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to marshal admission review: %v", err)
+			}
+
+			wa := &webhookAgent{statuses: map[string]plank.ClusterStatus{}, unlabeledProwJobs: unlabeledProwJobsWarn}
+			recorder := httptest.NewRecorder()
+			wa.serveValidate(recorder, httptest.NewRequest("POST", validatePath, bytes.NewReader(body)))
+
+			var review v1beta1.AdmissionReview
+			if err := json.Unmarshal(recorder.Body.Bytes(), &review); err != nil {
+				t.Fatalf("Failed to unmarshal response %q: %v", recorder.Body.String(), err)
+			}
+			if review.Response == nil {
+				t.Fatal("Expected a response")
+			}
+			if review.Response.Allowed != tc.expectedAllowed {
+				t.Errorf("Expected allowed to be %t, got %t", tc.expectedAllowed, review.Response.Allowed)
+			}
+		})
+	}
+}
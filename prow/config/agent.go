@@ -44,8 +44,13 @@ type Agent struct {
 	mut           sync.RWMutex // do not export Lock, etc methods
 	c             *Config
 	subscriptions []DeltaChan
+	reloadHooks   []ReloadHook
 }
 
+// ReloadHook is called every time the Agent reloads the config after the
+// initial load, with the error if the reload failed or nil if it succeeded.
+type ReloadHook func(err error)
+
 // IsConfigMapMount determines whether the provided directory is a configmap mounted directory
 func IsConfigMapMount(path string) (bool, error) {
 	files, err := os.ReadDir(path)
@@ -204,18 +209,22 @@ func watchConfigs(ca *Agent, prowConfig, jobConfig string, supplementalProwConfi
 	cmEventFunc := func() error {
 		c, err := Load(prowConfig, jobConfig, supplementalProwConfigDirs, supplementalProwConfigsFileNameSuffix, additionals...)
 		if err != nil {
+			ca.reloaded(err)
 			return err
 		}
 		ca.Set(c)
+		ca.reloaded(nil)
 		return nil
 	}
 	// We may need to add more directories to be watched
 	dirsEventFunc := func(w *fsnotify.Watcher) error {
 		c, err := Load(prowConfig, jobConfig, supplementalProwConfigDirs, supplementalProwConfigsFileNameSuffix, additionals...)
 		if err != nil {
+			ca.reloaded(err)
 			return err
 		}
 		ca.Set(c)
+		ca.reloaded(nil)
 		// TODO(AlexNPavel): Is there a chance that a ConfigMap mounted directory may appear without making a new pod? If yes, handle that.
 		_, dirs, err := ListCMsAndDirs(jobConfig)
 		if err != nil {
@@ -365,13 +374,16 @@ func (ca *Agent) Start(prowConfig, jobConfig string, additionalProwConfigDirs []
 				}
 				lastModTime = recentModTime
 			}
-			if c, err := Load(prowConfig, jobConfig, additionalProwConfigDirs, supplementalProwConfigsFileNameSuffix, additionals...); err != nil {
+			c, err := Load(prowConfig, jobConfig, additionalProwConfigDirs, supplementalProwConfigsFileNameSuffix, additionals...)
+			if err != nil {
 				logrus.WithField("prowConfig", prowConfig).
 					WithField("jobConfig", jobConfig).
 					WithError(err).Error("Error loading config.")
+				ca.reloaded(err)
 			} else {
 				skips = 0
 				ca.Set(c)
+				ca.reloaded(nil)
 			}
 		}
 	}()
@@ -388,6 +400,23 @@ func (ca *Agent) Subscribe(subscription DeltaChan) {
 	ca.subscriptions = append(ca.subscriptions, subscription)
 }
 
+// AddReloadHook registers a hook to be called after every config reload.
+func (ca *Agent) AddReloadHook(hook ReloadHook) {
+	ca.mut.Lock()
+	defer ca.mut.Unlock()
+	ca.reloadHooks = append(ca.reloadHooks, hook)
+}
+
+// reloaded calls the reload hooks with the result of a config reload.
+func (ca *Agent) reloaded(err error) {
+	ca.mut.RLock()
+	hooks := ca.reloadHooks
+	ca.mut.RUnlock()
+	for _, hook := range hooks {
+		hook(err)
+	}
+}
+
 // Getter returns the current Config in a thread-safe manner.
 type Getter func() *Config
 
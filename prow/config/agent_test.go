@@ -0,0 +1,74 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAgentReloadHooks(t *testing.T) {
+	prowConfig := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(prowConfig, []byte("log_level: info\n"), 0666); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	ca := &Agent{}
+	if err := ca.Start(prowConfig, "", nil, ""); err != nil {
+		t.Fatalf("failed to start agent: %v", err)
+	}
+	reloads := make(chan error, 10)
+	ca.AddReloadHook(func(err error) { reloads <- err })
+
+	// The agent polls the modification time of the config, so make sure it moves forward.
+	modTime := time.Now()
+	update := func(content string) {
+		if err := os.WriteFile(prowConfig, []byte(content), 0666); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+		modTime = modTime.Add(time.Hour)
+		if err := os.Chtimes(prowConfig, modTime, modTime); err != nil {
+			t.Fatalf("failed to update config modification time: %v", err)
+		}
+	}
+	waitForReload := func() error {
+		select {
+		case err := <-reloads:
+			return err
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for config reload")
+			return nil
+		}
+	}
+
+	update("log_level: [")
+	if err := waitForReload(); err == nil {
+		t.Error("expected the reload of an invalid config to fail")
+	}
+	if got := ca.Config().LogLevel; got != "info" {
+		t.Errorf("expected the previous config to be kept after a failed reload, got log level %q", got)
+	}
+
+	update("log_level: debug\n")
+	if err := waitForReload(); err != nil {
+		t.Errorf("expected the reload of a valid config to succeed, got: %v", err)
+	}
+	if got := ca.Config().LogLevel; got != "debug" {
+		t.Errorf("expected the new config to be loaded, got log level %q", got)
+	}
+}
@@ -17,14 +17,22 @@ limitations under the License.
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
+
+	prowapi "sigs.k8s.io/prow/prow/apis/prowjobs/v1"
+	"sigs.k8s.io/prow/prow/git/types"
 )
 
 // Policy for the config/org/repo/branch.
@@ -47,6 +55,8 @@ type Policy struct {
 	RequiredPullRequestReviews *ReviewPolicy `json:"required_pull_request_reviews,omitempty"`
 	// RequiredLinearHistory enforces a linear commit Git history, which prevents anyone from pushing merge commits to a branch.
 	RequiredLinearHistory *bool `json:"required_linear_history,omitempty"`
+	// RequiredSignatures requires commits pushed to the protected branch to have verified signatures.
+	RequiredSignatures *bool `json:"required_signatures,omitempty"`
 	// AllowForcePushes permits force pushes to the protected branch by anyone with write access to the repository.
 	AllowForcePushes *bool `json:"allow_force_pushes,omitempty"`
 	// AllowDeletions allows deletion of the protected branch by anyone with write access to the repository.
@@ -66,17 +76,47 @@ func (p Policy) Managed() bool {
 
 func (p Policy) defined() bool {
 	return p.Protect != nil || p.RequiredStatusChecks != nil || p.Admins != nil || p.Restrictions != nil || p.RequireManuallyTriggeredJobs != nil ||
-		p.RequiredPullRequestReviews != nil || p.RequiredLinearHistory != nil || p.AllowForcePushes != nil || p.AllowDeletions != nil
+		p.RequiredPullRequestReviews != nil || p.RequiredLinearHistory != nil || p.RequiredSignatures != nil || p.AllowForcePushes != nil || p.AllowDeletions != nil
+}
+
+// ReviewDismissalProblems returns why the required approvals and review
+// dismissal settings of the policy are likely to keep PRs from merging or to
+// not work as intended, if they are.
+func (p Policy) ReviewDismissalProblems() []string {
+	reviews := p.RequiredPullRequestReviews
+	if p.Protect == nil || !*p.Protect || reviews == nil || reviews.DismissStale == nil || !*reviews.DismissStale {
+		return nil
+	}
+	if reviews.Approvals == nil || *reviews.Approvals == 0 {
+		return []string{"dismiss_stale_reviews has no effect without required_approving_review_count"}
+	}
+	if p.RequiredStatusChecks != nil && p.RequiredStatusChecks.Strict != nil && *p.RequiredStatusChecks.Strict {
+		return []string{fmt.Sprintf("strict status checks require updating PRs with their base branch, which dismisses their %d required approvals with dismiss_stale_reviews", *reviews.Approvals)}
+	}
+	return nil
+}
+
+// AdminsBypassStrictChecks returns whether the policy requires strict status
+// checks without enforcing them for admins, who can then merge PRs that aren't
+// up to date with their base branch.
+func (p Policy) AdminsBypassStrictChecks() bool {
+	return boolValFromPtr(p.Protect) && p.RequiredStatusChecks != nil && boolValFromPtr(p.RequiredStatusChecks.Strict) && !boolValFromPtr(p.Admins)
 }
 
 // ContextPolicy configures required github contexts.
-// When merging policies, contexts are appended to context list from parent.
+// When merging policies, contexts are appended to context list from parent,
+// unless prefixed with "-", in which case the context is removed from the parent list.
 // Strict determines whether merging to the branch invalidates existing contexts.
 type ContextPolicy struct {
-	// Contexts appends required contexts that must be green to merge
+	// Contexts appends required contexts that must be green to merge,
+	// or removes inherited contexts when prefixed with "-"
 	Contexts []string `json:"contexts,omitempty"`
 	// Strict overrides whether new commits in the base branch require updating the PR if set
 	Strict *bool `json:"strict,omitempty"`
+	// Aliases appends other context names that satisfy a required context, e.g. the
+	// old name of a renamed job during a migration. They are not sent to GitHub, but
+	// honored by tooling that evaluates required contexts.
+	Aliases map[string][]string `json:"aliases,omitempty"`
 }
 
 // ReviewPolicy specifies github approval/review criteria.
@@ -93,6 +133,10 @@ type ReviewPolicy struct {
 	Approvals *int `json:"required_approving_review_count,omitempty"`
 	// BypassRestrictions appends users/teams that are allowed to bypass PR restrictions
 	BypassRestrictions *BypassRestrictions `json:"bypass_pull_request_allowances,omitempty"`
+	// RequiredApprovingTeams appends teams whose approval is mandatory to merge, in
+	// addition to the number of approvals. Only teams are honored. GitHub can only
+	// enforce this through rulesets, so it is not applied by branchprotector.
+	RequiredApprovingTeams *Restrictions `json:"required_approving_teams,omitempty"`
 }
 
 // DismissalRestrictions limits who can merge
@@ -146,19 +190,61 @@ func unionStrings(parent, child []string) []string {
 	return sets.List(s)
 }
 
+// contextRemovalPrefix marks a child context that removes the context from the parent list
+const contextRemovalPrefix = "-"
+
+// mergeContexts merges the parent and child contexts together, removing any parent
+// context that the child lists with the contextRemovalPrefix
+func mergeContexts(parent, child []string) []string {
+	var added, removed []string
+	for _, context := range child {
+		if strings.HasPrefix(context, contextRemovalPrefix) {
+			removed = append(removed, strings.TrimPrefix(context, contextRemovalPrefix))
+		} else {
+			added = append(added, context)
+		}
+	}
+	if len(removed) == 0 {
+		return unionStrings(parent, child)
+	}
+	s := sets.New[string](parent...)
+	s.Insert(added...)
+	s.Delete(removed...)
+	return sets.List(s)
+}
+
 func mergeContextPolicy(parent, child *ContextPolicy) *ContextPolicy {
 	if child == nil {
 		return parent
 	}
 	if parent == nil {
-		return child
+		parent = &ContextPolicy{}
 	}
 	return &ContextPolicy{
-		Contexts: unionStrings(parent.Contexts, child.Contexts),
+		Contexts: mergeContexts(parent.Contexts, child.Contexts),
 		Strict:   selectBool(parent.Strict, child.Strict),
+		Aliases:  mergeContextAliases(parent.Aliases, child.Aliases),
 	}
 }
 
+// mergeContextAliases merges the aliases of each context of the parent and child together
+func mergeContextAliases(parent, child map[string][]string) map[string][]string {
+	if len(child) == 0 {
+		return parent
+	}
+	if len(parent) == 0 {
+		return child
+	}
+	merged := make(map[string][]string, len(parent))
+	for context, aliases := range parent {
+		merged[context] = aliases
+	}
+	for context, aliases := range child {
+		merged[context] = unionStrings(merged[context], aliases)
+	}
+	return merged
+}
+
 func mergeReviewPolicy(parent, child *ReviewPolicy) *ReviewPolicy {
 	if child == nil {
 		return parent
@@ -167,11 +253,12 @@ func mergeReviewPolicy(parent, child *ReviewPolicy) *ReviewPolicy {
 		return child
 	}
 	return &ReviewPolicy{
-		DismissalRestrictions: mergeDismissalRestrictions(parent.DismissalRestrictions, child.DismissalRestrictions),
-		DismissStale:          selectBool(parent.DismissStale, child.DismissStale),
-		RequireOwners:         selectBool(parent.RequireOwners, child.RequireOwners),
-		Approvals:             selectInt(parent.Approvals, child.Approvals),
-		BypassRestrictions:    mergeBypassRestrictions(parent.BypassRestrictions, child.BypassRestrictions),
+		DismissalRestrictions:  mergeDismissalRestrictions(parent.DismissalRestrictions, child.DismissalRestrictions),
+		DismissStale:           selectBool(parent.DismissStale, child.DismissStale),
+		RequireOwners:          selectBool(parent.RequireOwners, child.RequireOwners),
+		Approvals:              selectInt(parent.Approvals, child.Approvals),
+		BypassRestrictions:     mergeBypassRestrictions(parent.BypassRestrictions, child.BypassRestrictions),
+		RequiredApprovingTeams: mergeRestrictions(parent.RequiredApprovingTeams, child.RequiredApprovingTeams),
 	}
 }
 
@@ -223,6 +310,7 @@ func (p Policy) Apply(child Policy) Policy {
 		RequiredStatusChecks:         mergeContextPolicy(p.RequiredStatusChecks, child.RequiredStatusChecks),
 		Admins:                       selectBool(p.Admins, child.Admins),
 		RequiredLinearHistory:        selectBool(p.RequiredLinearHistory, child.RequiredLinearHistory),
+		RequiredSignatures:           selectBool(p.RequiredSignatures, child.RequiredSignatures),
 		AllowForcePushes:             selectBool(p.AllowForcePushes, child.AllowForcePushes),
 		AllowDeletions:               selectBool(p.AllowDeletions, child.AllowDeletions),
 		RequireManuallyTriggeredJobs: selectBool(p.RequireManuallyTriggeredJobs, child.RequireManuallyTriggeredJobs),
@@ -233,6 +321,83 @@ func (p Policy) Apply(child Policy) Policy {
 	}
 }
 
+// Normalize returns a copy of the policy in canonical form, so that equivalent
+// policies compare equal: lists are sorted and deduplicated, and empty lists and
+// maps are nil. Nil and empty structs are kept apart, as e.g. empty restrictions
+// restrict pushes to nobody while nil restrictions don't restrict them.
+func (p Policy) Normalize() Policy {
+	n := p
+	n.Exclude = normalizeStrings(p.Exclude)
+	n.Include = normalizeStrings(p.Include)
+	if p.RequiredStatusChecks != nil {
+		n.RequiredStatusChecks = &ContextPolicy{
+			Contexts: normalizeStrings(p.RequiredStatusChecks.Contexts),
+			Strict:   p.RequiredStatusChecks.Strict,
+		}
+		for context, aliases := range p.RequiredStatusChecks.Aliases {
+			if aliases = normalizeStrings(aliases); aliases == nil {
+				continue
+			}
+			if n.RequiredStatusChecks.Aliases == nil {
+				n.RequiredStatusChecks.Aliases = map[string][]string{}
+			}
+			n.RequiredStatusChecks.Aliases[context] = aliases
+		}
+	}
+	n.Restrictions = normalizeRestrictions(p.Restrictions)
+	if p.RequiredPullRequestReviews != nil {
+		reviews := *p.RequiredPullRequestReviews
+		if reviews.DismissalRestrictions != nil {
+			reviews.DismissalRestrictions = &DismissalRestrictions{
+				Users: normalizeStrings(reviews.DismissalRestrictions.Users),
+				Teams: normalizeStrings(reviews.DismissalRestrictions.Teams),
+			}
+		}
+		if reviews.BypassRestrictions != nil {
+			reviews.BypassRestrictions = &BypassRestrictions{
+				Users: normalizeStrings(reviews.BypassRestrictions.Users),
+				Teams: normalizeStrings(reviews.BypassRestrictions.Teams),
+			}
+		}
+		reviews.RequiredApprovingTeams = normalizeRestrictions(reviews.RequiredApprovingTeams)
+		n.RequiredPullRequestReviews = &reviews
+	}
+	return n
+}
+
+// normalizeRestrictions returns a copy of the restrictions with normalized lists
+func normalizeRestrictions(r *Restrictions) *Restrictions {
+	if r == nil {
+		return nil
+	}
+	return &Restrictions{
+		Apps:  normalizeStrings(r.Apps),
+		Users: normalizeStrings(r.Users),
+		Teams: normalizeStrings(r.Teams),
+	}
+}
+
+// normalizeStrings returns the sorted unique items, or nil if there are none
+func normalizeStrings(items []string) []string {
+	if len(items) == 0 {
+		return nil
+	}
+	return sets.List(sets.New[string](items...))
+}
+
+// Hash returns a deterministic hash of the policy for change detection, e.g.
+// to cache the effective policy of a branch. Equivalent policies as defined by
+// Normalize hash identically.
+func (p Policy) Hash() string {
+	// Maps are marshaled with sorted keys, so the encoding is deterministic.
+	data, err := json.Marshal(p.Normalize())
+	if err != nil {
+		// Policies only consist of types that can always be marshaled.
+		panic(fmt.Sprintf("failed to marshal policy: %v", err))
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
 // BranchProtection specifies the global branch protection policy
 type BranchProtection struct {
 	Policy `json:",inline"`
@@ -341,6 +506,20 @@ func (bp *BranchProtection) merge(additional *BranchProtection) error {
 				repoSettings.Policy = additional.Orgs[org].Repos[repo].Policy
 				bp.Orgs[org].Repos[repo] = repoSettings
 			}
+			if bp.Orgs[org].Repos[repo].AllowedMergeMethods != nil && additional.Orgs[org].Repos[repo].AllowedMergeMethods != nil {
+				errs = append(errs, fmt.Errorf("both branchprotection configs set allowed_merge_methods for repo %s/%s", org, repo))
+			} else if additional.Orgs[org].Repos[repo].AllowedMergeMethods != nil {
+				repoSettings := bp.Orgs[org].Repos[repo]
+				repoSettings.AllowedMergeMethods = additional.Orgs[org].Repos[repo].AllowedMergeMethods
+				bp.Orgs[org].Repos[repo] = repoSettings
+			}
+			if bp.Orgs[org].Repos[repo].Archived != nil && additional.Orgs[org].Repos[repo].Archived != nil {
+				errs = append(errs, fmt.Errorf("both branchprotection configs set archived for repo %s/%s", org, repo))
+			} else if additional.Orgs[org].Repos[repo].Archived != nil {
+				repoSettings := bp.Orgs[org].Repos[repo]
+				repoSettings.Archived = additional.Orgs[org].Repos[repo].Archived
+				bp.Orgs[org].Repos[repo] = repoSettings
+			}
 
 			for branch := range additional.Orgs[org].Repos[repo].Branches {
 				if bp.Orgs[org].Repos[repo].Branches == nil {
@@ -413,8 +592,104 @@ func (o Org) GetRepo(name string) *Repo {
 
 // Repo holds protection policy overrides for all branches in a repo, as well as specific branch overrides.
 type Repo struct {
-	Policy   `json:",inline"`
-	Branches map[string]Branch `json:"branches,omitempty"`
+	Policy `json:",inline"`
+	// Archived marks the repo as archived. The protection of archived repos
+	// can't be changed, so they are skipped from enforcement.
+	Archived *bool `json:"archived,omitempty"`
+	// AllowedMergeMethods lists the methods PRs of the repo may be merged with,
+	// out of merge, rebase and squash. This is a repo setting rather than
+	// branch protection, so it's not applied by branchprotector but surfaced
+	// through GetAllowedMergeMethods for tooling to apply.
+	AllowedMergeMethods []types.PullRequestMergeType `json:"allowed_merge_methods,omitempty"`
+	Branches            map[string]Branch            `json:"branches,omitempty"`
+}
+
+// IsArchived returns true if the repo is marked as archived.
+func (r Repo) IsArchived() bool {
+	return r.Archived != nil && *r.Archived
+}
+
+// GetAllowedMergeMethods returns the methods PRs of the repo may be merged with,
+// or nil if they are not configured or the repo is archived.
+func (bp BranchProtection) GetAllowedMergeMethods(org, repo string) []types.PullRequestMergeType {
+	r := bp.Orgs[org].Repos[repo]
+	if r.IsArchived() {
+		return nil
+	}
+	return r.AllowedMergeMethods
+}
+
+// validateAllowedMergeMethods returns an error for each repo allowing unknown
+// or duplicate merge methods, or none at all.
+func (bp BranchProtection) validateAllowedMergeMethods() error {
+	var errs []error
+	for _, orgName := range sets.List(sets.KeySet(bp.Orgs)) {
+		repos := bp.Orgs[orgName].Repos
+		for _, repoName := range sets.List(sets.KeySet(repos)) {
+			methods := repos[repoName].AllowedMergeMethods
+			if methods != nil && len(methods) == 0 {
+				errs = append(errs, fmt.Errorf("%s/%s: allowed_merge_methods must allow at least one merge method", orgName, repoName))
+			}
+			seen := sets.New[types.PullRequestMergeType]()
+			for _, method := range methods {
+				switch {
+				case method != types.MergeMerge && method != types.MergeRebase && method != types.MergeSquash:
+					errs = append(errs, fmt.Errorf("%s/%s: allowed_merge_methods: unknown merge method %q, expected one of merge, rebase or squash", orgName, repoName, method))
+				case seen.Has(method):
+					errs = append(errs, fmt.Errorf("%s/%s: allowed_merge_methods: duplicate merge method %q", orgName, repoName, method))
+				}
+				seen.Insert(method)
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// validateLinearHistory returns an error for each repo or branch requiring a
+// linear history while it can only be merged with merge commits, which GitHub
+// would refuse: because its allowed_merge_methods only allow merge, or because
+// the merge method configured for it in tide is merge. Tide is only checked if
+// set, and only for repos with an explicitly configured merge method, as tide
+// may not merge them at all.
+func (bp BranchProtection) validateLinearHistory(tide *Tide) error {
+	var errs []error
+	validate := func(orgName, repoName, branchName string, policy Policy, methods []types.PullRequestMergeType) {
+		if policy.RequiredLinearHistory == nil || !*policy.RequiredLinearHistory {
+			return
+		}
+		location := fmt.Sprintf("%s/%s", orgName, repoName)
+		if branchName != "" {
+			location = fmt.Sprintf("%s=%s", location, branchName)
+		}
+		if methods != nil && !sets.New(methods...).HasAny(types.MergeRebase, types.MergeSquash) {
+			errs = append(errs, fmt.Errorf("%s: required_linear_history conflicts with allowed_merge_methods, which only allow merge commits", location))
+		}
+		if tide == nil {
+			return
+		}
+		if method, configured := tide.configuredMergeMethod(OrgRepo{Org: orgName, Repo: repoName}, branchName); configured && method == types.MergeMerge {
+			errs = append(errs, fmt.Errorf("%s: required_linear_history conflicts with the tide merge method, which is merge", location))
+		}
+	}
+	for _, orgName := range sets.List(sets.KeySet(bp.Orgs)) {
+		org := bp.GetOrg(orgName)
+		for _, repoName := range sets.List(sets.KeySet(bp.Orgs[orgName].Repos)) {
+			repo := org.GetRepo(repoName)
+			if repo.IsArchived() {
+				continue
+			}
+			validate(orgName, repoName, "", repo.Policy, repo.AllowedMergeMethods)
+			for _, branchName := range sets.List(sets.KeySet(repo.Branches)) {
+				branch, err := repo.GetBranch(branchName)
+				if err != nil {
+					// Reported by ValidateBranchProtection.
+					continue
+				}
+				validate(orgName, repoName, branchName, branch.Policy, repo.AllowedMergeMethods)
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
 }
 
 // HasManagedBranches returns true if the repo has managed branches
@@ -453,7 +728,11 @@ func (c *Config) GetBranchProtection(org, repo, branch string, presubmits []Pres
 	if _, present := c.BranchProtection.Orgs[org]; !present {
 		return nil, nil // only consider branches in configured orgs
 	}
-	b, err := c.BranchProtection.GetOrg(org).GetRepo(repo).GetBranch(branch)
+	r := c.BranchProtection.GetOrg(org).GetRepo(repo)
+	if r.IsArchived() {
+		return nil, nil
+	}
+	b, err := r.GetBranch(branch)
 	if err != nil {
 		return nil, err
 	}
@@ -461,12 +740,299 @@ func (c *Config) GetBranchProtection(org, repo, branch string, presubmits []Pres
 	return c.GetPolicy(org, repo, branch, *b, presubmits, nil)
 }
 
-// GetPolicy returns the protection policy for the branch, after merging in presubmits.
-func (c *Config) GetPolicy(org, repo, branch string, b Branch, presubmits []Presubmit, protectedOnGitHub *bool) (*Policy, error) {
+// GetRepoBranchProtections returns the effective policy for each of the given
+// branches of the repo, keyed by branch name.
+//
+// Unlike Repo.Branches, which only holds explicitly configured branches, this
+// selects branches the same way branchprotector does: configured branches are
+// always considered, the others must match the include patterns of the repo
+// policy, if any, and not match its exclude patterns. Branches that are not
+// selected, are unmanaged or end up with no policy are omitted.
+func (c *Config) GetRepoBranchProtections(org, repo string, branches []string, presubmits []Presubmit) (map[string]*Policy, error) {
+	if _, present := c.BranchProtection.Orgs[org]; !present {
+		return nil, nil // only consider branches in configured orgs
+	}
+	r := c.BranchProtection.GetOrg(org).GetRepo(repo)
+	if r.IsArchived() {
+		return nil, nil
+	}
+	if r.Unmanaged != nil && *r.Unmanaged && !r.HasManagedBranches() {
+		return nil, nil
+	}
+
+	selected, err := r.branchSelector()
+	if err != nil {
+		return nil, err
+	}
+
+	policies := map[string]*Policy{}
+	var errs []error
+	for _, branch := range branches {
+		if !selected(branch) {
+			continue
+		}
+		b, err := r.GetBranch(branch)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s=%s: %w", org, repo, branch, err))
+			continue
+		}
+		if b.Unmanaged != nil && *b.Unmanaged {
+			continue
+		}
+		policy, err := c.GetPolicy(org, repo, branch, *b, presubmits, nil)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if policy != nil {
+			policies[branch] = policy
+		}
+	}
+	return policies, utilerrors.NewAggregate(errs)
+}
+
+// GetBranchProtectionsMatching returns the effective policy of each of the
+// given branches of the repo that matches the pattern, keyed by branch name.
+//
+// The pattern is a regular expression, matched like the include and exclude
+// patterns of policies, e.g. `^release-` resolves the protection of every
+// release branch in the branch list. Each matching branch gets the policy
+// GetRepoBranchProtections computes for it, so configured branches still
+// override the repo policy.
+func (c *Config) GetBranchProtectionsMatching(org, repo, pattern string, branches []string, presubmits []Presubmit) (map[string]*Policy, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid branch pattern %q: %w", pattern, err)
+	}
+	var matching []string
+	for _, branch := range branches {
+		if re.MatchString(branch) {
+			matching = append(matching, branch)
+		}
+	}
+	if len(matching) == 0 {
+		return nil, nil
+	}
+	return c.GetRepoBranchProtections(org, repo, matching, presubmits)
+}
+
+// RequiredContextsByOrg returns the distinct contexts required on the branches
+// of each configured org, e.g. to budget CI capacity.
+//
+// Branches are keyed by org/repo and selected like GetRepoBranchProtections
+// does. The configured repos and branches are always considered, so passing no
+// branches returns the contexts required on the configured ones. Orgs that
+// require no contexts are omitted.
+func (c *Config) RequiredContextsByOrg(branches map[string][]string, presubmits map[string][]Presubmit) (map[string]sets.Set[string], error) {
+	contexts := map[string]sets.Set[string]{}
+	var errs []error
+	for orgRepo, names := range c.repoBranches(branches) {
+		org, repo, ok := strings.Cut(orgRepo, "/")
+		if !ok {
+			errs = append(errs, fmt.Errorf("invalid repo %q, expected org/repo", orgRepo))
+			continue
+		}
+		policies, err := c.GetRepoBranchProtections(org, repo, sets.List(names), presubmits[orgRepo])
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, policy := range policies {
+			if !boolValFromPtr(policy.Protect) || policy.RequiredStatusChecks == nil || len(policy.RequiredStatusChecks.Contexts) == 0 {
+				continue
+			}
+			if contexts[org] == nil {
+				contexts[org] = sets.New[string]()
+			}
+			contexts[org].Insert(policy.RequiredStatusChecks.Contexts...)
+		}
+	}
+	return contexts, utilerrors.NewAggregate(errs)
+}
+
+// BranchContexts are the contexts of a protected branch, split by how they are
+// enforced.
+type BranchContexts struct {
+	// Required are the contexts required by the merged policy of the branch.
+	Required []string `json:"required,omitempty"`
+	// RequiredIfPresent are the contexts of conditionally triggered presubmits,
+	// which are only required on the pull requests they run on.
+	RequiredIfPresent []string `json:"required_if_present,omitempty"`
+	// Optional are the contexts of optional presubmits.
+	Optional []string `json:"optional,omitempty"`
+}
+
+// RequiredContexts holds the contexts of protected branches, keyed by org, repo
+// and branch. It is meant to be serialized to JSON or YAML for external status
+// check tooling.
+type RequiredContexts map[string]map[string]map[string]BranchContexts
+
+// ExportRequiredContexts returns the contexts of every protected branch.
+//
+// Branches are keyed by org/repo and selected like RequiredContextsByOrg does.
+// The required contexts are the ones of the merged policy of each branch, so
+// they include the configured contexts, while the contexts that are required
+// if present and the optional ones come from BranchRequirements.
+func (c *Config) ExportRequiredContexts(branches map[string][]string, presubmits map[string][]Presubmit) (RequiredContexts, error) {
+	contexts := RequiredContexts{}
+	var errs []error
+	for orgRepo, names := range c.repoBranches(branches) {
+		org, repo, ok := strings.Cut(orgRepo, "/")
+		if !ok {
+			errs = append(errs, fmt.Errorf("invalid repo %q, expected org/repo", orgRepo))
+			continue
+		}
+		policies, err := c.GetRepoBranchProtections(org, repo, sets.List(names), presubmits[orgRepo])
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for branch, policy := range policies {
+			if !boolValFromPtr(policy.Protect) {
+				continue
+			}
+			var bc BranchContexts
+			if policy.RequiredStatusChecks != nil {
+				bc.Required = normalizeStrings(policy.RequiredStatusChecks.Contexts)
+			}
+			_, requiredIfPresent, optional := BranchRequirements(branch, presubmits[orgRepo], policy.RequireManuallyTriggeredJobs)
+			bc.RequiredIfPresent = normalizeStrings(requiredIfPresent)
+			bc.Optional = normalizeStrings(optional)
+			if contexts[org] == nil {
+				contexts[org] = map[string]map[string]BranchContexts{}
+			}
+			if contexts[org][repo] == nil {
+				contexts[org][repo] = map[string]BranchContexts{}
+			}
+			contexts[org][repo][branch] = bc
+		}
+	}
+	return contexts, utilerrors.NewAggregate(errs)
+}
+
+// repoBranches returns the given branches keyed by org/repo, along with the
+// branches configured in the branch protection config.
+func (c *Config) repoBranches(branches map[string][]string) map[string]sets.Set[string] {
+	repoBranches := map[string]sets.Set[string]{}
+	for orgRepo, names := range branches {
+		repoBranches[orgRepo] = sets.New[string](names...)
+	}
+	for orgName, org := range c.BranchProtection.Orgs {
+		for repoName, repo := range org.Repos {
+			orgRepo := orgName + "/" + repoName
+			if repoBranches[orgRepo] == nil {
+				repoBranches[orgRepo] = sets.New[string]()
+			}
+			repoBranches[orgRepo].Insert(sets.List(sets.KeySet(repo.Branches))...)
+		}
+	}
+	return repoBranches
+}
+
+// branchSelector returns whether branchprotector considers a branch of the repo:
+// configured branches always are, the others must match the include patterns
+// of the repo policy, if any, and not match its exclude patterns.
+func (r Repo) branchSelector() (func(branch string) bool, error) {
+	var branchInclusions *regexp.Regexp
+	if len(r.Include) > 0 {
+		var err error
+		if branchInclusions, err = regexp.Compile(strings.Join(r.Include, `|`)); err != nil {
+			return nil, err
+		}
+	}
+	var branchExclusions *regexp.Regexp
+	if len(r.Exclude) > 0 {
+		var err error
+		if branchExclusions, err = regexp.Compile(strings.Join(r.Exclude, `|`)); err != nil {
+			return nil, err
+		}
+	}
+	return func(branch string) bool {
+		if _, configured := r.Branches[branch]; configured {
+			return true
+		}
+		if branchInclusions != nil {
+			return branchInclusions.MatchString(branch)
+		}
+		return branchExclusions == nil || !branchExclusions.MatchString(branch)
+	}, nil
+}
+
+// SkipReason describes why a branch is not protected by branchprotector.
+type SkipReason string
+
+const (
+	// SkipReasonNone means the branch has a protection policy.
+	SkipReasonNone SkipReason = ""
+	// SkipReasonOrgNotConfigured means the org of the branch is not configured.
+	SkipReasonOrgNotConfigured SkipReason = "org-not-configured"
+	// SkipReasonArchived means the repo of the branch is marked as archived.
+	SkipReasonArchived SkipReason = "archived"
+	// SkipReasonExcluded means the branch doesn't match the include patterns
+	// of its repo, or matches its exclude patterns.
+	SkipReasonExcluded SkipReason = "excluded"
+	// SkipReasonUnmanaged means the branch protection is unmanaged.
+	SkipReasonUnmanaged SkipReason = "unmanaged"
+	// SkipReasonDisabled means the branch explicitly sets protect: false.
+	SkipReasonDisabled SkipReason = "disabled"
+	// SkipReasonNoPolicy means no protection setting applies to the branch.
+	SkipReasonNoPolicy SkipReason = "no-policy"
+)
+
+// GetBranchProtectionWithReason returns the policy branchprotector applies to a
+// given branch, or the reason it is skipped when the policy is nil.
+//
+// Unlike GetBranchProtection, it honors the include and exclude patterns of the
+// repo and returns no policy for unmanaged and disabled branches.
+func (c *Config) GetBranchProtectionWithReason(org, repo, branch string, presubmits []Presubmit) (*Policy, SkipReason, error) {
+	if _, present := c.BranchProtection.Orgs[org]; !present {
+		return nil, SkipReasonOrgNotConfigured, nil
+	}
+	r := c.BranchProtection.GetOrg(org).GetRepo(repo)
+	if r.IsArchived() {
+		return nil, SkipReasonArchived, nil
+	}
+	selected, err := r.branchSelector()
+	if err != nil {
+		return nil, SkipReasonNone, err
+	}
+	if !selected(branch) {
+		return nil, SkipReasonExcluded, nil
+	}
+	b, err := r.GetBranch(branch)
+	if err != nil {
+		return nil, SkipReasonNone, err
+	}
+	if b.Unmanaged != nil && *b.Unmanaged {
+		return nil, SkipReasonUnmanaged, nil
+	}
+	policy, err := c.GetPolicy(org, repo, branch, *b, presubmits, nil)
+	if err != nil {
+		return nil, SkipReasonNone, err
+	}
+	if b.Protect != nil && !*b.Protect {
+		return nil, SkipReasonDisabled, nil
+	}
+	if policy == nil {
+		return nil, SkipReasonNoPolicy, nil
+	}
+	return policy, SkipReasonNone, nil
+}
+
+// ContextSource returns additional contexts that are required on the branch,
+// e.g. the contexts of checks driven by jobs other than presubmits.
+type ContextSource func(org, repo, branch string) []string
+
+// GetPolicy returns the protection policy for the branch, after merging in presubmits
+// and the contexts of any additional context sources.
+func (c *Config) GetPolicy(org, repo, branch string, b Branch, presubmits []Presubmit, protectedOnGitHub *bool, contextSources ...ContextSource) (*Policy, error) {
 	policy := b.Policy
 
+	var extraContexts []string
+	for _, source := range contextSources {
+		extraContexts = append(extraContexts, source(org, repo, branch)...)
+	}
+
 	// Automatically require contexts from prow which must always be present
-	if prowContexts, requiredIfPresentContexts, optionalContexts := BranchRequirements(branch, presubmits, policy.RequireManuallyTriggeredJobs); c.shouldManageRequiredStatusCheck(prowContexts, requiredIfPresentContexts, optionalContexts) {
+	if ps, ok := c.presubmitPolicy(branch, presubmits, policy.RequireManuallyTriggeredJobs, extraContexts); ok {
 		// Error if protection is disabled
 		if policy.Protect != nil && !*policy.Protect {
 			if c.BranchProtection.AllowDisabledJobPolicies != nil && *c.BranchProtection.AllowDisabledJobPolicies {
@@ -479,16 +1045,6 @@ func (c *Config) GetPolicy(org, repo, branch string, b Branch, presubmits []Pres
 			}
 			return nil, fmt.Errorf("required prow jobs require branch protection")
 		}
-		ps := Policy{
-			RequiredStatusChecks: &ContextPolicy{
-				Contexts: prowContexts,
-			},
-		}
-		// Require protection by default if ProtectTested is true
-		if c.BranchProtection.ProtectTested != nil && *c.BranchProtection.ProtectTested {
-			yes := true
-			ps.Protect = &yes
-		}
 		policy = policy.Apply(ps)
 	}
 
@@ -508,6 +1064,120 @@ func (c *Config) GetPolicy(org, repo, branch string, b Branch, presubmits []Pres
 	return &policy, nil
 }
 
+// UnenforcedContextsWarning returns a warning if the policy returned by GetPolicy
+// requires contexts, but no level of the configuration sets protect, in which
+// case branchprotector leaves the branch alone and doesn't enforce them.
+// It returns an empty string otherwise.
+func UnenforcedContextsWarning(policy *Policy) string {
+	if policy == nil || policy.Protect != nil || policy.RequiredStatusChecks == nil || len(policy.RequiredStatusChecks.Contexts) == 0 {
+		return ""
+	}
+	return "required contexts are configured, but no level of the configuration sets protect, so branchprotector doesn't enforce them. " +
+		"Set 'protect: true' to enforce them, or 'protect-tested-repos: true' to protect all branches requiring prow jobs"
+}
+
+// presubmitPolicy returns the policy required by the presubmits of the branch
+// and the extra contexts, if any.
+func (c *Config) presubmitPolicy(branch string, presubmits []Presubmit, requireManuallyTriggeredJobs *bool, extraContexts []string) (Policy, bool) {
+	prowContexts, requiredIfPresentContexts, optionalContexts := BranchRequirements(branch, presubmits, requireManuallyTriggeredJobs)
+	if len(extraContexts) > 0 {
+		prowContexts = sets.List(sets.New[string](prowContexts...).Insert(extraContexts...))
+	}
+	if !c.shouldManageRequiredStatusCheck(prowContexts, requiredIfPresentContexts, optionalContexts) {
+		return Policy{}, false
+	}
+	ps := Policy{
+		RequiredStatusChecks: &ContextPolicy{
+			Contexts: prowContexts,
+		},
+	}
+	// Require protection by default if ProtectTested is true
+	if c.BranchProtection.ProtectTested != nil && *c.BranchProtection.ProtectTested {
+		yes := true
+		ps.Protect = &yes
+	}
+	return ps, true
+}
+
+// PolicyExplanation records which level of the branch protection config contributed
+// each setting of a merged policy. Levels are global, org, repo, branch and presubmits.
+type PolicyExplanation struct {
+	// Settings maps each defined setting, named by its yaml path
+	// (e.g. required_status_checks.strict), to the level that last set it.
+	Settings map[string]string
+	// Contexts maps each required context to the level that added it.
+	Contexts map[string]string
+}
+
+// ExplainBranchProtection returns the policy for a given branch like GetBranchProtection,
+// along with an explanation of which level contributed each of its settings.
+func (c *Config) ExplainBranchProtection(org, repo, branch string, presubmits []Presubmit) (*Policy, *PolicyExplanation, error) {
+	policy, err := c.GetBranchProtection(org, repo, branch, presubmits)
+	if err != nil || policy == nil {
+		return policy, nil, err
+	}
+
+	bp := c.BranchProtection
+	o := bp.Orgs[org]
+	r := o.Repos[repo]
+	b := r.Branches[branch]
+	levels := []namedPolicy{{"global", bp.Policy}, {"org", o.Policy}, {"repo", r.Policy}, {"branch", b.Policy}}
+	merged := bp.Apply(o.Policy).Apply(r.Policy).Apply(b.Policy)
+	if ps, ok := c.presubmitPolicy(branch, presubmits, merged.RequireManuallyTriggeredJobs, nil); ok {
+		levels = append(levels, namedPolicy{"presubmits", ps})
+	}
+
+	explanation := &PolicyExplanation{Settings: map[string]string{}, Contexts: map[string]string{}}
+	for _, l := range levels {
+		for _, setting := range l.policy.definedSettings() {
+			explanation.Settings[setting] = l.level
+		}
+		if l.policy.RequiredStatusChecks == nil {
+			continue
+		}
+		// Mirror mergeContexts: removals win over additions on the same level.
+		var removed []string
+		for _, context := range l.policy.RequiredStatusChecks.Contexts {
+			if strings.HasPrefix(context, contextRemovalPrefix) {
+				removed = append(removed, strings.TrimPrefix(context, contextRemovalPrefix))
+			} else if _, ok := explanation.Contexts[context]; !ok {
+				explanation.Contexts[context] = l.level
+			}
+		}
+		for _, context := range removed {
+			delete(explanation.Contexts, context)
+		}
+	}
+	return policy, explanation, nil
+}
+
+// definedSettings returns the yaml paths of the scalar settings defined by the policy.
+func (p Policy) definedSettings() []string {
+	var settings []string
+	add := func(setting string, defined bool) {
+		if defined {
+			settings = append(settings, setting)
+		}
+	}
+	add("unmanaged", p.Unmanaged != nil)
+	add("protect", p.Protect != nil)
+	add("enforce_admins", p.Admins != nil)
+	add("require_manually_triggered_jobs", p.RequireManuallyTriggeredJobs != nil)
+	add("required_linear_history", p.RequiredLinearHistory != nil)
+	add("required_signatures", p.RequiredSignatures != nil)
+	add("allow_force_pushes", p.AllowForcePushes != nil)
+	add("allow_deletions", p.AllowDeletions != nil)
+	if p.RequiredStatusChecks != nil {
+		add("required_status_checks.strict", p.RequiredStatusChecks.Strict != nil)
+	}
+	if r := p.RequiredPullRequestReviews; r != nil {
+		add("required_pull_request_reviews.dismiss_stale_reviews", r.DismissStale != nil)
+		add("required_pull_request_reviews.require_code_owner_reviews", r.RequireOwners != nil)
+		add("required_pull_request_reviews.required_approving_review_count", r.Approvals != nil)
+	}
+	return settings
+}
+
 func (c *Config) shouldManageRequiredStatusCheck(requiredContexts, requiredIfPresentContexts, optionalContexts []string) bool {
 	if len(requiredContexts) > 0 {
 		return true
@@ -546,6 +1216,98 @@ func (c *Config) reposWithDisabledPolicy() []string {
 	return sets.List(repoWarns)
 }
 
+// RepoProtectionState is whether branchprotector protects a configured repo.
+type RepoProtectionState string
+
+const (
+	// RepoProtected means protection is enabled for the repo.
+	RepoProtected RepoProtectionState = "protected"
+	// RepoUnprotected means protection is disabled or not enabled for the repo.
+	RepoUnprotected RepoProtectionState = "unprotected"
+	// RepoUnmanaged means the protection of the repo is unmanaged.
+	RepoUnmanaged RepoProtectionState = "unmanaged"
+)
+
+// CountReposByProtection returns how many of the configured repos are in each
+// protection state, after merging in the org and global policies, e.g. for
+// coverage metrics. Every state is present in the result. Archived repos are
+// not counted.
+func (bp BranchProtection) CountReposByProtection() map[RepoProtectionState]int {
+	counts := map[RepoProtectionState]int{RepoProtected: 0, RepoUnprotected: 0, RepoUnmanaged: 0}
+	for orgName, org := range bp.Orgs {
+		for repoName := range org.Repos {
+			repo := bp.GetOrg(orgName).GetRepo(repoName)
+			switch {
+			case repo.IsArchived():
+			case boolValFromPtr(repo.Unmanaged):
+				counts[RepoUnmanaged]++
+			case boolValFromPtr(repo.Protect):
+				counts[RepoProtected]++
+			default:
+				counts[RepoUnprotected]++
+			}
+		}
+	}
+	return counts
+}
+
+// CountBranchesByApprovals returns how many of the configured protected
+// branches require each number of approving reviews, after merging in the repo,
+// org and global policies, e.g. for governance metrics. Branches not requiring
+// reviews are counted as requiring 0 approvals. Branches of archived repos and
+// unmanaged branches are not counted.
+func (bp BranchProtection) CountBranchesByApprovals() map[int]int {
+	counts := map[int]int{}
+	for orgName, org := range bp.Orgs {
+		for repoName, repo := range org.Repos {
+			r := bp.GetOrg(orgName).GetRepo(repoName)
+			if r.IsArchived() {
+				continue
+			}
+			for branchName := range repo.Branches {
+				b, err := r.GetBranch(branchName)
+				if err != nil || boolValFromPtr(b.Unmanaged) || !boolValFromPtr(b.Protect) {
+					continue
+				}
+				var approvals int
+				if b.RequiredPullRequestReviews != nil && b.RequiredPullRequestReviews.Approvals != nil {
+					approvals = *b.RequiredPullRequestReviews.Approvals
+				}
+				counts[approvals]++
+			}
+		}
+	}
+	return counts
+}
+
+// StrictBranches returns the configured protected branches requiring status
+// checks to pass against the latest base branch, after merging in the repo, org
+// and global policies, e.g. to roll out strict mode. Branches are identified as
+// org/repo=branch and sorted. Branches of archived repos and unmanaged branches
+// are omitted.
+func (bp BranchProtection) StrictBranches() []string {
+	var strict []string
+	for orgName, org := range bp.Orgs {
+		for repoName, repo := range org.Repos {
+			r := bp.GetOrg(orgName).GetRepo(repoName)
+			if r.IsArchived() {
+				continue
+			}
+			for branchName := range repo.Branches {
+				b, err := r.GetBranch(branchName)
+				if err != nil || boolValFromPtr(b.Unmanaged) || !boolValFromPtr(b.Protect) {
+					continue
+				}
+				if b.RequiredStatusChecks != nil && boolValFromPtr(b.RequiredStatusChecks.Strict) {
+					strict = append(strict, fmt.Sprintf("%s/%s=%s", orgName, repoName, branchName))
+				}
+			}
+		}
+	}
+	sort.Strings(strict)
+	return strict
+}
+
 // boolValFromPtr returns the bool value from a bool pointer.
 // Nil counts as false. We need the boolpointers to be able
 // to differentiate unset from false in the serialization.
@@ -597,6 +1359,162 @@ func (c *Config) BranchProtectionWarnings(logger *logrus.Entry, presubmits map[s
 	}
 }
 
+// namedPolicy is a policy defined at a named level of the branch protection config.
+type namedPolicy struct {
+	level  string
+	policy Policy
+}
+
+// ProtectContradictions returns an error for each org, repo and branch where a child
+// sets protect: false over a parent's protect: true while the merged policy still defines
+// other settings. GetPolicy rejects such policies unless allow_disabled_policies is set.
+func (bp BranchProtection) ProtectContradictions() error {
+	if boolValFromPtr(bp.AllowDisabledPolicies) {
+		return nil
+	}
+	var errs []error
+	for _, orgName := range sets.List(sets.KeySet(bp.Orgs)) {
+		org := bp.Orgs[orgName]
+		levels := []namedPolicy{{"global", bp.Policy}, {"org", org.Policy}}
+		if err := protectContradiction(levels); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", orgName, err))
+		}
+		for _, repoName := range sets.List(sets.KeySet(org.Repos)) {
+			repo := org.Repos[repoName]
+			repoLevels := append(levels, namedPolicy{"repo", repo.Policy})
+			if err := protectContradiction(repoLevels); err != nil {
+				errs = append(errs, fmt.Errorf("%s/%s: %w", orgName, repoName, err))
+			}
+			for _, branchName := range sets.List(sets.KeySet(repo.Branches)) {
+				branchLevels := append(repoLevels, namedPolicy{"branch", repo.Branches[branchName].Policy})
+				if err := protectContradiction(branchLevels); err != nil {
+					errs = append(errs, fmt.Errorf("%s/%s=%s: %w", orgName, repoName, branchName, err))
+				}
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// ValidateBranchProtection returns an error for each problem keeping the branch
+// protection config from being applied: invalid include and exclude patterns,
+// branch policies setting neither protect nor unmanaged, invalid allowed merge
+// methods, linear histories that can't be satisfied and contradicting protect
+// settings. Problems are reported on the
+// level defining them.
+func ValidateBranchProtection(bp BranchProtection) error {
+	var errs []error
+	if len(bp.Include) > 0 && len(bp.Exclude) > 0 {
+		errs = append(errs, errors.New("global: include and exclude are mutually exclusive"))
+	}
+	if err := bp.Policy.validateBranchPatterns(); err != nil {
+		errs = append(errs, fmt.Errorf("global: %w", err))
+	}
+	for _, orgName := range sets.List(sets.KeySet(bp.Orgs)) {
+		org := bp.Orgs[orgName]
+		if err := org.Policy.validateBranchPatterns(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", orgName, err))
+		}
+		for _, repoName := range sets.List(sets.KeySet(org.Repos)) {
+			repo := bp.GetOrg(orgName).GetRepo(repoName)
+			if err := org.Repos[repoName].Policy.validateBranchPatterns(); err != nil {
+				errs = append(errs, fmt.Errorf("%s/%s: %w", orgName, repoName, err))
+			}
+			for _, branchName := range sets.List(sets.KeySet(repo.Branches)) {
+				if err := repo.Branches[branchName].Policy.validateBranchPatterns(); err != nil {
+					errs = append(errs, fmt.Errorf("%s/%s=%s: %w", orgName, repoName, branchName, err))
+				}
+				if _, err := repo.GetBranch(branchName); err != nil {
+					errs = append(errs, fmt.Errorf("%s/%s=%s: %w", orgName, repoName, branchName, err))
+				}
+			}
+		}
+	}
+	if err := bp.validateAllowedMergeMethods(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := bp.validateLinearHistory(nil); err != nil {
+		errs = append(errs, err)
+	}
+	if err := bp.ProtectContradictions(); err != nil {
+		errs = append(errs, err)
+	}
+	return utilerrors.Flatten(utilerrors.NewAggregate(errs))
+}
+
+// validateBranchPatterns returns an error if an include or exclude pattern of
+// the policy is not a valid regular expression.
+func (p Policy) validateBranchPatterns() error {
+	var errs []error
+	for _, pattern := range p.Include {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("invalid include pattern %q: %w", pattern, err))
+		}
+	}
+	for _, pattern := range p.Exclude {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("invalid exclude pattern %q: %w", pattern, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// protectContradiction merges the policies from the top level down and returns an error if
+// protect: true is overridden with protect: false while other settings are still defined.
+func protectContradiction(levels []namedPolicy) error {
+	var merged Policy
+	var enabledBy, disabledBy string
+	for _, l := range levels {
+		merged = merged.Apply(l.policy)
+		if l.policy.Protect == nil {
+			continue
+		}
+		if *l.policy.Protect {
+			enabledBy, disabledBy = l.level, ""
+		} else if enabledBy != "" {
+			disabledBy = l.level
+		}
+	}
+	if disabledBy == "" || boolValFromPtr(merged.Unmanaged) {
+		return nil
+	}
+	merged.Protect = nil
+	if !merged.defined() {
+		return nil
+	}
+	return fmt.Errorf("protect: false set by the %s policy overrides protect: true set by the %s policy, but other settings are still defined", disabledBy, enabledBy)
+}
+
+// LastObservedContexts returns the last time each context was reported by one of the given jobs.
+func LastObservedContexts(jobs []prowapi.ProwJob) map[string]time.Time {
+	observed := map[string]time.Time{}
+	for _, job := range jobs {
+		if job.Spec.Context == "" {
+			continue
+		}
+		if started := job.Status.StartTime.Time; started.After(observed[job.Spec.Context]) {
+			observed[job.Spec.Context] = started
+		}
+	}
+	return observed
+}
+
+// StaleRequiredContexts returns the sorted required contexts of the policy that were not
+// observed since the cutoff. Such contexts are likely orphaned and will block merges.
+// Contexts missing from lastObserved are stale.
+func (p Policy) StaleRequiredContexts(lastObserved map[string]time.Time, cutoff time.Time) []string {
+	if p.RequiredStatusChecks == nil {
+		return nil
+	}
+	stale := sets.New[string]()
+	for _, context := range p.RequiredStatusChecks.Contexts {
+		if observed, ok := lastObserved[context]; !ok || observed.Before(cutoff) {
+			stale.Insert(context)
+		}
+	}
+	return sets.List(stale)
+}
+
 // BranchRequirements partitions status contexts for a given org, repo branch into three buckets:
 //   - contexts that are always required to be present
 //   - contexts that are required, _if_ present
@@ -632,3 +1550,75 @@ func BranchRequirements(branch string, jobs []Presubmit, requireManuallyTriggere
 	}
 	return required, requiredIfPresent, optional
 }
+
+// ValidateBranchContexts returns an error listing the contexts that several of
+// the presubmits running against the branch report to, which makes the
+// requirements of the branch ambiguous, e.g. when inrepoconfig presubmits
+// reuse the context of a centrally configured one.
+func ValidateBranchContexts(branch string, jobs []Presubmit) error {
+	jobsByContext := map[string][]string{}
+	for _, j := range jobs {
+		if !j.CouldRun(branch) {
+			continue
+		}
+		jobsByContext[j.Context] = append(jobsByContext[j.Context], j.Name)
+	}
+	var errs []error
+	for _, context := range sets.List(sets.KeySet(jobsByContext)) {
+		if names := jobsByContext[context]; len(names) > 1 {
+			sort.Strings(names)
+			errs = append(errs, fmt.Errorf("jobs %s report to the same context %q on branch %s", strings.Join(names, ", "), context, branch))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// PullRequestRequirements returns the contexts that are required on a pull request
+// against the given branch that changes the given files. Unlike BranchRequirements,
+// which cannot know which files a pull request changes, contexts of jobs that run
+// conditionally on changed files are required exactly when the changes trigger them.
+func PullRequestRequirements(branch string, jobs []Presubmit, changes []string, requireManuallyTriggeredJobs *bool) []string {
+	var required []string
+	manuallyTriggeredJobs := requireManuallyTriggeredJobs != nil && *requireManuallyTriggeredJobs
+	for _, j := range jobs {
+		if !j.CouldRun(branch) || !j.ContextRequired() {
+			continue
+		}
+		switch {
+		case manuallyTriggeredJobs && j.NeedsExplicitTrigger():
+			required = append(required, j.Context)
+		case j.AlwaysRun:
+			required = append(required, j.Context)
+		case j.RegexpChangeMatcher.CouldRun():
+			if j.RegexpChangeMatcher.RunsAgainstChanges(changes) {
+				required = append(required, j.Context)
+			}
+		}
+	}
+	return required
+}
+
+// ContextAliases returns the context names that satisfy each required context of the
+// policy: the context itself and its aliases.
+func (p Policy) ContextAliases() map[string]sets.Set[string] {
+	if p.RequiredStatusChecks == nil {
+		return nil
+	}
+	aliases := make(map[string]sets.Set[string], len(p.RequiredStatusChecks.Contexts))
+	for _, context := range p.RequiredStatusChecks.Contexts {
+		aliases[context] = sets.New[string](context).Insert(p.RequiredStatusChecks.Aliases[context]...)
+	}
+	return aliases
+}
+
+// MissingRequiredContexts returns the sorted required contexts of the policy that are
+// not satisfied by any of the given contexts, either directly or through an alias.
+func (p Policy) MissingRequiredContexts(satisfied sets.Set[string]) []string {
+	missing := sets.New[string]()
+	for context, aliases := range p.ContextAliases() {
+		if aliases.Intersection(satisfied).Len() == 0 {
+			missing.Insert(context)
+		}
+	}
+	return sets.List(missing)
+}
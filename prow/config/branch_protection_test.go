@@ -20,10 +20,18 @@ import (
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/diff"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 	utilpointer "k8s.io/utils/pointer"
+
+	prowapi "sigs.k8s.io/prow/prow/apis/prowjobs/v1"
+	"sigs.k8s.io/prow/prow/git/types"
 )
 
 var (
@@ -159,6 +167,7 @@ func TestUnionStrings(t *testing.T) {
 func TestApply(test *testing.T) {
 	t := true
 	f := false
+	one := 1
 	basic := Policy{
 		Protect: &t,
 	}
@@ -184,6 +193,7 @@ func TestApply(test *testing.T) {
 			child: Policy{
 				Admins:                &f,
 				RequiredLinearHistory: &t,
+				RequiredSignatures:    &t,
 				AllowForcePushes:      &t,
 				AllowDeletions:        &t,
 			},
@@ -191,6 +201,7 @@ func TestApply(test *testing.T) {
 				Protect:               &t,
 				Admins:                &f,
 				RequiredLinearHistory: &t,
+				RequiredSignatures:    &t,
 				AllowForcePushes:      &t,
 				AllowDeletions:        &t,
 			},
@@ -225,6 +236,37 @@ func TestApply(test *testing.T) {
 				},
 			},
 		},
+		{
+			name: "add and remove contexts",
+			parent: Policy{
+				RequiredStatusChecks: &ContextPolicy{
+					Contexts: []string{"hello", "world"},
+				},
+			},
+			child: Policy{
+				RequiredStatusChecks: &ContextPolicy{
+					Contexts: []string{"-world", "of", "thrones", "-unknown"},
+				},
+			},
+			expected: Policy{
+				RequiredStatusChecks: &ContextPolicy{
+					Contexts: []string{"hello", "of", "thrones"},
+				},
+			},
+		},
+		{
+			name: "remove contexts without parent contexts",
+			child: Policy{
+				RequiredStatusChecks: &ContextPolicy{
+					Contexts: []string{"-world", "hello"},
+				},
+			},
+			expected: Policy{
+				RequiredStatusChecks: &ContextPolicy{
+					Contexts: []string{"hello"},
+				},
+			},
+		},
 		{
 			name: "merge struct",
 			parent: Policy{
@@ -278,6 +320,53 @@ func TestApply(test *testing.T) {
 				Protect: &t,
 			},
 		},
+		{
+			name: "append required approving teams",
+			parent: Policy{
+				RequiredPullRequestReviews: &ReviewPolicy{
+					RequiredApprovingTeams: &Restrictions{
+						Teams: []string{"security", "release"},
+					},
+				},
+			},
+			child: Policy{
+				RequiredPullRequestReviews: &ReviewPolicy{
+					RequiredApprovingTeams: &Restrictions{
+						Teams: []string{"api-reviewers", "security"},
+					},
+				},
+			},
+			expected: Policy{
+				RequiredPullRequestReviews: &ReviewPolicy{
+					RequiredApprovingTeams: &Restrictions{
+						Teams: []string{"api-reviewers", "release", "security"},
+					},
+				},
+			},
+		},
+		{
+			name: "inherit required approving teams",
+			parent: Policy{
+				RequiredPullRequestReviews: &ReviewPolicy{
+					RequiredApprovingTeams: &Restrictions{
+						Teams: []string{"security"},
+					},
+				},
+			},
+			child: Policy{
+				RequiredPullRequestReviews: &ReviewPolicy{
+					Approvals: &one,
+				},
+			},
+			expected: Policy{
+				RequiredPullRequestReviews: &ReviewPolicy{
+					Approvals: &one,
+					RequiredApprovingTeams: &Restrictions{
+						Teams: []string{"security"},
+					},
+				},
+			},
+		},
 		{
 			name: "merge exclusion strings",
 			child: Policy{
@@ -942,6 +1031,134 @@ func TestConfig_GetBranchProtection(t *testing.T) {
 			},
 			expected: nil,
 		},
+		{
+			name: "archived repo is not protected",
+			config: Config{
+				ProwConfig: ProwConfig{
+					BranchProtection: BranchProtection{
+						Orgs: map[string]Org{
+							"org": {
+								Policy: Policy{
+									Protect: yes,
+								},
+								Repos: map[string]Repo{
+									"repo": {
+										Archived: yes,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "branch inherits required signatures from org",
+			config: Config{
+				ProwConfig: ProwConfig{
+					BranchProtection: BranchProtection{
+						Orgs: map[string]Org{
+							"org": {
+								Policy: Policy{
+									Protect:            yes,
+									RequiredSignatures: yes,
+								},
+								Repos: map[string]Repo{
+									"repo": {
+										Branches: map[string]Branch{
+											"branch": {},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: &Policy{Protect: yes, RequiredSignatures: yes},
+		},
+		{
+			name: "repo overrides required signatures of org",
+			config: Config{
+				ProwConfig: ProwConfig{
+					BranchProtection: BranchProtection{
+						Orgs: map[string]Org{
+							"org": {
+								Policy: Policy{
+									Protect:            yes,
+									RequiredSignatures: yes,
+								},
+								Repos: map[string]Repo{
+									"repo": {
+										Policy: Policy{
+											RequiredSignatures: no,
+										},
+										Branches: map[string]Branch{
+											"branch": {},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: &Policy{Protect: yes, RequiredSignatures: no},
+		},
+		{
+			name: "branch overrides required signatures of repo",
+			config: Config{
+				ProwConfig: ProwConfig{
+					BranchProtection: BranchProtection{
+						Orgs: map[string]Org{
+							"org": {
+								Policy: Policy{
+									Protect:            yes,
+									RequiredSignatures: yes,
+								},
+								Repos: map[string]Repo{
+									"repo": {
+										Policy: Policy{
+											RequiredSignatures: no,
+										},
+										Branches: map[string]Branch{
+											"branch": {
+												Policy: Policy{
+													RequiredSignatures: yes,
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: &Policy{Protect: yes, RequiredSignatures: yes},
+		},
+		{
+			name: "repo explicitly not archived is protected",
+			config: Config{
+				ProwConfig: ProwConfig{
+					BranchProtection: BranchProtection{
+						Orgs: map[string]Org{
+							"org": {
+								Policy: Policy{
+									Protect: yes,
+								},
+								Repos: map[string]Repo{
+									"repo": {
+										Archived: no,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: &Policy{Protect: yes},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -1338,3 +1555,1783 @@ func TestUnprotectedBranches(t *testing.T) {
 		})
 	}
 }
+
+func TestProtectContradictions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   BranchProtection
+		expected string
+	}{
+		{
+			name: "no contradictions",
+			config: BranchProtection{
+				Policy: Policy{Protect: yes},
+				Orgs: map[string]Org{
+					"org": {
+						Repos: map[string]Repo{
+							"repo": {
+								Policy: Policy{Admins: yes},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "repo disables protection without other settings",
+			config: BranchProtection{
+				Orgs: map[string]Org{
+					"org": {
+						Policy: Policy{Protect: yes},
+						Repos: map[string]Repo{
+							"repo": {
+								Policy: Policy{Protect: no},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "repo disables protection with inherited settings",
+			config: BranchProtection{
+				Orgs: map[string]Org{
+					"org": {
+						Policy: Policy{
+							Protect:              yes,
+							RequiredStatusChecks: &ContextPolicy{Contexts: []string{"test"}},
+						},
+						Repos: map[string]Repo{
+							"repo": {
+								Policy: Policy{Protect: no},
+							},
+						},
+					},
+				},
+			},
+			expected: "org/repo: protect: false set by the repo policy overrides protect: true set by the org policy, but other settings are still defined",
+		},
+		{
+			name: "repo disables protection with its own settings",
+			config: BranchProtection{
+				Orgs: map[string]Org{
+					"org": {
+						Policy: Policy{Protect: yes},
+						Repos: map[string]Repo{
+							"repo": {
+								Policy: Policy{Protect: no, Admins: yes},
+							},
+						},
+					},
+				},
+			},
+			expected: "org/repo: protect: false set by the repo policy overrides protect: true set by the org policy, but other settings are still defined",
+		},
+		{
+			name: "branch disables protection enabled globally",
+			config: BranchProtection{
+				Policy: Policy{Protect: yes},
+				Orgs: map[string]Org{
+					"org": {
+						Repos: map[string]Repo{
+							"repo": {
+								Policy: Policy{Admins: yes},
+								Branches: map[string]Branch{
+									"master":  {Policy: Policy{Protect: no}},
+									"release": {Policy: Policy{Protect: yes}},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: "org/repo=master: protect: false set by the branch policy overrides protect: true set by the global policy, but other settings are still defined",
+		},
+		{
+			name: "branch re-enables protection",
+			config: BranchProtection{
+				Orgs: map[string]Org{
+					"org": {
+						Policy: Policy{Protect: yes, Admins: yes},
+						Repos: map[string]Repo{
+							"repo": {
+								Policy: Policy{Protect: no},
+								Branches: map[string]Branch{
+									"master": {Policy: Policy{Protect: yes}},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: "org/repo: protect: false set by the repo policy overrides protect: true set by the org policy, but other settings are still defined",
+		},
+		{
+			name: "unmanaged branches are ignored",
+			config: BranchProtection{
+				Orgs: map[string]Org{
+					"org": {
+						Policy: Policy{Protect: yes, Admins: yes},
+						Repos: map[string]Repo{
+							"repo": {
+								Branches: map[string]Branch{
+									"master": {Policy: Policy{Protect: no, Unmanaged: yes}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "disabled policies are allowed",
+			config: BranchProtection{
+				AllowDisabledPolicies: yes,
+				Orgs: map[string]Org{
+					"org": {
+						Policy: Policy{Protect: yes, Admins: yes},
+						Repos: map[string]Repo{
+							"repo": {
+								Policy: Policy{Protect: no},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var actual string
+			if err := tc.config.ProtectContradictions(); err != nil {
+				actual = err.Error()
+			}
+			if actual != tc.expected {
+				t.Errorf("expected error %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestStaleRequiredContexts(t *testing.T) {
+	now := time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)
+	cutoff := now.Add(-30 * 24 * time.Hour)
+	job := func(context string, started time.Time) prowapi.ProwJob {
+		return prowapi.ProwJob{
+			Spec:   prowapi.ProwJobSpec{Context: context},
+			Status: prowapi.ProwJobStatus{StartTime: metav1.NewTime(started)},
+		}
+	}
+	jobs := []prowapi.ProwJob{
+		job("fresh", now.Add(-time.Hour)),
+		job("recently-rerun", cutoff.Add(-time.Hour)),
+		job("recently-rerun", now.Add(-24*time.Hour)),
+		job("stale", cutoff.Add(-time.Hour)),
+		job("", now),
+	}
+
+	testCases := []struct {
+		name     string
+		policy   Policy
+		expected []string
+	}{
+		{
+			name: "no required contexts",
+		},
+		{
+			name: "fresh and stale contexts",
+			policy: Policy{
+				RequiredStatusChecks: &ContextPolicy{
+					Contexts: []string{"stale", "fresh", "never-seen", "recently-rerun"},
+				},
+			},
+			expected: []string{"never-seen", "stale"},
+		},
+		{
+			name: "only fresh contexts",
+			policy: Policy{
+				RequiredStatusChecks: &ContextPolicy{
+					Contexts: []string{"fresh", "recently-rerun"},
+				},
+			},
+			expected: []string{},
+		},
+	}
+
+	observed := LastObservedContexts(jobs)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := tc.policy.StaleRequiredContexts(observed, cutoff)
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				t.Errorf("stale contexts differ from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExplainBranchProtection(t *testing.T) {
+	presubmits := []Presubmit{
+		{
+			JobBase:   JobBase{Name: "required presubmit"},
+			Reporter:  Reporter{Context: "required presubmit"},
+			AlwaysRun: true,
+		},
+	}
+	testCases := []struct {
+		name     string
+		config   Config
+		expected *PolicyExplanation
+	}{
+		{
+			name: "branch in an unconfigured org has no explanation",
+			config: Config{
+				ProwConfig: ProwConfig{
+					BranchProtection: BranchProtection{
+						Orgs: map[string]Org{"other": {}},
+					},
+				},
+			},
+		},
+		{
+			name: "each setting and context is traced to its level",
+			config: Config{
+				ProwConfig: ProwConfig{
+					BranchProtection: BranchProtection{
+						Policy: Policy{
+							Protect: no,
+							RequiredStatusChecks: &ContextPolicy{
+								Contexts: []string{"global-context"},
+								Strict:   yes,
+							},
+						},
+						Orgs: map[string]Org{
+							"org": {
+								Policy: Policy{
+									Protect: yes,
+									Admins:  yes,
+									RequiredStatusChecks: &ContextPolicy{
+										Contexts: []string{"org-context", "removed-context"},
+									},
+								},
+								Repos: map[string]Repo{
+									"repo": {
+										Policy: Policy{
+											RequiredStatusChecks: &ContextPolicy{
+												Contexts: []string{"global-context", "-removed-context"},
+												Strict:   no,
+											},
+											RequiredPullRequestReviews: &ReviewPolicy{
+												Approvals: utilpointer.Int(2),
+											},
+										},
+										Branches: map[string]Branch{
+											"branch": {
+												Policy: Policy{Admins: no, Protect: yes},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: &PolicyExplanation{
+				Settings: map[string]string{
+					"protect":                       "branch",
+					"enforce_admins":                "branch",
+					"required_status_checks.strict": "repo",
+					"required_pull_request_reviews.required_approving_review_count": "repo",
+				},
+				Contexts: map[string]string{
+					"global-context":     "global",
+					"org-context":        "org",
+					"required presubmit": "presubmits",
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policy, explanation, err := tc.config.ExplainBranchProtection("org", "repo", "branch", presubmits)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expectedPolicy, err := tc.config.GetBranchProtection("org", "repo", "branch", presubmits)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(expectedPolicy, policy); diff != "" {
+				t.Errorf("policy differs from GetBranchProtection (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.expected, explanation); diff != "" {
+				t.Errorf("explanation differs from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateBranchContexts(t *testing.T) {
+	testCases := []struct {
+		name     string
+		branch   string
+		jobs     []Presubmit
+		expected string
+	}{
+		{
+			name:   "unique contexts",
+			branch: "master",
+			jobs: []Presubmit{
+				{JobBase: JobBase{Name: "unit"}, Reporter: Reporter{Context: "unit"}},
+				{JobBase: JobBase{Name: "e2e"}, Reporter: Reporter{Context: "e2e"}},
+			},
+		},
+		{
+			name:   "duplicate contexts",
+			branch: "master",
+			jobs: []Presubmit{
+				{JobBase: JobBase{Name: "unit"}, Reporter: Reporter{Context: "unit"}},
+				{JobBase: JobBase{Name: "unit-inrepo"}, Reporter: Reporter{Context: "unit"}},
+				{JobBase: JobBase{Name: "e2e"}, Reporter: Reporter{Context: "e2e"}},
+				{JobBase: JobBase{Name: "e2e-old"}, Reporter: Reporter{Context: "e2e"}},
+			},
+			expected: `[jobs e2e, e2e-old report to the same context "e2e" on branch master, jobs unit, unit-inrepo report to the same context "unit" on branch master]`,
+		},
+		{
+			name:   "duplicate contexts on other branches",
+			branch: "master",
+			jobs: []Presubmit{
+				{JobBase: JobBase{Name: "unit"}, Reporter: Reporter{Context: "unit"}, Brancher: Brancher{Branches: []string{"master"}}},
+				{JobBase: JobBase{Name: "unit-release"}, Reporter: Reporter{Context: "unit"}, Brancher: Brancher{Branches: []string{"release"}}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := SetPresubmitRegexes(tc.jobs); err != nil {
+				t.Fatalf("could not set regexes: %v", err)
+			}
+			var actual string
+			if err := ValidateBranchContexts(tc.branch, tc.jobs); err != nil {
+				actual = err.Error()
+			}
+			if actual != tc.expected {
+				t.Errorf("expected error %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPullRequestRequirements(t *testing.T) {
+	presubmits := []Presubmit{
+		{
+			AlwaysRun: true,
+			Reporter:  Reporter{Context: "always-run"},
+		},
+		{
+			RegexpChangeMatcher: RegexpChangeMatcher{RunIfChanged: "^docs/"},
+			Reporter:            Reporter{Context: "docs"},
+		},
+		{
+			RegexpChangeMatcher: RegexpChangeMatcher{SkipIfOnlyChanged: `\.md$`},
+			Reporter:            Reporter{Context: "unit"},
+		},
+		{
+			RegexpChangeMatcher: RegexpChangeMatcher{RunIfChanged: "^docs/"},
+			Reporter:            Reporter{Context: "docs-optional"},
+			Optional:            true,
+		},
+		{
+			RegexpChangeMatcher: RegexpChangeMatcher{RunIfChanged: "^docs/"},
+			Reporter:            Reporter{Context: "docs-other-branch"},
+			Brancher:            Brancher{Branches: []string{"other"}},
+		},
+		{
+			Reporter: Reporter{Context: "manual"},
+		},
+	}
+	if err := SetPresubmitRegexes(presubmits); err != nil {
+		t.Fatalf("could not set regexes: %v", err)
+	}
+
+	testCases := []struct {
+		name                         string
+		changes                      []string
+		requireManuallyTriggeredJobs *bool
+		expected                     []string
+	}{
+		{
+			name:     "no changes only require jobs that always run",
+			expected: []string{"always-run"},
+		},
+		{
+			name:     "docs changes require docs job but skip unit job",
+			changes:  []string{"docs/README.md"},
+			expected: []string{"always-run", "docs"},
+		},
+		{
+			name:     "code changes require unit job",
+			changes:  []string{"main.go", "README.md"},
+			expected: []string{"always-run", "unit"},
+		},
+		{
+			name:     "mixed changes require both",
+			changes:  []string{"docs/guide.md", "main.go"},
+			expected: []string{"always-run", "docs", "unit"},
+		},
+		{
+			name:                         "manually triggered jobs are required when configured",
+			changes:                      []string{"README.md"},
+			requireManuallyTriggeredJobs: yes,
+			expected:                     []string{"always-run", "manual"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := PullRequestRequirements("master", presubmits, tc.changes, tc.requireManuallyTriggeredJobs)
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				t.Errorf("required contexts differ from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestContextAliases(t *testing.T) {
+	cfg := Config{
+		ProwConfig: ProwConfig{
+			BranchProtection: BranchProtection{
+				Policy: Policy{
+					Protect: yes,
+					RequiredStatusChecks: &ContextPolicy{
+						Contexts: []string{"unit", "lint"},
+						Aliases:  map[string][]string{"lint": {"verify"}},
+					},
+				},
+				Orgs: map[string]Org{
+					"org": {
+						Repos: map[string]Repo{
+							"repo": {
+								Policy: Policy{
+									RequiredStatusChecks: &ContextPolicy{
+										Contexts: []string{"e2e"},
+										Aliases: map[string][]string{
+											"unit": {"unit-old"},
+											"lint": {"lint-old"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	policy, err := cfg.GetBranchProtection("org", "repo", "master", nil)
+	if err != nil {
+		t.Fatalf("Failed to get branch protection: %v", err)
+	}
+
+	expectedAliases := map[string]sets.Set[string]{
+		"e2e":  sets.New[string]("e2e"),
+		"lint": sets.New[string]("lint", "lint-old", "verify"),
+		"unit": sets.New[string]("unit", "unit-old"),
+	}
+	if diff := cmp.Diff(expectedAliases, policy.ContextAliases()); diff != "" {
+		t.Errorf("aliases differ from expected (-want +got):\n%s", diff)
+	}
+
+	testCases := []struct {
+		name      string
+		satisfied sets.Set[string]
+		expected  []string
+	}{
+		{
+			name:      "nothing reported",
+			satisfied: sets.New[string](),
+			expected:  []string{"e2e", "lint", "unit"},
+		},
+		{
+			name:      "canonical contexts satisfy themselves",
+			satisfied: sets.New[string]("e2e", "lint", "unit"),
+		},
+		{
+			name:      "aliases satisfy their contexts",
+			satisfied: sets.New[string]("e2e", "verify", "unit-old"),
+		},
+		{
+			name:      "aliases of other contexts don't count",
+			satisfied: sets.New[string]("lint-old", "unit-old"),
+			expected:  []string{"e2e"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.expected, policy.MissingRequiredContexts(tc.satisfied), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("missing contexts differ from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetRepoBranchProtections(t *testing.T) {
+	branches := []string{"main", "release-1.0", "release-1.0-rc", "feature", "legacy"}
+	protected := Policy{
+		Protect:              yes,
+		RequiredStatusChecks: &ContextPolicy{Contexts: []string{"unit"}},
+	}
+
+	testCases := []struct {
+		name     string
+		repo     Repo
+		org      string
+		expected map[string]*Policy
+	}{
+		{
+			name: "repo policy applies to every branch",
+			repo: Repo{Policy: protected},
+			expected: map[string]*Policy{
+				"main":           &protected,
+				"release-1.0":    &protected,
+				"release-1.0-rc": &protected,
+				"feature":        &protected,
+				"legacy":         &protected,
+			},
+		},
+		{
+			name: "included branches and configured branches",
+			repo: Repo{
+				Policy: Policy{
+					Protect:              yes,
+					RequiredStatusChecks: &ContextPolicy{Contexts: []string{"unit"}},
+					Include:              []string{"^main$", "^release-"},
+				},
+				Branches: map[string]Branch{
+					"legacy": {Policy: Policy{Protect: yes, Admins: yes}},
+				},
+			},
+			expected: map[string]*Policy{
+				"main": {
+					Protect:              yes,
+					RequiredStatusChecks: &ContextPolicy{Contexts: []string{"unit"}},
+					Include:              []string{"^main$", "^release-"},
+				},
+				"release-1.0": {
+					Protect:              yes,
+					RequiredStatusChecks: &ContextPolicy{Contexts: []string{"unit"}},
+					Include:              []string{"^main$", "^release-"},
+				},
+				"release-1.0-rc": {
+					Protect:              yes,
+					RequiredStatusChecks: &ContextPolicy{Contexts: []string{"unit"}},
+					Include:              []string{"^main$", "^release-"},
+				},
+				"legacy": {
+					Protect:              yes,
+					Admins:               yes,
+					RequiredStatusChecks: &ContextPolicy{Contexts: []string{"unit"}},
+					Include:              []string{"^main$", "^release-"},
+				},
+			},
+		},
+		{
+			name: "excluded and unmanaged branches are omitted",
+			repo: Repo{
+				Policy: Policy{
+					Protect: yes,
+					Exclude: []string{"-rc$"},
+				},
+				Branches: map[string]Branch{
+					"feature": {Policy: Policy{Unmanaged: yes}},
+				},
+			},
+			expected: map[string]*Policy{
+				"main":        {Protect: yes, Exclude: []string{"-rc$"}},
+				"release-1.0": {Protect: yes, Exclude: []string{"-rc$"}},
+				"legacy":      {Protect: yes, Exclude: []string{"-rc$"}},
+			},
+		},
+		{
+			name: "unmanaged repo protects no branches",
+			repo: Repo{Policy: Policy{Protect: yes, Unmanaged: yes}},
+		},
+		{
+			name: "unconfigured org protects no branches",
+			repo: Repo{Policy: protected},
+			org:  "other",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Config{
+				ProwConfig: ProwConfig{
+					BranchProtection: BranchProtection{
+						Orgs: map[string]Org{
+							"org": {Repos: map[string]Repo{"repo": tc.repo}},
+						},
+					},
+				},
+			}
+			org := tc.org
+			if org == "" {
+				org = "org"
+			}
+			actual, err := cfg.GetRepoBranchProtections(org, "repo", branches, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, actual, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("policies differ from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetBranchProtectionsMatching(t *testing.T) {
+	cfg := Config{
+		ProwConfig: ProwConfig{
+			BranchProtection: BranchProtection{
+				Orgs: map[string]Org{
+					"org": {Repos: map[string]Repo{"repo": {
+						Policy: Policy{
+							Protect:              yes,
+							RequiredStatusChecks: &ContextPolicy{Contexts: []string{"unit"}},
+						},
+						Branches: map[string]Branch{
+							"release-1.1": {Policy: Policy{Protect: yes, Admins: yes}},
+							"release-1.2": {Policy: Policy{Unmanaged: yes}},
+						},
+					}}},
+				},
+			},
+		},
+	}
+	branches := []string{"main", "release-1.0", "release-1.1", "release-1.2", "feature-release-1.0"}
+
+	testCases := []struct {
+		name     string
+		pattern  string
+		expected map[string]*Policy
+		err      bool
+	}{
+		{
+			name:    "pattern matching several branches with an exact override",
+			pattern: "^release-",
+			expected: map[string]*Policy{
+				"release-1.0": {
+					Protect:              yes,
+					RequiredStatusChecks: &ContextPolicy{Contexts: []string{"unit"}},
+				},
+				"release-1.1": {
+					Protect:              yes,
+					Admins:               yes,
+					RequiredStatusChecks: &ContextPolicy{Contexts: []string{"unit"}},
+				},
+			},
+		},
+		{
+			name:    "unanchored pattern",
+			pattern: "release-1.0",
+			expected: map[string]*Policy{
+				"release-1.0": {
+					Protect:              yes,
+					RequiredStatusChecks: &ContextPolicy{Contexts: []string{"unit"}},
+				},
+				"feature-release-1.0": {
+					Protect:              yes,
+					RequiredStatusChecks: &ContextPolicy{Contexts: []string{"unit"}},
+				},
+			},
+		},
+		{
+			name:    "pattern matching no branches",
+			pattern: "^dev$",
+		},
+		{
+			name:    "invalid pattern",
+			pattern: "release-(",
+			err:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := cfg.GetBranchProtectionsMatching("org", "repo", tc.pattern, branches, nil)
+			if tc.err != (err != nil) {
+				t.Fatalf("expected error %t, got %v", tc.err, err)
+			}
+			if diff := cmp.Diff(tc.expected, actual, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("policies differ from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRequiredContextsByOrg(t *testing.T) {
+	cfg := Config{
+		ProwConfig: ProwConfig{
+			BranchProtection: BranchProtection{
+				Orgs: map[string]Org{
+					"org": {
+						Policy: Policy{Protect: yes, RequiredStatusChecks: &ContextPolicy{Contexts: []string{"cla"}}},
+						Repos: map[string]Repo{
+							"api": {
+								Policy: Policy{RequiredStatusChecks: &ContextPolicy{Contexts: []string{"unit"}}},
+								Branches: map[string]Branch{
+									"release": {Policy: Policy{RequiredStatusChecks: &ContextPolicy{Contexts: []string{"e2e"}}}},
+								},
+							},
+							"web": {
+								Policy: Policy{RequiredStatusChecks: &ContextPolicy{Contexts: []string{"unit", "lint"}}},
+							},
+							"old": {
+								Policy:   Policy{RequiredStatusChecks: &ContextPolicy{Contexts: []string{"legacy"}}},
+								Archived: yes,
+							},
+							"docs": {
+								Policy: Policy{Unmanaged: yes, RequiredStatusChecks: &ContextPolicy{Contexts: []string{"docs"}}},
+							},
+						},
+					},
+					"other": {Policy: Policy{Protect: yes}},
+				},
+			},
+		},
+	}
+	presubmits := map[string][]Presubmit{
+		"org/cli": {{
+			JobBase:   JobBase{Name: "build"},
+			Reporter:  Reporter{Context: "build"},
+			AlwaysRun: true,
+		}},
+	}
+
+	testCases := []struct {
+		name     string
+		branches map[string][]string
+		expected map[string]sets.Set[string]
+	}{
+		{
+			name: "configured branches only",
+			expected: map[string]sets.Set[string]{
+				"org": sets.New[string]("cla", "unit", "e2e"),
+			},
+		},
+		{
+			name: "listed branches",
+			branches: map[string][]string{
+				"org/api":   {"main"},
+				"org/web":   {"main", "release"},
+				"org/old":   {"main"},
+				"org/docs":  {"main"},
+				"org/cli":   {"main"},
+				"other/foo": {"main"},
+			},
+			expected: map[string]sets.Set[string]{
+				"org": sets.New[string]("cla", "unit", "e2e", "lint", "build"),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := cfg.RequiredContextsByOrg(tc.branches, presubmits)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				t.Errorf("contexts differ from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExportRequiredContexts(t *testing.T) {
+	cfg := Config{
+		ProwConfig: ProwConfig{
+			BranchProtection: BranchProtection{
+				Orgs: map[string]Org{
+					"org": {
+						Policy: Policy{Protect: yes, RequiredStatusChecks: &ContextPolicy{Contexts: []string{"cla"}}},
+						Repos: map[string]Repo{
+							"api": {
+								Branches: map[string]Branch{
+									"release": {Policy: Policy{RequiredStatusChecks: &ContextPolicy{Contexts: []string{"e2e"}}}},
+								},
+							},
+							"old": {Archived: yes},
+							"dev": {Policy: Policy{Protect: no}},
+						},
+					},
+				},
+			},
+		},
+	}
+	presubmits := map[string][]Presubmit{
+		"org/api": {
+			{
+				JobBase:   JobBase{Name: "unit"},
+				Reporter:  Reporter{Context: "unit"},
+				AlwaysRun: true,
+			},
+			{
+				JobBase:             JobBase{Name: "docs"},
+				Reporter:            Reporter{Context: "docs"},
+				RegexpChangeMatcher: RegexpChangeMatcher{RunIfChanged: "^docs/"},
+			},
+			{
+				JobBase:   JobBase{Name: "lint"},
+				Reporter:  Reporter{Context: "lint"},
+				AlwaysRun: true,
+				Optional:  true,
+			},
+		},
+	}
+	if err := SetPresubmitRegexes(presubmits["org/api"]); err != nil {
+		t.Fatalf("failed to set presubmit regexes: %v", err)
+	}
+
+	expected := RequiredContexts{
+		"org": {
+			"api": {
+				"main": {
+					Required:          []string{"cla", "unit"},
+					RequiredIfPresent: []string{"docs"},
+					Optional:          []string{"lint"},
+				},
+				"release": {
+					Required:          []string{"cla", "e2e", "unit"},
+					RequiredIfPresent: []string{"docs"},
+					Optional:          []string{"lint"},
+				},
+			},
+			"web": {
+				"main": {Required: []string{"cla"}},
+			},
+		},
+	}
+	actual, err := cfg.ExportRequiredContexts(map[string][]string{
+		"org/api": {"main"},
+		"org/web": {"main"},
+		"org/old": {"main"},
+		"org/dev": {"main"},
+	}, presubmits)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("contexts differ from expected (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetBranchProtectionWithReason(t *testing.T) {
+	cfg := Config{
+		ProwConfig: ProwConfig{
+			BranchProtection: BranchProtection{
+				Orgs: map[string]Org{
+					"org": {
+						Repos: map[string]Repo{
+							"protected": {
+								Policy: Policy{Protect: yes, Exclude: []string{"^dev-"}},
+								Branches: map[string]Branch{
+									"disabled":  {Policy: Policy{Protect: no}},
+									"unmanaged": {Policy: Policy{Unmanaged: yes}},
+								},
+							},
+							"included": {
+								Policy: Policy{Protect: yes, Include: []string{"^release-"}},
+							},
+							"archived": {
+								Policy:   Policy{Protect: yes},
+								Archived: yes,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name           string
+		org, repo      string
+		branch         string
+		expectPolicy   bool
+		expectedReason SkipReason
+	}{
+		{
+			name:         "protected branch",
+			org:          "org",
+			repo:         "protected",
+			branch:       "main",
+			expectPolicy: true,
+		},
+		{
+			name:           "org not configured",
+			org:            "other",
+			repo:           "protected",
+			branch:         "main",
+			expectedReason: SkipReasonOrgNotConfigured,
+		},
+		{
+			name:           "excluded by pattern",
+			org:            "org",
+			repo:           "protected",
+			branch:         "dev-feature",
+			expectedReason: SkipReasonExcluded,
+		},
+		{
+			name:           "archived repo",
+			org:            "org",
+			repo:           "archived",
+			branch:         "main",
+			expectedReason: SkipReasonArchived,
+		},
+		{
+			name:           "not included by pattern",
+			org:            "org",
+			repo:           "included",
+			branch:         "main",
+			expectedReason: SkipReasonExcluded,
+		},
+		{
+			name:         "included by pattern",
+			org:          "org",
+			repo:         "included",
+			branch:       "release-1.0",
+			expectPolicy: true,
+		},
+		{
+			name:           "explicitly disabled",
+			org:            "org",
+			repo:           "protected",
+			branch:         "disabled",
+			expectedReason: SkipReasonDisabled,
+		},
+		{
+			name:           "unmanaged",
+			org:            "org",
+			repo:           "protected",
+			branch:         "unmanaged",
+			expectedReason: SkipReasonUnmanaged,
+		},
+		{
+			name:           "no policy",
+			org:            "org",
+			repo:           "unconfigured",
+			branch:         "main",
+			expectedReason: SkipReasonNoPolicy,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policy, reason, err := cfg.GetBranchProtectionWithReason(tc.org, tc.repo, tc.branch, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (policy != nil) != tc.expectPolicy {
+				t.Errorf("expected a policy: %t, got %+v", tc.expectPolicy, policy)
+			}
+			if reason != tc.expectedReason {
+				t.Errorf("expected reason %q, got %q", tc.expectedReason, reason)
+			}
+		})
+	}
+}
+
+func TestReviewDismissalProblems(t *testing.T) {
+	zero, two := 0, 2
+	testCases := []struct {
+		name     string
+		policy   Policy
+		expected []string
+	}{
+		{
+			name:   "no review settings",
+			policy: Policy{Protect: yes},
+		},
+		{
+			name: "approvals dismissed on new commits",
+			policy: Policy{
+				Protect:                    yes,
+				RequiredPullRequestReviews: &ReviewPolicy{Approvals: &two, DismissStale: yes},
+			},
+		},
+		{
+			name: "dismissing stale reviews without required approvals",
+			policy: Policy{
+				Protect:                    yes,
+				RequiredPullRequestReviews: &ReviewPolicy{DismissStale: yes},
+			},
+			expected: []string{"dismiss_stale_reviews has no effect without required_approving_review_count"},
+		},
+		{
+			name: "dismissing stale reviews with zero required approvals",
+			policy: Policy{
+				Protect:                    yes,
+				RequiredPullRequestReviews: &ReviewPolicy{Approvals: &zero, DismissStale: yes},
+			},
+			expected: []string{"dismiss_stale_reviews has no effect without required_approving_review_count"},
+		},
+		{
+			name: "strict status checks dismiss approvals",
+			policy: Policy{
+				Protect:                    yes,
+				RequiredStatusChecks:       &ContextPolicy{Strict: yes},
+				RequiredPullRequestReviews: &ReviewPolicy{Approvals: &two, DismissStale: yes},
+			},
+			expected: []string{"strict status checks require updating PRs with their base branch, which dismisses their 2 required approvals with dismiss_stale_reviews"},
+		},
+		{
+			name: "strict status checks keep approvals",
+			policy: Policy{
+				Protect:                    yes,
+				RequiredStatusChecks:       &ContextPolicy{Strict: yes},
+				RequiredPullRequestReviews: &ReviewPolicy{Approvals: &two, DismissStale: no},
+			},
+		},
+		{
+			name: "unprotected branch",
+			policy: Policy{
+				Protect:                    no,
+				RequiredStatusChecks:       &ContextPolicy{Strict: yes},
+				RequiredPullRequestReviews: &ReviewPolicy{Approvals: &two, DismissStale: yes},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.expected, tc.policy.ReviewDismissalProblems()); diff != "" {
+				t.Errorf("problems differ from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAdminsBypassStrictChecks(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   Policy
+		expected bool
+	}{
+		{
+			name:   "no status checks",
+			policy: Policy{Protect: yes},
+		},
+		{
+			name: "lenient status checks",
+			policy: Policy{
+				Protect:              yes,
+				RequiredStatusChecks: &ContextPolicy{Contexts: []string{"test"}, Strict: no},
+			},
+		},
+		{
+			name: "strict status checks without enforce_admins",
+			policy: Policy{
+				Protect:              yes,
+				RequiredStatusChecks: &ContextPolicy{Strict: yes},
+			},
+			expected: true,
+		},
+		{
+			name: "strict status checks with enforce_admins: false",
+			policy: Policy{
+				Protect:              yes,
+				RequiredStatusChecks: &ContextPolicy{Strict: yes},
+				Admins:               no,
+			},
+			expected: true,
+		},
+		{
+			name: "strict status checks enforced for admins",
+			policy: Policy{
+				Protect:              yes,
+				RequiredStatusChecks: &ContextPolicy{Strict: yes},
+				Admins:               yes,
+			},
+		},
+		{
+			name: "unprotected branch",
+			policy: Policy{
+				Protect:              no,
+				RequiredStatusChecks: &ContextPolicy{Strict: yes},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := tc.policy.AdminsBypassStrictChecks(); actual != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPolicyNormalize(t *testing.T) {
+	two := 2
+	testCases := []struct {
+		name       string
+		a, b       Policy
+		equivalent bool
+	}{
+		{
+			name: "list order and duplicates",
+			a: Policy{
+				Protect:              yes,
+				RequiredStatusChecks: &ContextPolicy{Contexts: []string{"b", "a", "b"}, Aliases: map[string][]string{"a": {"y", "x"}}},
+				Restrictions:         &Restrictions{Users: []string{"bob", "alice"}},
+				RequiredPullRequestReviews: &ReviewPolicy{
+					Approvals:             &two,
+					DismissalRestrictions: &DismissalRestrictions{Teams: []string{"b", "a"}},
+				},
+				Exclude: []string{"^b", "^a"},
+			},
+			b: Policy{
+				Protect:              yes,
+				RequiredStatusChecks: &ContextPolicy{Contexts: []string{"a", "b"}, Aliases: map[string][]string{"a": {"x", "y"}}},
+				Restrictions:         &Restrictions{Users: []string{"alice", "bob"}},
+				RequiredPullRequestReviews: &ReviewPolicy{
+					Approvals:             &two,
+					DismissalRestrictions: &DismissalRestrictions{Teams: []string{"a", "b"}},
+				},
+				Exclude: []string{"^a", "^b"},
+			},
+			equivalent: true,
+		},
+		{
+			name: "empty and nil lists",
+			a: Policy{
+				RequiredStatusChecks: &ContextPolicy{Contexts: []string{}, Aliases: map[string][]string{"a": {}}},
+				Restrictions:         &Restrictions{Apps: []string{}, Users: []string{}, Teams: []string{}},
+				Include:              []string{},
+			},
+			b: Policy{
+				RequiredStatusChecks: &ContextPolicy{},
+				Restrictions:         &Restrictions{},
+			},
+			equivalent: true,
+		},
+		{
+			name: "empty restrictions differ from none",
+			a:    Policy{Restrictions: &Restrictions{}},
+			b:    Policy{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			diff := cmp.Diff(tc.a.Normalize(), tc.b.Normalize())
+			if tc.equivalent && diff != "" {
+				t.Errorf("normalized policies differ (-a +b):\n%s", diff)
+			}
+			if !tc.equivalent && diff == "" {
+				t.Error("normalized policies are equal, expected them to differ")
+			}
+		})
+	}
+}
+
+func TestPolicyNormalizeDoesNotMutate(t *testing.T) {
+	p := Policy{
+		RequiredStatusChecks: &ContextPolicy{Contexts: []string{"b", "a"}},
+		Exclude:              []string{"^b", "^a"},
+	}
+	p.Normalize()
+	if diff := cmp.Diff([]string{"b", "a"}, p.RequiredStatusChecks.Contexts); diff != "" {
+		t.Errorf("contexts mutated (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"^b", "^a"}, p.Exclude); diff != "" {
+		t.Errorf("exclude mutated (-want +got):\n%s", diff)
+	}
+}
+
+func TestPolicyHash(t *testing.T) {
+	one, two := 1, 2
+	base := Policy{
+		Protect:                    yes,
+		RequiredStatusChecks:       &ContextPolicy{Contexts: []string{"b", "a"}, Strict: yes},
+		Restrictions:               &Restrictions{Teams: []string{"admins", "leads"}},
+		RequiredPullRequestReviews: &ReviewPolicy{Approvals: &one},
+	}
+	if hash := base.Hash(); hash != base.Hash() {
+		t.Errorf("hash is not stable: %s != %s", hash, base.Hash())
+	}
+	testCases := []struct {
+		name   string
+		policy Policy
+		same   bool
+	}{
+		{
+			name: "equivalent policy",
+			policy: Policy{
+				Protect:                    yes,
+				RequiredStatusChecks:       &ContextPolicy{Contexts: []string{"a", "b", "a"}, Strict: yes, Aliases: map[string][]string{"a": {}}},
+				Restrictions:               &Restrictions{Apps: []string{}, Teams: []string{"leads", "admins"}},
+				RequiredPullRequestReviews: &ReviewPolicy{Approvals: &one},
+				Include:                    []string{},
+			},
+			same: true,
+		},
+		{
+			name: "different contexts",
+			policy: Policy{
+				Protect:                    yes,
+				RequiredStatusChecks:       &ContextPolicy{Contexts: []string{"a", "c"}, Strict: yes},
+				Restrictions:               &Restrictions{Teams: []string{"admins", "leads"}},
+				RequiredPullRequestReviews: &ReviewPolicy{Approvals: &one},
+			},
+		},
+		{
+			name: "different approvals",
+			policy: Policy{
+				Protect:                    yes,
+				RequiredStatusChecks:       &ContextPolicy{Contexts: []string{"a", "b"}, Strict: yes},
+				Restrictions:               &Restrictions{Teams: []string{"admins", "leads"}},
+				RequiredPullRequestReviews: &ReviewPolicy{Approvals: &two},
+			},
+		},
+		{
+			name: "unset strict",
+			policy: Policy{
+				Protect:                    yes,
+				RequiredStatusChecks:       &ContextPolicy{Contexts: []string{"a", "b"}},
+				Restrictions:               &Restrictions{Teams: []string{"admins", "leads"}},
+				RequiredPullRequestReviews: &ReviewPolicy{Approvals: &one},
+			},
+		},
+		{
+			name: "no restrictions",
+			policy: Policy{
+				Protect:                    yes,
+				RequiredStatusChecks:       &ContextPolicy{Contexts: []string{"a", "b"}, Strict: yes},
+				RequiredPullRequestReviews: &ReviewPolicy{Approvals: &one},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if same := tc.policy.Hash() == base.Hash(); same != tc.same {
+				t.Errorf("expected equal hashes to be %t, got %t", tc.same, same)
+			}
+		})
+	}
+}
+
+func TestGetPolicyContextSources(t *testing.T) {
+	presubmits := []Presubmit{{AlwaysRun: true, Reporter: Reporter{Context: "presubmit"}}}
+	periodicContexts := func(org, repo, branch string) []string {
+		if org == "org" && repo == "repo" && branch == "main" {
+			return []string{"periodic", "presubmit"}
+		}
+		return nil
+	}
+	testCases := []struct {
+		name       string
+		branch     string
+		presubmits []Presubmit
+		sources    []ContextSource
+		expected   *Policy
+	}{
+		{
+			name:       "no context sources",
+			branch:     "main",
+			presubmits: presubmits,
+			expected:   &Policy{Protect: yes, RequiredStatusChecks: &ContextPolicy{Contexts: []string{"presubmit"}}},
+		},
+		{
+			name:       "contexts of presubmits and context sources are required",
+			branch:     "main",
+			presubmits: presubmits,
+			sources:    []ContextSource{periodicContexts},
+			expected:   &Policy{Protect: yes, RequiredStatusChecks: &ContextPolicy{Contexts: []string{"periodic", "presubmit"}}},
+		},
+		{
+			name:     "contexts of context sources are required without presubmits",
+			branch:   "main",
+			sources:  []ContextSource{periodicContexts},
+			expected: &Policy{Protect: yes, RequiredStatusChecks: &ContextPolicy{Contexts: []string{"periodic", "presubmit"}}},
+		},
+		{
+			name:    "context sources without contexts for the branch",
+			branch:  "release",
+			sources: []ContextSource{periodicContexts},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Config{ProwConfig: ProwConfig{BranchProtection: BranchProtection{ProtectTested: yes}}}
+			policy, err := c.GetPolicy("org", "repo", tc.branch, Branch{}, tc.presubmits, nil, tc.sources...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.expected, policy); diff != "" {
+				t.Errorf("policy differs (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUnenforcedContextsWarning(t *testing.T) {
+	presubmits := []Presubmit{{AlwaysRun: true, Reporter: Reporter{Context: "presubmit"}}}
+	testCases := []struct {
+		name       string
+		bp         BranchProtection
+		branch     Policy
+		presubmits []Presubmit
+		warned     bool
+	}{
+		{
+			name:   "contexts of the config without protect",
+			branch: Policy{RequiredStatusChecks: &ContextPolicy{Contexts: []string{"config"}}},
+			warned: true,
+		},
+		{
+			name:       "contexts of presubmits without protect",
+			presubmits: presubmits,
+			warned:     true,
+		},
+		{
+			name:       "contexts of presubmits with protect-tested-repos",
+			bp:         BranchProtection{ProtectTested: yes},
+			presubmits: presubmits,
+		},
+		{
+			name:   "contexts with protect",
+			branch: Policy{Protect: yes, RequiredStatusChecks: &ContextPolicy{Contexts: []string{"config"}}},
+		},
+		{
+			name:   "no contexts without protect",
+			branch: Policy{Admins: yes},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Config{ProwConfig: ProwConfig{BranchProtection: tc.bp}}
+			policy, err := c.GetPolicy("org", "repo", "main", Branch{Policy: tc.branch}, tc.presubmits, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if warned := UnenforcedContextsWarning(policy) != ""; warned != tc.warned {
+				t.Errorf("expected warned to be %t, got %t", tc.warned, warned)
+			}
+		})
+	}
+}
+
+func TestValidateBranchProtection(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   BranchProtection
+		expected []string
+	}{
+		{
+			name: "valid config",
+			config: BranchProtection{
+				Policy: Policy{Protect: yes, Include: []string{"^main$"}},
+				Orgs: map[string]Org{
+					"org": {
+						Repos: map[string]Repo{
+							"repo": {
+								Policy:   Policy{Exclude: []string{"^dev-"}},
+								Branches: map[string]Branch{"main": {Policy: Policy{Admins: yes}}},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:     "global include and exclude",
+			config:   BranchProtection{Policy: Policy{Include: []string{"^main$"}, Exclude: []string{"^dev-"}}},
+			expected: []string{"global: include and exclude are mutually exclusive"},
+		},
+		{
+			name: "invalid patterns and branch policies",
+			config: BranchProtection{
+				Orgs: map[string]Org{
+					"org": {
+						Repos: map[string]Repo{
+							"repo": {
+								Policy:   Policy{Exclude: []string{"["}},
+								Branches: map[string]Branch{"main": {Policy: Policy{Admins: yes}}},
+							},
+						},
+					},
+				},
+			},
+			expected: []string{
+				"org/repo: invalid exclude pattern \"[\": error parsing regexp: missing closing ]: `[`",
+				"org/repo=main: defined branch policies must set protect or unmanaged=true",
+			},
+		},
+		{
+			name: "invalid allowed merge methods",
+			config: BranchProtection{
+				Orgs: map[string]Org{
+					"org": {
+						Repos: map[string]Repo{
+							"duplicate": {AllowedMergeMethods: []types.PullRequestMergeType{types.MergeSquash, types.MergeSquash}},
+							"none":      {AllowedMergeMethods: []types.PullRequestMergeType{}},
+							"unknown":   {AllowedMergeMethods: []types.PullRequestMergeType{types.MergeIfNecessary}},
+						},
+					},
+				},
+			},
+			expected: []string{
+				"org/duplicate: allowed_merge_methods: duplicate merge method \"squash\"",
+				"org/none: allowed_merge_methods must allow at least one merge method",
+				"org/unknown: allowed_merge_methods: unknown merge method \"ifNecessary\", expected one of merge, rebase or squash",
+			},
+		},
+		{
+			name: "contradicting protect settings",
+			config: BranchProtection{
+				Orgs: map[string]Org{
+					"org": {
+						Policy: Policy{Protect: yes, Admins: yes},
+						Repos:  map[string]Repo{"repo": {Policy: Policy{Protect: no}}},
+					},
+				},
+			},
+			expected: []string{"org/repo: protect: false set by the repo policy overrides protect: true set by the org policy, but other settings are still defined"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var actual []string
+			if err := ValidateBranchProtection(tc.config); err != nil {
+				for _, err := range err.(utilerrors.Aggregate).Errors() {
+					actual = append(actual, err.Error())
+				}
+			}
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				t.Errorf("errors differ from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateLinearHistory(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   BranchProtection
+		tide     *Tide
+		expected []string
+	}{
+		{
+			name: "linear history with squash allowed",
+			config: BranchProtection{
+				Orgs: map[string]Org{
+					"org": {
+						Policy: Policy{RequiredLinearHistory: yes},
+						Repos: map[string]Repo{
+							"repo": {AllowedMergeMethods: []types.PullRequestMergeType{types.MergeMerge, types.MergeSquash}},
+						},
+					},
+				},
+			},
+			tide: &Tide{MergeType: map[string]TideOrgMergeType{"org": {MergeType: types.MergeRebase}}},
+		},
+		{
+			name: "linear history with only merge commits allowed",
+			config: BranchProtection{
+				Orgs: map[string]Org{
+					"org": {
+						Policy: Policy{RequiredLinearHistory: yes},
+						Repos: map[string]Repo{
+							"repo": {
+								AllowedMergeMethods: []types.PullRequestMergeType{types.MergeMerge},
+								Branches: map[string]Branch{
+									"main": {Policy: Policy{Protect: yes}},
+									"dev":  {Policy: Policy{Protect: yes, RequiredLinearHistory: no}},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: []string{
+				"org/repo: required_linear_history conflicts with allowed_merge_methods, which only allow merge commits",
+				"org/repo=main: required_linear_history conflicts with allowed_merge_methods, which only allow merge commits",
+			},
+		},
+		{
+			name: "linear history with tide merging branch with merge commits",
+			config: BranchProtection{
+				Orgs: map[string]Org{
+					"org": {
+						Repos: map[string]Repo{
+							"repo": {
+								Branches: map[string]Branch{
+									"main": {Policy: Policy{Protect: yes, RequiredLinearHistory: yes}},
+								},
+							},
+						},
+					},
+				},
+			},
+			tide: &Tide{MergeType: map[string]TideOrgMergeType{
+				"org":               {MergeType: types.MergeSquash},
+				"org/repo@main":     {MergeType: types.MergeMerge},
+				"org/other@release": {MergeType: types.MergeMerge},
+			}},
+			expected: []string{"org/repo=main: required_linear_history conflicts with the tide merge method, which is merge"},
+		},
+		{
+			name: "linear history without tide merge method",
+			config: BranchProtection{
+				Orgs: map[string]Org{
+					"org": {
+						Policy: Policy{RequiredLinearHistory: yes},
+						Repos: map[string]Repo{
+							"repo": {},
+						},
+					},
+				},
+			},
+			tide: &Tide{MergeType: map[string]TideOrgMergeType{"other": {MergeType: types.MergeMerge}}},
+		},
+		{
+			name: "linear history of archived repo",
+			config: BranchProtection{
+				Orgs: map[string]Org{
+					"org": {
+						Policy: Policy{RequiredLinearHistory: yes},
+						Repos: map[string]Repo{
+							"repo": {Archived: yes, AllowedMergeMethods: []types.PullRequestMergeType{types.MergeMerge}},
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var actual []string
+			if err := tc.config.validateLinearHistory(tc.tide); err != nil {
+				for _, err := range err.(utilerrors.Aggregate).Errors() {
+					actual = append(actual, err.Error())
+				}
+			}
+			if diff := cmp.Diff(tc.expected, actual); diff != "" {
+				t.Errorf("errors differ from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetAllowedMergeMethods(t *testing.T) {
+	bp := BranchProtection{
+		Orgs: map[string]Org{
+			"org": {
+				Repos: map[string]Repo{
+					"squash": {AllowedMergeMethods: []types.PullRequestMergeType{types.MergeSquash}},
+					"archived": {
+						Archived:            yes,
+						AllowedMergeMethods: []types.PullRequestMergeType{types.MergeSquash},
+					},
+					"unset": {},
+				},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name      string
+		org, repo string
+		expected  []types.PullRequestMergeType
+	}{
+		{
+			name:     "configured methods",
+			org:      "org",
+			repo:     "squash",
+			expected: []types.PullRequestMergeType{types.MergeSquash},
+		},
+		{
+			name: "archived repo",
+			org:  "org",
+			repo: "archived",
+		},
+		{
+			name: "unset methods",
+			org:  "org",
+			repo: "unset",
+		},
+		{
+			name: "unconfigured repo",
+			org:  "other",
+			repo: "repo",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.expected, bp.GetAllowedMergeMethods(tc.org, tc.repo)); diff != "" {
+				t.Errorf("merge methods differ from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCountReposByProtection(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   BranchProtection
+		expected map[RepoProtectionState]int
+	}{
+		{
+			name:     "no repos",
+			expected: map[RepoProtectionState]int{RepoProtected: 0, RepoUnprotected: 0, RepoUnmanaged: 0},
+		},
+		{
+			name: "mixed config",
+			config: BranchProtection{
+				Policy: Policy{Protect: yes},
+				Orgs: map[string]Org{
+					"protected-org": {
+						Repos: map[string]Repo{
+							"inherited": {},
+							"explicit":  {Policy: Policy{Protect: yes}},
+							"disabled":  {Policy: Policy{Protect: no}},
+							"unmanaged": {Policy: Policy{Unmanaged: yes}},
+							"archived":  {Archived: yes},
+						},
+					},
+					"unprotected-org": {
+						Policy: Policy{Protect: no},
+						Repos: map[string]Repo{
+							"inherited": {},
+							"enabled":   {Policy: Policy{Protect: yes}},
+						},
+					},
+					"unmanaged-org": {
+						Policy: Policy{Unmanaged: yes},
+						Repos: map[string]Repo{
+							"inherited": {},
+						},
+					},
+				},
+			},
+			expected: map[RepoProtectionState]int{RepoProtected: 3, RepoUnprotected: 2, RepoUnmanaged: 2},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.expected, tc.config.CountReposByProtection()); diff != "" {
+				t.Errorf("counts differ from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCountBranchesByApprovals(t *testing.T) {
+	one, two := 1, 2
+	testCases := []struct {
+		name     string
+		config   BranchProtection
+		expected map[int]int
+	}{
+		{
+			name:     "no branches",
+			expected: map[int]int{},
+		},
+		{
+			name: "varied approval counts",
+			config: BranchProtection{
+				Policy: Policy{Protect: yes},
+				Orgs: map[string]Org{
+					"org": {
+						Policy: Policy{RequiredPullRequestReviews: &ReviewPolicy{Approvals: &one}},
+						Repos: map[string]Repo{
+							"inherited": {
+								Branches: map[string]Branch{
+									"main":    {},
+									"release": {},
+								},
+							},
+							"strict": {
+								Policy: Policy{RequiredPullRequestReviews: &ReviewPolicy{Approvals: &two}},
+								Branches: map[string]Branch{
+									"main": {},
+									"dev":  {Policy: Policy{RequiredPullRequestReviews: &ReviewPolicy{Approvals: &one}}},
+								},
+							},
+							"archived": {
+								Archived: yes,
+								Branches: map[string]Branch{"main": {}},
+							},
+						},
+					},
+					"no-reviews-org": {
+						Repos: map[string]Repo{
+							"repo": {
+								Branches: map[string]Branch{
+									"main":      {},
+									"disabled":  {Policy: Policy{Protect: no}},
+									"unmanaged": {Policy: Policy{Unmanaged: yes}},
+								},
+							},
+						},
+					},
+				},
+			},
+			expected: map[int]int{0: 1, 1: 3, 2: 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.expected, tc.config.CountBranchesByApprovals()); diff != "" {
+				t.Errorf("counts differ from expected (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestStrictBranches(t *testing.T) {
+	bp := BranchProtection{
+		Policy: Policy{Protect: yes},
+		Orgs: map[string]Org{
+			"org": {
+				Policy: Policy{RequiredStatusChecks: &ContextPolicy{Strict: yes}},
+				Repos: map[string]Repo{
+					"inherited": {
+						Branches: map[string]Branch{
+							"main":    {},
+							"release": {Policy: Policy{RequiredStatusChecks: &ContextPolicy{Strict: no}}},
+						},
+					},
+					"archived": {
+						Archived: yes,
+						Branches: map[string]Branch{"main": {}},
+					},
+				},
+			},
+			"lenient-org": {
+				Repos: map[string]Repo{
+					"repo": {
+						Branches: map[string]Branch{
+							"main":      {},
+							"strict":    {Policy: Policy{RequiredStatusChecks: &ContextPolicy{Strict: yes}}},
+							"disabled":  {Policy: Policy{Protect: no, RequiredStatusChecks: &ContextPolicy{Strict: yes}}},
+							"unmanaged": {Policy: Policy{Unmanaged: yes, RequiredStatusChecks: &ContextPolicy{Strict: yes}}},
+						},
+					},
+				},
+			},
+		},
+	}
+	expected := []string{"lenient-org/repo=strict", "org/inherited=main"}
+	if diff := cmp.Diff(expected, bp.StrictBranches()); diff != "" {
+		t.Errorf("strict branches differ from expected (-want +got):\n%s", diff)
+	}
+}
@@ -204,6 +204,16 @@ type ProwConfig struct {
 	// can be used to restrict build cluster on a topic.
 	PubSubTriggers PubSubTriggers `json:"pubsub_triggers,omitempty"`
 
+	// PubSubForbiddenEnvs lists the environment variables that Pub/Sub messages
+	// may not set on the jobs they trigger. Each of the pubsub_triggers can
+	// forbid additional variables or allow some of these.
+	PubSubForbiddenEnvs []string `json:"pubsub_forbidden_envs,omitempty"`
+
+	// PubSubMaintenanceWindows pause triggering the matching jobs from Pub/Sub
+	// messages, e.g. during cluster maintenance. Messages received during a
+	// window are nacked so that Pub/Sub redelivers them later.
+	PubSubMaintenanceWindows []PubSubMaintenanceWindow `json:"pubsub_maintenance_windows,omitempty"`
+
 	// GitHubOptions allows users to control how prow applications display GitHub website links.
 	GitHubOptions GitHubOptions `json:"github,omitempty"`
 
@@ -1533,6 +1543,277 @@ type PubSubTrigger struct {
 	AllowedClusters []string `json:"allowed_clusters"`
 	// MaxOutstandingMessages is the max number of messaged being processed, default is 10.
 	MaxOutstandingMessages int `json:"max_outstanding_messages"`
+	// GitHubApp optionally configures the GitHub App used to fetch inrepoconfig
+	// for jobs triggered from these topics, instead of the credentials sub runs with.
+	// It is not supported when sub fetches inrepoconfig from Moonraker.
+	GitHubApp *PubSubGitHubApp `json:"github_app,omitempty"`
+	// TenantID restricts these topics to triggering jobs of the given tenant.
+	// Jobs of any tenant can be triggered if unset.
+	TenantID string `json:"tenant_id,omitempty"`
+	// ReportTopic overrides the Pub/Sub topic that jobs triggered from these
+	// topics report their creation status to. Jobs that don't specify a
+	// project to report to use Project.
+	ReportTopic string `json:"report_topic,omitempty"`
+	// ResponseTopic is the Pub/Sub topic of Project that a response is
+	// published to once the ProwJob of a message of these topics is created,
+	// with the name, namespace and UID of the job, so that publishers can
+	// correlate their message with the job. The response carries the ID of the
+	// message in the prow.k8s.io/pubsub.MessageID attribute. Nothing is
+	// published if unset.
+	ResponseTopic string `json:"response_topic,omitempty"`
+	// ForbiddenEnvs lists environment variables that messages of these topics
+	// may not set, in addition to the global pubsub_forbidden_envs.
+	ForbiddenEnvs []string `json:"forbidden_envs,omitempty"`
+	// AllowedEnvs lists environment variables forbidden by the global
+	// pubsub_forbidden_envs that messages of these topics may set anyway.
+	AllowedEnvs []string `json:"allowed_envs,omitempty"`
+	// EnvSafelist optionally restricts the environment variables that messages
+	// of these topics may set to the listed ones, e.g. for topics published to
+	// by third parties. Forbidden envs stay forbidden. Any env may be set if unset.
+	EnvSafelist []string `json:"env_safelist,omitempty"`
+	// MaxInfraRetries is how many times jobs triggered from these topics are
+	// recreated when they end in the error state, e.g. because their pod was
+	// evicted. Jobs are not recreated if unset. Jobs are watched by the
+	// subscriber in memory, so they are no longer retried after it restarts.
+	MaxInfraRetries int `json:"max_infra_retries,omitempty"`
+	// AbortOlderPresubmits aborts the incomplete presubmits of the same job and
+	// pull requests when messages of these topics trigger a presubmit, e.g. to
+	// rerun a job against the updated head SHA of a pull request.
+	AbortOlderPresubmits bool `json:"abort_older_presubmits,omitempty"`
+	// KubeContext optionally names the kubeconfig context of the cluster that
+	// ProwJobs triggered from these topics are stored in. Defaults to the
+	// infrastructure cluster.
+	KubeContext string `json:"kube_context,omitempty"`
+	// DefaultEventType is the event type of messages of these topics that
+	// don't set the prow.k8s.io/pubsub.EventType attribute, e.g.
+	// prow.k8s.io/pubsub.PeriodicProwJobEvent. Such messages are rejected if unset.
+	DefaultEventType string `json:"default_event_type,omitempty"`
+	// EventTypeFromPayload reads the event type of messages of these topics
+	// that don't set the prow.k8s.io/pubsub.EventType attribute from the type
+	// field of their JSON payload, before falling back to DefaultEventType.
+	EventTypeFromPayload bool `json:"event_type_from_payload,omitempty"`
+	// Transform optionally configures a webhook that the events of these
+	// topics are sent to, and replaced by, before their job is created.
+	Transform *PubSubTransform `json:"transform,omitempty"`
+	// ProcessingSLO is how long handling a message of these topics may take.
+	// Messages taking longer are counted by the prow_pubsub_slow_message_counter
+	// metric, so that alerts can target the subscription. Not counted if unset.
+	ProcessingSLO *metav1.Duration `json:"processing_slo,omitempty"`
+	// AllowProwJobName allows messages of these topics to set the name of the
+	// ProwJob they create, e.g. for callers deduplicating events themselves.
+	// Messages for which a ProwJob of that name exists already are acked.
+	AllowProwJobName bool `json:"allow_prowjob_name,omitempty"`
+	// AllowOwnerReferences allows messages of these topics to set owner
+	// references on the ProwJob they create, e.g. so that it is garbage
+	// collected along with an object of an external controller.
+	AllowOwnerReferences bool `json:"allow_owner_references,omitempty"`
+	// AttributeLabels maps message attributes to the labels their values are
+	// copied to on the jobs of these topics, e.g. team: example.com/team.
+	// Labels set by the event itself take precedence.
+	AttributeLabels map[string]string `json:"attribute_labels,omitempty"`
+	// AnnotationTemplates maps annotations to the templates their values are
+	// built from on the jobs of these topics, e.g. link: https://ci/{{.build-id}}.
+	// Variables are resolved from the message attributes, then from the envs of
+	// the event. Messages referencing unknown variables are rejected.
+	// Annotations set by the event itself take precedence.
+	AnnotationTemplates map[string]string `json:"annotation_templates,omitempty"`
+	// SanitizeLabelValues sanitizes invalid label values of the jobs of these
+	// topics, e.g. ones too long or with invalid characters, and lists the
+	// sanitized labels in the prow.k8s.io/pubsub.sanitized-labels annotation.
+	// Messages with invalid label values are rejected by default.
+	SanitizeLabelValues bool `json:"sanitize_label_values,omitempty"`
+	// GCSCredentialsSecret is the name of the secret holding the GCS
+	// credentials that the decorated jobs of these topics upload their
+	// artifacts with, overriding the one of their decoration config.
+	GCSCredentialsSecret string `json:"gcs_credentials_secret,omitempty"`
+	// MaxTimeout is the longest decoration timeout that messages of these
+	// topics may set on their jobs. Messages setting a timeout are rejected
+	// if unset.
+	MaxTimeout *metav1.Duration `json:"max_timeout,omitempty"`
+	// PayloadTemplate constructs the events of these topics from payloads of
+	// another shape, e.g. emitted by third-party systems. It maps event fields
+	// to paths of values in the JSON payload, e.g. name: .job.name or
+	// envs.BUILD_ID: .builds[0].id. The fields name, prowjob_name, refs.org,
+	// refs.repo, refs.base_ref, refs.base_sha and the keys of envs, labels and
+	// annotations are supported. Payloads must be events if unset.
+	PayloadTemplate map[string]string `json:"payload_template,omitempty"`
+	// DrainGracePeriod is how long sub waits on shutdown for the messages of
+	// these topics that are being handled, e.g. for subscriptions whose
+	// messages take long to handle. Defaults to the --grace-period of sub.
+	DrainGracePeriod *metav1.Duration `json:"drain_grace_period,omitempty"`
+	// ReportPayload attaches a copy of the message to the reports of jobs of
+	// these topics that failed to be created, in the prow.k8s.io/pubsub.payload
+	// annotation, for debugging producers. The values of envs are redacted and
+	// the copy is truncated to 4KiB.
+	ReportPayload bool `json:"report_payload,omitempty"`
+}
+
+// payloadTemplateFields are the event fields a PayloadTemplate may set,
+// besides the keys of payloadTemplateMaps.
+var payloadTemplateFields = sets.New[string]("name", "prowjob_name", "refs.org", "refs.repo", "refs.base_ref", "refs.base_sha")
+
+// payloadTemplateMaps are the event fields whose keys a PayloadTemplate may set.
+var payloadTemplateMaps = sets.New[string]("envs", "labels", "annotations")
+
+// payloadTemplatePath matches the paths of values in payloads, made of object
+// keys prefixed by a dot and array indices in brackets.
+var payloadTemplatePath = regexp.MustCompile(`^(\.[^.\[\]]+|\[[0-9]+\])+$`)
+
+// validatePayloadTemplate returns an error if the template sets an unsupported
+// event field or has an invalid path.
+func validatePayloadTemplate(template map[string]string) error {
+	for _, field := range sets.List(sets.KeySet(template)) {
+		if prefix, key, isMap := strings.Cut(field, "."); !payloadTemplateFields.Has(field) && (!isMap || !payloadTemplateMaps.Has(prefix) || key == "") {
+			return fmt.Errorf("unsupported field %q", field)
+		}
+		if path := template[field]; !payloadTemplatePath.MatchString(path) {
+			return fmt.Errorf("invalid path %q of field %q, expected e.g. .job.name or .builds[0].id", path, field)
+		}
+	}
+	return nil
+}
+
+// annotationTemplateVariable matches the variables of annotation templates,
+// e.g. {{.build-id}}.
+var annotationTemplateVariable = regexp.MustCompile(`\{\{\s*\.([^{}\s]+)\s*\}\}`)
+
+// ExpandAnnotationTemplate replaces the variables of the annotation template
+// with the values returned by lookup. It returns an error listing the variables
+// that lookup doesn't know.
+func ExpandAnnotationTemplate(template string, lookup func(variable string) (string, bool)) (string, error) {
+	var unknown []string
+	expanded := annotationTemplateVariable.ReplaceAllStringFunc(template, func(match string) string {
+		variable := annotationTemplateVariable.FindStringSubmatch(match)[1]
+		value, ok := lookup(variable)
+		if !ok {
+			unknown = append(unknown, variable)
+		}
+		return value
+	})
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("unknown variables %s", strings.Join(unknown, ", "))
+	}
+	return expanded, nil
+}
+
+// validateAnnotationTemplates returns an error if the templates set invalid
+// annotations or have malformed variables.
+func validateAnnotationTemplates(templates map[string]string) error {
+	for _, annotation := range sets.List(sets.KeySet(templates)) {
+		if err := validateAnnotation(map[string]string{annotation: ""}); err != nil {
+			return err
+		}
+		template := templates[annotation]
+		if stripped := annotationTemplateVariable.ReplaceAllString(template, ""); strings.Contains(stripped, "{{") || strings.Contains(stripped, "}}") {
+			return fmt.Errorf("template %q of annotation %q has a malformed variable, expected e.g. {{.build-id}}", template, annotation)
+		}
+	}
+	return nil
+}
+
+// PubSubTransform configures the webhook transforming the events of a PubSubTrigger.
+type PubSubTransform struct {
+	// URL is POSTed the JSON encoded event and must respond with the event to
+	// trigger the job with, e.g. enriched with envs or annotations.
+	URL string `json:"url"`
+	// Timeout of the request to the webhook. Defaults to 10s.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// FailOpen triggers the job with the original event if the webhook fails
+	// or times out. Such messages are rejected by default.
+	FailOpen bool `json:"fail_open,omitempty"`
+}
+
+// ForbiddenEnvs returns the environment variables that messages of the
+// trigger may not set: the global PubSubForbiddenEnvs extended by the
+// trigger's ForbiddenEnvs, without its AllowedEnvs.
+func (c *ProwConfig) ForbiddenEnvs(trigger PubSubTrigger) sets.Set[string] {
+	forbidden := sets.New[string](c.PubSubForbiddenEnvs...).Insert(trigger.ForbiddenEnvs...)
+	return forbidden.Delete(trigger.AllowedEnvs...)
+}
+
+// RejectedEnvs returns the environment variables among envs that messages of
+// the trigger may not set: the forbidden ones and, if the trigger has an
+// EnvSafelist, the ones missing from it.
+func (c *ProwConfig) RejectedEnvs(trigger PubSubTrigger, envs sets.Set[string]) sets.Set[string] {
+	rejected := c.ForbiddenEnvs(trigger).Intersection(envs)
+	if len(trigger.EnvSafelist) > 0 {
+		rejected = rejected.Union(envs.Difference(sets.New[string](trigger.EnvSafelist...)))
+	}
+	return rejected
+}
+
+// PubSubMaintenanceWindow is a time range during which jobs are not triggered
+// from Pub/Sub messages.
+type PubSubMaintenanceWindow struct {
+	// Start is when the window begins, in RFC3339 format.
+	Start time.Time `json:"start"`
+	// End is when the window ends, in RFC3339 format.
+	End time.Time `json:"end"`
+	// Jobs are glob patterns matching the names of the jobs paused during the
+	// window. All jobs are paused if empty.
+	Jobs []string `json:"jobs,omitempty"`
+}
+
+// InPubSubMaintenanceWindow returns whether triggering the named job from
+// Pub/Sub messages is paused by a maintenance window at the given time.
+func (c *ProwConfig) InPubSubMaintenanceWindow(jobName string, now time.Time) bool {
+	for _, window := range c.PubSubMaintenanceWindows {
+		if now.Before(window.Start) || !now.Before(window.End) {
+			continue
+		}
+		if len(window.Jobs) == 0 {
+			return true
+		}
+		for _, pattern := range window.Jobs {
+			if match, _ := filepath.Match(pattern, jobName); match {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MissingPubSubJobs returns the job patterns of the pubsub maintenance windows
+// that match none of the statically configured jobs, e.g. because the job was
+// renamed or removed. Jobs from inrepoconfig are not known and thus not matched.
+func (c *Config) MissingPubSubJobs() []string {
+	jobNames := sets.New[string]()
+	for _, p := range c.AllPeriodics() {
+		jobNames.Insert(p.Name)
+	}
+	for _, p := range c.AllStaticPresubmits(nil) {
+		jobNames.Insert(p.Name)
+	}
+	for _, p := range c.AllStaticPostsubmits(nil) {
+		jobNames.Insert(p.Name)
+	}
+
+	missing := sets.New[string]()
+	for _, window := range c.PubSubMaintenanceWindows {
+		for _, pattern := range window.Jobs {
+			if !matchesAnyJob(pattern, jobNames) {
+				missing.Insert(pattern)
+			}
+		}
+	}
+	return sets.List(missing)
+}
+
+func matchesAnyJob(pattern string, jobNames sets.Set[string]) bool {
+	for jobName := range jobNames {
+		if match, _ := filepath.Match(pattern, jobName); match {
+			return true
+		}
+	}
+	return false
+}
+
+// PubSubGitHubApp references the GitHub App credentials of a PubSubTrigger.
+type PubSubGitHubApp struct {
+	// AppID is the ID of the GitHub App.
+	AppID string `json:"app_id"`
+	// PrivateKeyPath is the path to the private key of the GitHub App.
+	PrivateKeyPath string `json:"private_key_path"`
 }
 
 // GitHubOptions allows users to control how prow applications display GitHub website links.
@@ -1882,6 +2163,61 @@ func loadConfig(prowConfig, jobConfig string, additionalProwConfigDirs []string,
 		if trigger.MaxOutstandingMessages == 0 {
 			nc.PubSubTriggers[i].MaxOutstandingMessages = defaultMaxOutstandingMessages
 		}
+		if app := trigger.GitHubApp; app != nil && (app.AppID == "" || app.PrivateKeyPath == "") {
+			return nil, fmt.Errorf("pubsub_triggers[%d].github_app requires both app_id and private_key_path", i)
+		}
+		if both := sets.New[string](trigger.ForbiddenEnvs...).Intersection(sets.New[string](trigger.AllowedEnvs...)); both.Len() > 0 {
+			return nil, fmt.Errorf("pubsub_triggers[%d] both forbids and allows envs %s", i, strings.Join(sets.List(both), ", "))
+		}
+		if both := sets.New[string](trigger.ForbiddenEnvs...).Intersection(sets.New[string](trigger.EnvSafelist...)); both.Len() > 0 {
+			return nil, fmt.Errorf("pubsub_triggers[%d] both forbids and safelists envs %s", i, strings.Join(sets.List(both), ", "))
+		}
+		if trigger.MaxInfraRetries < 0 {
+			return nil, fmt.Errorf("pubsub_triggers[%d].max_infra_retries must not be negative", i)
+		}
+		if transform := trigger.Transform; transform != nil {
+			if u, err := url.Parse(transform.URL); err != nil || !u.IsAbs() {
+				return nil, fmt.Errorf("pubsub_triggers[%d].transform.url must be an absolute URL, got %q", i, transform.URL)
+			}
+			if transform.Timeout != nil && transform.Timeout.Duration <= 0 {
+				return nil, fmt.Errorf("pubsub_triggers[%d].transform.timeout must be positive", i)
+			}
+		}
+		if trigger.ProcessingSLO != nil && trigger.ProcessingSLO.Duration <= 0 {
+			return nil, fmt.Errorf("pubsub_triggers[%d].processing_slo must be positive", i)
+		}
+		if trigger.MaxTimeout != nil && trigger.MaxTimeout.Duration <= 0 {
+			return nil, fmt.Errorf("pubsub_triggers[%d].max_timeout must be positive", i)
+		}
+		if trigger.DrainGracePeriod != nil && trigger.DrainGracePeriod.Duration <= 0 {
+			return nil, fmt.Errorf("pubsub_triggers[%d].drain_grace_period must be positive", i)
+		}
+		for attribute, label := range trigger.AttributeLabels {
+			if err := validateLabels(map[string]string{label: ""}); err != nil {
+				return nil, fmt.Errorf("pubsub_triggers[%d].attribute_labels[%s]: %w", i, attribute, err)
+			}
+		}
+		if err := validateAnnotationTemplates(trigger.AnnotationTemplates); err != nil {
+			return nil, fmt.Errorf("pubsub_triggers[%d].annotation_templates: %w", i, err)
+		}
+		if err := validatePayloadTemplate(trigger.PayloadTemplate); err != nil {
+			return nil, fmt.Errorf("pubsub_triggers[%d].payload_template: %w", i, err)
+		}
+		if trigger.GCSCredentialsSecret != "" {
+			if errs := validation.IsDNS1123Subdomain(trigger.GCSCredentialsSecret); len(errs) != 0 {
+				return nil, fmt.Errorf("pubsub_triggers[%d].gcs_credentials_secret %q is not a valid secret name: %s", i, trigger.GCSCredentialsSecret, strings.Join(errs, "; "))
+			}
+		}
+	}
+	for i, window := range nc.PubSubMaintenanceWindows {
+		if !window.Start.Before(window.End) {
+			return nil, fmt.Errorf("pubsub_maintenance_windows[%d] must end after it starts", i)
+		}
+		for _, pattern := range window.Jobs {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("pubsub_maintenance_windows[%d] has invalid job pattern %q: %w", i, pattern, err)
+			}
+		}
 	}
 
 	// TODO(krzyzacy): temporary allow empty jobconfig
@@ -2790,6 +3126,12 @@ func parseProwConfig(c *Config) error {
 	if len(c.BranchProtection.Include) > 0 && len(c.BranchProtection.Exclude) > 0 {
 		return fmt.Errorf("Forbidden to set both Policy.Include and Policy.Exclude, Please use either Include or Exclude!")
 	}
+	if err := c.BranchProtection.validateAllowedMergeMethods(); err != nil {
+		return err
+	}
+	if err := c.BranchProtection.validateLinearHistory(&c.Tide); err != nil {
+		return err
+	}
 
 	// Avoid using a Moonraker client timeout of infinity (default behavior of
 	// https://pkg.go.dev/net/http#Client) by setting a default value.
@@ -3190,6 +3190,486 @@ pubsub_subscriptions:
 				return nil
 			},
 		},
+		{
+			name: "PubSubTriggers with GitHub App credentials",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  github_app:
+    app_id: "123"
+    private_key_path: /etc/app/key
+`,
+			verify: func(c *Config) error {
+				if diff := cmp.Diff(c.PubSubTriggers[0].GitHubApp, &PubSubGitHubApp{AppID: "123", PrivateKeyPath: "/etc/app/key"}); diff != "" {
+					return fmt.Errorf("want(-), got(+): \n%s", diff)
+				}
+				return nil
+			},
+		},
+		{
+			name: "PubSubTriggers with incomplete GitHub App credentials",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  github_app:
+    app_id: "123"
+`,
+			expectError: true,
+		},
+		{
+			name: "PubSubTriggers extend and relax the global forbidden envs",
+			prowConfig: `
+pubsub_forbidden_envs:
+- GLOBAL_A
+- GLOBAL_B
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  forbidden_envs:
+  - TRIGGER_C
+  allowed_envs:
+  - GLOBAL_B
+- project: projA
+  topics:
+  - topicC
+`,
+			verify: func(c *Config) error {
+				if diff := cmp.Diff([]string{"GLOBAL_A", "TRIGGER_C"}, sets.List(c.ForbiddenEnvs(c.PubSubTriggers[0]))); diff != "" {
+					return fmt.Errorf("want(-), got(+): \n%s", diff)
+				}
+				if diff := cmp.Diff([]string{"GLOBAL_A", "GLOBAL_B"}, sets.List(c.ForbiddenEnvs(c.PubSubTriggers[1]))); diff != "" {
+					return fmt.Errorf("want(-), got(+): \n%s", diff)
+				}
+				return nil
+			},
+		},
+		{
+			name: "PubSubTriggers restrict envs to their safelist",
+			prowConfig: `
+pubsub_forbidden_envs:
+- GLOBAL_A
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  env_safelist:
+  - GLOBAL_A
+  - SAFE
+- project: projA
+  topics:
+  - topicC
+`,
+			verify: func(c *Config) error {
+				envs := sets.New[string]("GLOBAL_A", "SAFE", "OTHER")
+				if diff := cmp.Diff([]string{"GLOBAL_A", "OTHER"}, sets.List(c.RejectedEnvs(c.PubSubTriggers[0], envs))); diff != "" {
+					return fmt.Errorf("want(-), got(+): \n%s", diff)
+				}
+				if diff := cmp.Diff([]string{"GLOBAL_A"}, sets.List(c.RejectedEnvs(c.PubSubTriggers[1], envs))); diff != "" {
+					return fmt.Errorf("want(-), got(+): \n%s", diff)
+				}
+				return nil
+			},
+		},
+		{
+			name: "PubSubTriggers can't both forbid and safelist an env",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  forbidden_envs:
+  - FOO
+  env_safelist:
+  - FOO
+`,
+			expectError: true,
+		},
+		{
+			name: "PubSubTriggers can't both forbid and allow an env",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  forbidden_envs:
+  - FOO
+  allowed_envs:
+  - FOO
+`,
+			expectError: true,
+		},
+		{
+			name: "PubSubTriggers can't retry a negative number of times",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  max_infra_retries: -1
+`,
+			expectError: true,
+		},
+		{
+			name: "PubSubTriggers transform requires an absolute URL",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  transform:
+    url: /transform
+`,
+			expectError: true,
+		},
+		{
+			name: "PubSubTriggers transform is loaded",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  transform:
+    url: https://transform.example.com
+    timeout: 5s
+    fail_open: true
+`,
+			verify: func(c *Config) error {
+				transform := c.PubSubTriggers[0].Transform
+				if transform == nil || transform.URL != "https://transform.example.com" || transform.Timeout.Duration != 5*time.Second || !transform.FailOpen {
+					return fmt.Errorf("unexpected transform %+v", transform)
+				}
+				return nil
+			},
+		},
+		{
+			name: "PubSubTriggers drain_grace_period must be positive",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  drain_grace_period: 0s
+`,
+			expectError: true,
+		},
+		{
+			name: "PubSubTriggers processing_slo must be positive",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  processing_slo: 0s
+`,
+			expectError: true,
+		},
+		{
+			name: "PubSubTriggers processing_slo is loaded",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  processing_slo: 30s
+`,
+			verify: func(c *Config) error {
+				if slo := c.PubSubTriggers[0].ProcessingSLO; slo == nil || slo.Duration != 30*time.Second {
+					return fmt.Errorf("unexpected processing_slo %v", slo)
+				}
+				return nil
+			},
+		},
+		{
+			name: "PubSubTriggers attribute_labels must map to valid labels",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  attribute_labels:
+    team: not a label
+`,
+			expectError: true,
+		},
+		{
+			name: "PubSubTriggers attribute_labels is loaded",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  attribute_labels:
+    team: example.com/team
+`,
+			verify: func(c *Config) error {
+				if label := c.PubSubTriggers[0].AttributeLabels["team"]; label != "example.com/team" {
+					return fmt.Errorf("unexpected attribute_labels %v", c.PubSubTriggers[0].AttributeLabels)
+				}
+				return nil
+			},
+		},
+		{
+			name: "PubSubTriggers gcs_credentials_secret must be a valid secret name",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  gcs_credentials_secret: Not_A_Secret
+`,
+			expectError: true,
+		},
+		{
+			name: "PubSubTriggers gcs_credentials_secret is loaded",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  gcs_credentials_secret: team-gcs-credentials
+`,
+			verify: func(c *Config) error {
+				if secret := c.PubSubTriggers[0].GCSCredentialsSecret; secret != "team-gcs-credentials" {
+					return fmt.Errorf("unexpected gcs_credentials_secret %q", secret)
+				}
+				return nil
+			},
+		},
+		{
+			name: "PubSubTriggers max_timeout must be positive",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  max_timeout: 0s
+`,
+			expectError: true,
+		},
+		{
+			name: "PubSubTriggers max_timeout is loaded",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  max_timeout: 1h
+`,
+			verify: func(c *Config) error {
+				if max := c.PubSubTriggers[0].MaxTimeout; max == nil || max.Duration != time.Hour {
+					return fmt.Errorf("unexpected max_timeout %v", max)
+				}
+				return nil
+			},
+		},
+		{
+			name: "PubSubTriggers annotation_templates must have well-formed variables",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  annotation_templates:
+    link: https://ci/{{build-id}}
+`,
+			expectError: true,
+		},
+		{
+			name: "PubSubTriggers annotation_templates is loaded",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  annotation_templates:
+    link: https://ci/{{.build-id}}
+`,
+			verify: func(c *Config) error {
+				expected := map[string]string{"link": "https://ci/{{.build-id}}"}
+				if templates := c.PubSubTriggers[0].AnnotationTemplates; !reflect.DeepEqual(templates, expected) {
+					return fmt.Errorf("unexpected annotation_templates %v", templates)
+				}
+				return nil
+			},
+		},
+		{
+			name: "PubSubTriggers payload_template must set supported fields",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  payload_template:
+    cluster: .cluster
+`,
+			expectError: true,
+		},
+		{
+			name: "PubSubTriggers payload_template must have valid paths",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  payload_template:
+    name: job.name
+`,
+			expectError: true,
+		},
+		{
+			name: "PubSubTriggers payload_template is loaded",
+			prowConfig: `
+pubsub_triggers:
+- project: projA
+  topics:
+  - topicB
+  payload_template:
+    name: .job.name
+    envs.BUILD_ID: .builds[0].id
+    labels.example.com/team: .team
+`,
+			verify: func(c *Config) error {
+				expected := map[string]string{"name": ".job.name", "envs.BUILD_ID": ".builds[0].id", "labels.example.com/team": ".team"}
+				if template := c.PubSubTriggers[0].PayloadTemplate; !reflect.DeepEqual(template, expected) {
+					return fmt.Errorf("unexpected payload_template %v", template)
+				}
+				return nil
+			},
+		},
+		{
+			name: "branch protection allowed_merge_methods must be known",
+			prowConfig: `
+branch-protection:
+  orgs:
+    org:
+      repos:
+        repo:
+          allowed_merge_methods:
+          - fast-forward
+`,
+			expectError: true,
+		},
+		{
+			name: "branch protection allowed_merge_methods is loaded",
+			prowConfig: `
+branch-protection:
+  orgs:
+    org:
+      repos:
+        repo:
+          allowed_merge_methods:
+          - squash
+          - rebase
+`,
+			verify: func(c *Config) error {
+				if methods := c.BranchProtection.GetAllowedMergeMethods("org", "repo"); !reflect.DeepEqual(methods, []types.PullRequestMergeType{types.MergeSquash, types.MergeRebase}) {
+					return fmt.Errorf("unexpected allowed_merge_methods %v", methods)
+				}
+				return nil
+			},
+		},
+		{
+			name: "PubSubMaintenanceWindows pause matching jobs inside the window",
+			prowConfig: `
+pubsub_maintenance_windows:
+- start: 2023-06-01T10:00:00Z
+  end: 2023-06-01T12:00:00Z
+  jobs:
+  - ci-build-*
+- start: 2023-06-02T10:00:00Z
+  end: 2023-06-02T12:00:00Z
+`,
+			verify: func(c *Config) error {
+				for _, tc := range []struct {
+					job      string
+					now      string
+					expected bool
+				}{
+					{job: "ci-build-foo", now: "2023-06-01T10:00:00Z", expected: true},
+					{job: "ci-build-foo", now: "2023-06-01T11:59:59Z", expected: true},
+					{job: "ci-build-foo", now: "2023-06-01T12:00:00Z", expected: false},
+					{job: "ci-build-foo", now: "2023-06-01T09:59:59Z", expected: false},
+					{job: "ci-test-foo", now: "2023-06-01T11:00:00Z", expected: false},
+					{job: "ci-test-foo", now: "2023-06-02T11:00:00Z", expected: true},
+				} {
+					now, err := time.Parse(time.RFC3339, tc.now)
+					if err != nil {
+						return err
+					}
+					if actual := c.InPubSubMaintenanceWindow(tc.job, now); actual != tc.expected {
+						return fmt.Errorf("expected %s to be paused at %s to be %t, got %t", tc.job, tc.now, tc.expected, actual)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name: "PubSubMaintenanceWindows must end after they start",
+			prowConfig: `
+pubsub_maintenance_windows:
+- start: 2023-06-01T12:00:00Z
+  end: 2023-06-01T10:00:00Z
+`,
+			expectError: true,
+		},
+		{
+			name: "PubSubMaintenanceWindows reject invalid job patterns",
+			prowConfig: `
+pubsub_maintenance_windows:
+- start: 2023-06-01T10:00:00Z
+  end: 2023-06-01T12:00:00Z
+  jobs:
+  - ci-[build
+`,
+			expectError: true,
+		},
+		{
+			name: "PubSubMaintenanceWindows report job patterns matching no job",
+			prowConfig: `
+pubsub_maintenance_windows:
+- start: 2023-06-01T10:00:00Z
+  end: 2023-06-01T12:00:00Z
+  jobs:
+  - ci-build-*
+  - ci-missing
+  - ci-renamed-*
+`,
+			jobConfigs: []string{
+				`
+periodics:
+- interval: 10m
+  agent: kubernetes
+  name: ci-build-amd64
+  spec:
+    containers:
+    - image: alpine`,
+			},
+			verify: func(c *Config) error {
+				if diff := cmp.Diff([]string{"ci-missing", "ci-renamed-*"}, c.MissingPubSubJobs()); diff != "" {
+					return fmt.Errorf("missing jobs differ from expected (-want +got):\n%s", diff)
+				}
+				return nil
+			},
+		},
+		{
+			name: "PubSubMaintenanceWindows without job patterns reference no jobs",
+			prowConfig: `
+pubsub_maintenance_windows:
+- start: 2023-06-01T10:00:00Z
+  end: 2023-06-01T12:00:00Z
+`,
+			verify: func(c *Config) error {
+				if missing := c.MissingPubSubJobs(); len(missing) != 0 {
+					return fmt.Errorf("expected no missing jobs, got %v", missing)
+				}
+				return nil
+			},
+		},
 		{
 			name:               "Version file sets the version",
 			versionFileContent: "some-git-sha",
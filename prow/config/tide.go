@@ -386,6 +386,15 @@ func (t *Tide) MergeMethod(repo OrgRepo) types.PullRequestMergeType {
 //
 //  6. default to "merge"
 func (t *Tide) OrgRepoBranchMergeMethod(orgRepo OrgRepo, branch string) types.PullRequestMergeType {
+	if mergeMethod, configured := t.configuredMergeMethod(orgRepo, branch); configured {
+		return mergeMethod
+	}
+	return types.MergeMerge
+}
+
+// configuredMergeMethod returns the merge method configured for the triple as
+// described by OrgRepoBranchMergeMethod, and false if none is configured.
+func (t *Tide) configuredMergeMethod(orgRepo OrgRepo, branch string) (types.PullRequestMergeType, bool) {
 	isOrgSet, isRepoSet, isBranchSet := orgRepo.Org != "", orgRepo.Repo != "", branch != ""
 	var orgFound, repoFound bool
 
@@ -411,7 +420,7 @@ func (t *Tide) OrgRepoBranchMergeMethod(orgRepo OrgRepo, branch string) types.Pu
 	if isOrgSet && isRepoSet && isBranchSet {
 		orgRepoBranchShorthand := fmt.Sprintf("%s/%s@%s", orgRepo.Org, orgRepo.Repo, branch)
 		if orgRepoBranch, found := t.MergeType[orgRepoBranchShorthand]; found && orgRepoBranch.MergeType != "" {
-			return orgRepoBranch.MergeType
+			return orgRepoBranch.MergeType, true
 		}
 	}
 
@@ -428,7 +437,7 @@ func (t *Tide) OrgRepoBranchMergeMethod(orgRepo OrgRepo, branch string) types.Pu
 		for _, key := range keys {
 			branchConfig := branches[key]
 			if branchConfig.Regexpr.MatchString(branch) {
-				return branchConfig.MergeType
+				return branchConfig.MergeType, true
 			}
 		}
 	}
@@ -437,26 +446,25 @@ func (t *Tide) OrgRepoBranchMergeMethod(orgRepo OrgRepo, branch string) types.Pu
 	if isOrgSet && isRepoSet {
 		orgRepoShorthand := fmt.Sprintf("%s/%s", orgRepo.Org, orgRepo.Repo)
 		if orgRepo, found := t.MergeType[orgRepoShorthand]; found && orgRepo.MergeType != "" {
-			return orgRepo.MergeType
+			return orgRepo.MergeType, true
 		}
 	}
 
 	// 4. Repo-wide match
 	if orgFound && repoFound {
 		if t.MergeType[orgRepo.Org].Repos[repo].MergeType != "" {
-			return t.MergeType[orgRepo.Org].Repos[repo].MergeType
+			return t.MergeType[orgRepo.Org].Repos[repo].MergeType, true
 		}
 	}
 
 	// 5. "$org" shorthand
 	if orgFound {
 		if t.MergeType[orgRepo.Org].MergeType != "" {
-			return t.MergeType[orgRepo.Org].MergeType
+			return t.MergeType[orgRepo.Org].MergeType, true
 		}
 	}
 
-	// 6. Default
-	return types.MergeMerge
+	return "", false
 }
 
 // MergeCommitTemplate returns a struct with Go template string(s) or nil
@@ -43,6 +43,9 @@ const (
 	PubSubTopicLabel = "prow.k8s.io/pubsub.topic"
 	// PubSubRunIDLabel annotation
 	PubSubRunIDLabel = "prow.k8s.io/pubsub.runID"
+	// PubSubPayloadLabel annotation carries a copy of the Pub/Sub message a
+	// ProwJob failed to be created from, for debugging producers.
+	PubSubPayloadLabel = "prow.k8s.io/pubsub.payload"
 )
 
 // ReportMessage is a message structure used to pass a prowjob status to Pub/Sub topic.s
@@ -57,6 +60,7 @@ type ReportMessage struct {
 	JobType prowapi.ProwJobType  `json:"job_type"`
 	JobName string               `json:"job_name"`
 	Message string               `json:"message,omitempty"`
+	Payload string               `json:"payload,omitempty"`
 }
 
 // Client is a reporter client fed to crier controller
@@ -193,5 +197,6 @@ func (c *Client) generateMessageFromPJ(pj *prowapi.ProwJob) *ReportMessage {
 		JobType: pj.Spec.Type,
 		JobName: pj.Spec.Job,
 		Message: pj.Status.Description,
+		Payload: pj.Annotations[PubSubPayloadLabel],
 	}
 }
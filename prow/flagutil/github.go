@@ -331,6 +331,19 @@ func (o *GitHubOptions) GitHubClientWithAccessToken(token string) (github.Client
 	return client, err
 }
 
+// WithGitHubApp returns a copy of the options that authenticates as the given
+// GitHub App instead of the configured token or app.
+func (o *GitHubOptions) WithGitHubApp(appID, appPrivateKeyPath string) *GitHubOptions {
+	copied := *o
+	copied.TokenPath = ""
+	copied.AppID = appID
+	copied.AppPrivateKeyPath = appPrivateKeyPath
+	// Generators are bound to the original credentials.
+	copied.tokenGenerator = nil
+	copied.userGenerator = nil
+	return &copied
+}
+
 // GitClientFactory returns git.ClientFactory. Passing non-empty cookieFilePath
 // will result in git ClientFactory to work with Gerrit.
 // TODO(chaodaiG): move this logic to somewhere more appropriate instead of in
@@ -283,6 +283,27 @@ func (o *KubernetesOptions) ProwJobClient(namespace string, dryRun bool) (prowJo
 	return o.prowJobClientset.ProwV1().ProwJobs(namespace), nil
 }
 
+// ProwJobClientForContext returns a ProwJob client for the cluster of the given context name.
+func (o *KubernetesOptions) ProwJobClientForContext(context, namespace string, dryRun bool) (prowJobClient prowv1.ProwJobInterface, err error) {
+	if err := o.resolve(dryRun); err != nil {
+		return nil, err
+	}
+
+	if o.dryRun {
+		return nil, errors.New("no dry-run prowjob client is supported in dry-run mode")
+	}
+
+	config, exists := o.clusterConfigs[context]
+	if !exists {
+		return nil, fmt.Errorf("context %q does not exist in the provided config", context)
+	}
+	clientset, err := prow.NewForConfig(&config)
+	if err != nil {
+		return nil, fmt.Errorf("create %s prowjob client: %w", context, err)
+	}
+	return clientset.ProwV1().ProwJobs(namespace), nil
+}
+
 // InfrastructureClusterConfig returns the *rest.Config for the infrastructure cluster
 func (o *KubernetesOptions) InfrastructureClusterConfig(dryRun bool) (*rest.Config, error) {
 	if err := o.resolve(dryRun); err != nil {
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -95,7 +96,7 @@ func (gw *Gangway) CreateJobExecution(ctx context.Context, cjer *CreateJobExecut
 	var reporterFunc ReporterFunc = nil
 	requireTenantID := true
 
-	jobExec, err := HandleProwJob(l, reporterFunc, cjer, gw.ProwJobClient, &mainConfig, gw.InRepoConfigGetter, allowedApiClient, requireTenantID, allowedClusters)
+	jobExec, err := HandleProwJob(ctx, l, reporterFunc, cjer, gw.ProwJobClient, &mainConfig, gw.InRepoConfigGetter, allowedApiClient, requireTenantID, allowedClusters)
 	if err != nil {
 		logrus.WithError(err).Debugf("failed to create job %q", cjer.GetJobName())
 		return nil, err
@@ -384,6 +385,10 @@ func (pull *Pull) Validate() error {
 	return nil
 }
 
+// ErrJobPaused is returned by HandleProwJob for jobs paused by the
+// kube.PausedAnnotation in their config.
+var ErrJobPaused = errors.New("job is paused")
+
 // Ensure interface is intact. I.e., this declaration ensures that the type
 // "*config.Config" implements the "prowCfgClient" interface. See
 // https://golang.org/doc/faq#guarantee_satisfies_interface.
@@ -423,6 +428,20 @@ func (cjer *CreateJobExecutionRequest) getJobHandler() (jobHandler, error) {
 	return jh, nil
 }
 
+// annotationEnabled returns whether the boolean annotation is set to true.
+// Values that aren't booleans leave it disabled.
+func annotationEnabled(l *logrus.Entry, annotations map[string]string, key string) bool {
+	value, ok := annotations[key]
+	if !ok {
+		return false
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		l.WithError(err).WithField("annotation", key).Warn("Ignoring annotation that isn't a boolean")
+	}
+	return enabled
+}
+
 // Deep-copy all map fields from a gangway.CreateJobExecutionRequest and also
 // the statically defined (configured in YAML) Prow Job labels and annotations.
 func mergeMapFields(cjer *CreateJobExecutionRequest, staticLabels, staticAnnotations map[string]string) (map[string]string, map[string]string) {
@@ -452,7 +471,8 @@ func mergeMapFields(cjer *CreateJobExecutionRequest, staticLabels, staticAnnotat
 	return combinedLabels, combinedAnnotations
 }
 
-func HandleProwJob(l *logrus.Entry,
+func HandleProwJob(ctx context.Context,
+	l *logrus.Entry,
 	reporterFunc ReporterFunc,
 	cjer *CreateJobExecutionRequest,
 	pjc ProwJobClient,
@@ -500,6 +520,30 @@ func HandleProwJob(l *logrus.Entry,
 		}
 	}
 
+	// deny job that is marked deprecated in its config
+	if annotationEnabled(l, annotations, kube.DeprecatedAnnotation) {
+		err := fmt.Errorf("job %s is deprecated", cjer.GetJobName())
+		if reason := annotations[kube.DeprecatedReasonAnnotation]; reason != "" {
+			err = fmt.Errorf("%w: %s", err, reason)
+		}
+		l.WithField("name", cjer.GetJobName()).Info("job is deprecated")
+		if reporterFunc != nil {
+			reporterFunc(&prowJobCR, prowcrd.ErrorState, err)
+		}
+		return nil, err
+	}
+
+	// deny job that is paused in its config, without reporting it as failed
+	// as the request may be retried once the job is unpaused
+	if annotationEnabled(l, annotations, kube.PausedAnnotation) {
+		err := fmt.Errorf("%w: %s", ErrJobPaused, cjer.GetJobName())
+		if reason := annotations[kube.PausedReasonAnnotation]; reason != "" {
+			err = fmt.Errorf("%w: %s", err, reason)
+		}
+		l.WithField("name", cjer.GetJobName()).Info("job is paused")
+		return nil, err
+	}
+
 	// deny job that runs on not allowed cluster
 	var clusterIsAllowed bool
 	for _, allowedCluster := range allowedClusters {
@@ -559,9 +603,11 @@ func HandleProwJob(l *logrus.Entry,
 		}
 	}
 
-	if _, err := pjc.Create(context.TODO(), &prowJobCR, metav1.CreateOptions{}); err != nil {
+	if _, err := pjc.Create(ctx, &prowJobCR, metav1.CreateOptions{}); err != nil {
 		l.WithError(err).Errorf("failed to create job %q as %q", cjer.GetJobName(), prowJobCR.Name)
-		if reporterFunc != nil {
+		// The job may still have been created if the request was cancelled,
+		// so leave reporting it to the caller.
+		if reporterFunc != nil && ctx.Err() == nil {
 			reporterFunc(&prowJobCR, prowcrd.ErrorState, err)
 		}
 		return nil, err
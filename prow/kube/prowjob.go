@@ -69,6 +69,20 @@ const (
 	// IsOptionalLabel is added in resources created by prow and
 	// carries the Optional from a Presubmit job.
 	IsOptionalLabel = "prow.k8s.io/is-optional"
+	// DeprecatedAnnotation can be set to "true" on a job in its config to
+	// mark it deprecated. Requests to trigger deprecated jobs through gangway
+	// or sub are rejected.
+	DeprecatedAnnotation = "prow.k8s.io/deprecated"
+	// DeprecatedReasonAnnotation can be set next to DeprecatedAnnotation with
+	// the reason the job is deprecated, e.g. the name of the job replacing it.
+	DeprecatedReasonAnnotation = "prow.k8s.io/deprecated-reason"
+	// PausedAnnotation can be set to "true" on a job in its config to pause
+	// it, e.g. during an incident. Requests to trigger paused jobs are
+	// rejected, and sub has Pub/Sub redeliver them later.
+	PausedAnnotation = "prow.k8s.io/paused"
+	// PausedReasonAnnotation can be set next to PausedAnnotation with the
+	// reason the job is paused.
+	PausedReasonAnnotation = "prow.k8s.io/paused-reason"
 
 	// Gerrit related labels that are used by Prow
 
@@ -0,0 +1,90 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prowjobs
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"k8s.io/client-go/tools/cache"
+
+	prowapi "sigs.k8s.io/prow/prow/apis/prowjobs/v1"
+)
+
+// observeSchedulingLatency records the time the job took from its creation to
+// its pod being scheduled, once the job transitions to pending. Jobs that never
+// get a pod, e.g. because they were aborted while triggered, are not recorded.
+func observeSchedulingLatency(histogramVec *prometheus.HistogramVec, oldJob *prowapi.ProwJob, newJob *prowapi.ProwJob) {
+	if oldJob == nil || oldJob.Status.PendingTime != nil || newJob.Status.PendingTime == nil {
+		return
+	}
+	if newJob.Status.StartTime.IsZero() {
+		return
+	}
+
+	histogram, err := histogramVec.GetMetricWithLabelValues(newJob.Namespace, newJob.Spec.Job, string(newJob.Spec.Type))
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get a histogram for a prowjob")
+		return
+	}
+	histogram.Observe(newJob.Status.PendingTime.Sub(newJob.Status.StartTime.Time).Seconds())
+}
+
+// NewProwJobSchedulingLatencyHistogramVec creates histograms which track the
+// time between the creation of ProwJobs and their transition to pending, i.e.
+// the scheduling latency of their pods. Like NewProwJobLifecycleHistogramVec,
+// data is collected by hooking itself into the prowjob informer, so only
+// transitions observed while running are recorded.
+func NewProwJobSchedulingLatencyHistogramVec(informer cache.SharedIndexInformer) *prometheus.HistogramVec {
+	histogramVec := newSchedulingLatencyHistogramVec()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldJob, newJob interface{}) {
+			observeSchedulingLatency(histogramVec, oldJob.(*prowapi.ProwJob), newJob.(*prowapi.ProwJob))
+		},
+	})
+	return histogramVec
+}
+
+func newSchedulingLatencyHistogramVec() *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "prow_job_scheduling_latency_seconds",
+			Help: "Time from the creation of a prow job to its transition to pending.",
+			Buckets: []float64{
+				(1 * time.Second).Seconds(),
+				(5 * time.Second).Seconds(),
+				(10 * time.Second).Seconds(),
+				(30 * time.Second).Seconds(),
+				(1 * time.Minute).Seconds(),
+				(2 * time.Minute).Seconds(),
+				(5 * time.Minute).Seconds(),
+				(10 * time.Minute).Seconds(),
+				(30 * time.Minute).Seconds(),
+				(1 * time.Hour).Seconds(),
+			},
+		},
+		[]string{
+			// namespace of the job
+			"job_namespace",
+			// name of the job
+			"job_name",
+			// type of the prowjob: presubmit, postsubmit, periodic, batch
+			"type",
+		},
+	)
+}
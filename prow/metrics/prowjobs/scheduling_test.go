@@ -0,0 +1,89 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prowjobs
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "sigs.k8s.io/prow/prow/apis/prowjobs/v1"
+)
+
+func TestObserveSchedulingLatency(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	pending := v1.NewTime(start.Add(30 * time.Second))
+	job := func(pendingTime *v1.Time) *prowapi.ProwJob {
+		return &prowapi.ProwJob{
+			ObjectMeta: v1.ObjectMeta{Namespace: "prowjobs"},
+			Spec:       prowapi.ProwJobSpec{Job: "job", Type: prowapi.PeriodicJob},
+			Status: prowapi.ProwJobStatus{
+				StartTime:   v1.NewTime(start),
+				PendingTime: pendingTime,
+			},
+		}
+	}
+	tests := []struct {
+		name          string
+		oldJob        *prowapi.ProwJob
+		newJob        *prowapi.ProwJob
+		expectedCount uint64
+		expectedSum   float64
+	}{
+		{
+			name:          "transition to pending is observed",
+			oldJob:        job(nil),
+			newJob:        job(&pending),
+			expectedCount: 1,
+			expectedSum:   30,
+		},
+		{
+			name:   "job that never became pending is not observed",
+			oldJob: job(nil),
+			newJob: job(nil),
+		},
+		{
+			name:   "job that was already pending is not observed again",
+			oldJob: job(&pending),
+			newJob: job(&pending),
+		},
+		{
+			name:   "job without start time is not observed",
+			oldJob: &prowapi.ProwJob{},
+			newJob: &prowapi.ProwJob{Status: prowapi.ProwJobStatus{PendingTime: &pending}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			histogramVec := newSchedulingLatencyHistogramVec()
+			observeSchedulingLatency(histogramVec, tt.oldJob, tt.newJob)
+			var count uint64
+			var sum float64
+			for _, m := range collect(histogramVec) {
+				count += m.GetHistogram().GetSampleCount()
+				sum += m.GetHistogram().GetSampleSum()
+			}
+			if count != tt.expectedCount {
+				t.Errorf("expected %d observations, got %d", tt.expectedCount, count)
+			}
+			if sum != tt.expectedSum {
+				t.Errorf("expected a sum of %v, got %v", tt.expectedSum, sum)
+			}
+		})
+	}
+}
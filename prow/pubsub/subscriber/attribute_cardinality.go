@@ -0,0 +1,75 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subscriber
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// attributeCardinality tracks the distinct combinations of attribute values of
+// the messages of every subscription, remembering at most limit combinations
+// per subscription so that memory stays bounded.
+type attributeCardinality struct {
+	lock         sync.Mutex
+	combinations map[string]sets.Set[string]
+}
+
+// observe records the attribute combination of a message and returns the number
+// of distinct combinations tracked for the subscription, and whether the
+// combination is new but wasn't tracked because the limit was reached.
+func (a *attributeCardinality) observe(subscription string, attributes map[string]string, limit int) (int, bool) {
+	// Maps are marshaled with sorted keys, so equal attributes have equal keys.
+	key, err := json.Marshal(attributes)
+	if err != nil {
+		return 0, false
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.combinations == nil {
+		a.combinations = map[string]sets.Set[string]{}
+	}
+	seen := a.combinations[subscription]
+	if seen == nil {
+		seen = sets.New[string]()
+		a.combinations[subscription] = seen
+	}
+	if seen.Has(string(key)) {
+		return seen.Len(), false
+	}
+	if seen.Len() >= limit {
+		return seen.Len(), true
+	}
+	seen.Insert(string(key))
+	return seen.Len(), false
+}
+
+// recordAttributeCardinality records the attribute combination of a message in
+// the attribute cardinality metrics, if MaxAttributeCombinations is set.
+func (s *Subscriber) recordAttributeCardinality(subscription string, attributes map[string]string) {
+	if s.MaxAttributeCombinations <= 0 {
+		return
+	}
+	combinations, overflow := s.attributeCardinality.observe(subscription, attributes, s.MaxAttributeCombinations)
+	s.Metrics.AttributeCombinationsGauge.With(prometheus.Labels{subscriptionLabel: subscription}).Set(float64(combinations))
+	if overflow {
+		s.Metrics.AttributeCombinationsOverflowCounter.With(prometheus.Labels{subscriptionLabel: subscription}).Inc()
+	}
+}
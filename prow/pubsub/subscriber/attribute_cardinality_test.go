@@ -0,0 +1,85 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subscriber
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"sigs.k8s.io/prow/prow/client/clientset/versioned/fake"
+	"sigs.k8s.io/prow/prow/config"
+)
+
+func TestHandleMessageAttributeCardinality(t *testing.T) {
+	c := &config.Config{
+		JobConfig: config.JobConfig{
+			Periodics: []config.Periodic{{JobBase: config.JobBase{Name: "test"}}},
+		},
+	}
+	c.ProwJobNamespace = "prowjobs"
+	ca := &config.Agent{}
+	ca.Set(c)
+	fakeProwJobClient := fake.NewSimpleClientset()
+	s := Subscriber{
+		Metrics:                  NewMetrics(),
+		ProwJobClient:            fakeProwJobClient.ProwV1().ProwJobs(c.ProwJobNamespace),
+		ConfigAgent:              ca,
+		Reporter:                 &fakeReporter{},
+		MaxAttributeCombinations: 2,
+	}
+	subscription := "attribute-cardinality"
+	labels := prometheus.Labels{subscriptionLabel: subscription}
+	handle := func(attributes map[string]string) {
+		t.Helper()
+		pe := ProwJobEvent{Name: "test"}
+		m, err := pe.ToMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range attributes {
+			m.Attributes[k] = v
+		}
+		if err := s.handleMessage(context.Background(), &pubSubMessage{*m}, subscription, config.PubSubTrigger{AllowedClusters: []string{"*"}}); err != nil {
+			t.Fatalf("Failed to handle message: %v", err)
+		}
+	}
+	expectMetrics := func(combinations, overflow float64) {
+		t.Helper()
+		if got := testutil.ToFloat64(s.Metrics.AttributeCombinationsGauge.With(labels)); got != combinations {
+			t.Errorf("Expected %v attribute combinations, got %v", combinations, got)
+		}
+		if got := testutil.ToFloat64(s.Metrics.AttributeCombinationsOverflowCounter.With(labels)); got != overflow {
+			t.Errorf("Expected %v overflowing messages, got %v", overflow, got)
+		}
+	}
+
+	handle(map[string]string{"team": "infra"})
+	handle(map[string]string{"team": "infra"})
+	expectMetrics(1, 0)
+	handle(map[string]string{"team": "release"})
+	expectMetrics(2, 0)
+	// Past the cap, new combinations go to the overflow bucket.
+	handle(map[string]string{"team": "release", "env": "prod"})
+	handle(map[string]string{"team": "docs"})
+	expectMetrics(2, 2)
+	// Tracked combinations don't overflow.
+	handle(map[string]string{"team": "infra"})
+	expectMetrics(2, 2)
+}
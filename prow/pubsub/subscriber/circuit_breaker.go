@@ -0,0 +1,166 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	prowcrd "sigs.k8s.io/prow/prow/apis/prowjobs/v1"
+	"sigs.k8s.io/prow/prow/gangway"
+)
+
+// errCircuitOpen is returned for messages handled while the circuit breaker is
+// open, which are nacked so that they are redelivered once it closes.
+var errCircuitOpen = errors.New("ProwJob creation is paused by the circuit breaker")
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// CircuitBreaker stops creating ProwJobs after consecutive creation failures,
+// so that an unhealthy API server isn't hammered with requests.
+//
+// Once FailureThreshold consecutive creations failed, the breaker opens and no
+// ProwJobs are created for the Cooldown. It then half-opens, letting a single
+// creation through: the breaker closes if it succeeds and opens again otherwise.
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failures opening the breaker.
+	FailureThreshold int
+	// Cooldown is how long the breaker stays open before half-opening.
+	Cooldown time.Duration
+
+	lock     sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	// trial is set while the single creation of the half-open breaker is in flight.
+	trial bool
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+func (b *CircuitBreaker) clock() time.Time {
+	if b.now == nil {
+		return time.Now()
+	}
+	return b.now()
+}
+
+// allow returns whether a ProwJob may be created. Every allowed creation must
+// be followed by a call to done. A nil breaker allows all creations.
+func (b *CircuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	switch b.state {
+	case circuitOpen:
+		if b.clock().Sub(b.openedAt) < b.Cooldown {
+			return false
+		}
+		b.state = circuitHalfOpen
+		b.trial = true
+		return true
+	case circuitHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+	return true
+}
+
+// done records the result of an allowed creation. attempted is false if no
+// creation request was sent, e.g. because the job was not found, in which case
+// the state of the breaker is unchanged.
+func (b *CircuitBreaker) done(attempted bool, err error) {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.state == circuitHalfOpen {
+		b.trial = false
+	}
+	if !attempted {
+		return
+	}
+	if !isServerFailure(err) {
+		b.state = circuitClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.FailureThreshold {
+		b.state = circuitOpen
+		b.openedAt = b.clock()
+	}
+}
+
+// isOpen returns whether the breaker is open or half-open.
+func (b *CircuitBreaker) isOpen() bool {
+	if b == nil {
+		return false
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state != circuitClosed
+}
+
+// isServerFailure returns whether the creation error indicates an unhealthy API
+// server, rather than a rejected ProwJob.
+func isServerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var status kerrors.APIStatus
+	if !errors.As(err, &status) {
+		// Connection errors and the like.
+		return true
+	}
+	return kerrors.IsInternalError(err) || kerrors.IsServerTimeout(err) || kerrors.IsTimeout(err) ||
+		kerrors.IsServiceUnavailable(err) || kerrors.IsTooManyRequests(err)
+}
+
+// attemptProwJobClient records whether a creation request was sent and its
+// result, including the created ProwJob. No request is sent once ctx is done.
+type attemptProwJobClient struct {
+	gangway.ProwJobClient
+	attempted bool
+	created   *prowcrd.ProwJob
+	err       error
+}
+
+func (c *attemptProwJobClient) Create(ctx context.Context, pj *prowcrd.ProwJob, opts metav1.CreateOptions) (*prowcrd.ProwJob, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	created, err := c.ProwJobClient.Create(ctx, pj, opts)
+	c.attempted, c.created, c.err = true, created, err
+	return created, err
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clienttesting "k8s.io/client-go/testing"
+
+	"sigs.k8s.io/prow/prow/client/clientset/versioned/fake"
+	"sigs.k8s.io/prow/prow/config"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	serverErr := kerrors.NewInternalError(errors.New("etcd is down"))
+	now := time.Now()
+	b := &CircuitBreaker{FailureThreshold: 2, Cooldown: time.Minute, now: func() time.Time { return now }}
+	create := func(err error) {
+		t.Helper()
+		if !b.allow() {
+			t.Fatal("Expected creation to be allowed")
+		}
+		b.done(true, err)
+	}
+	expectState := func(expected circuitState) {
+		t.Helper()
+		if b.state != expected {
+			t.Fatalf("Expected state %d, got %d", expected, b.state)
+		}
+	}
+
+	create(serverErr)
+	expectState(circuitClosed)
+	create(nil)
+	create(serverErr)
+	expectState(circuitClosed)
+	// Rejected jobs don't count as failures.
+	create(kerrors.NewInvalid(schema.GroupKind{Kind: "ProwJob"}, "job", nil))
+	create(serverErr)
+	expectState(circuitClosed)
+	create(serverErr)
+	expectState(circuitOpen)
+	if b.allow() {
+		t.Fatal("Expected creation to be denied while open")
+	}
+
+	now = now.Add(time.Minute)
+	create(serverErr)
+	expectState(circuitOpen)
+	if b.allow() {
+		t.Fatal("Expected creation to be denied after the trial failed")
+	}
+
+	now = now.Add(time.Minute)
+	if !b.allow() {
+		t.Fatal("Expected the trial creation to be allowed after the cooldown")
+	}
+	expectState(circuitHalfOpen)
+	if b.allow() {
+		t.Fatal("Expected creation to be denied while the trial is in flight")
+	}
+	// The trial didn't reach the API server.
+	b.done(false, nil)
+	expectState(circuitHalfOpen)
+	create(nil)
+	expectState(circuitClosed)
+	if !b.allow() {
+		t.Fatal("Expected creation to be allowed once closed")
+	}
+}
+
+func TestHandleMessageCircuitBreaker(t *testing.T) {
+	c := &config.Config{
+		JobConfig: config.JobConfig{
+			Periodics: []config.Periodic{{JobBase: config.JobBase{Name: "test"}}},
+		},
+	}
+	c.ProwJobNamespace = "prowjobs"
+	ca := &config.Agent{}
+	ca.Set(c)
+	fakeProwJobClient := fake.NewSimpleClientset()
+	failing := true
+	fakeProwJobClient.PrependReactor("create", "prowjobs", func(clienttesting.Action) (bool, runtime.Object, error) {
+		if failing {
+			return true, nil, kerrors.NewServiceUnavailable("apiserver is overloaded")
+		}
+		return false, nil, nil
+	})
+	now := time.Now()
+	s := Subscriber{
+		Metrics:        NewMetrics(),
+		ProwJobClient:  fakeProwJobClient.ProwV1().ProwJobs(c.ProwJobNamespace),
+		ConfigAgent:    ca,
+		Reporter:       &fakeReporter{},
+		CircuitBreaker: &CircuitBreaker{FailureThreshold: 2, Cooldown: time.Minute, now: func() time.Time { return now }},
+	}
+	handle := func() error {
+		pe := ProwJobEvent{Name: "test"}
+		m, err := pe.ToMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.handleMessage(context.Background(), &pubSubMessage{*m}, "sub", config.PubSubTrigger{AllowedClusters: []string{"*"}})
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := handle(); err == nil || errors.Is(err, errCircuitOpen) {
+			t.Fatalf("Expected creation to fail, got %v", err)
+		}
+	}
+	if open := testutil.ToFloat64(s.Metrics.CircuitBreakerOpenGauge); open != 1 {
+		t.Errorf("Expected the circuit breaker to be reported open, got %v", open)
+	}
+	failing = false
+	if err := handle(); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("Expected the circuit breaker to be open, got %v", err)
+	}
+	pjs, err := fakeProwJobClient.ProwV1().ProwJobs(c.ProwJobNamespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list Prow Jobs: %v", err)
+	}
+	if len(pjs.Items) != 0 {
+		t.Errorf("Expected no Prow Jobs while the circuit breaker is open, got %d", len(pjs.Items))
+	}
+
+	now = now.Add(time.Minute)
+	if err := handle(); err != nil {
+		t.Fatalf("Expected the trial creation to succeed, got %v", err)
+	}
+	if open := testutil.ToFloat64(s.Metrics.CircuitBreakerOpenGauge); open != 0 {
+		t.Errorf("Expected the circuit breaker to be reported closed, got %v", open)
+	}
+}
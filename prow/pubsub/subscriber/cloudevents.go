@@ -0,0 +1,100 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subscriber
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	// CloudEventTypeAttribute is the message attribute holding the type of
+	// CloudEvents published in binary content mode, whose payload is the data
+	// of the event.
+	CloudEventTypeAttribute = "ce-type"
+	// CloudEventsContentType is the content-type attribute of CloudEvents
+	// published in structured content mode, whose payload is the JSON envelope
+	// of the event.
+	CloudEventsContentType = "application/cloudevents+json"
+
+	contentTypeAttribute = "content-type"
+)
+
+// cloudEventEnvelope is a CloudEvent in structured content mode.
+type cloudEventEnvelope struct {
+	SpecVersion string          `json:"specversion"`
+	Type        string          `json:"type"`
+	Data        json.RawMessage `json:"data,omitempty"`
+	DataBase64  string          `json:"data_base64,omitempty"`
+}
+
+// normalizedMessage is the event type and ProwJobEvent payload of a message,
+// whichever format it was published in.
+type normalizedMessage struct {
+	// eventType is empty if the message doesn't carry one.
+	eventType string
+	payload   []byte
+}
+
+// normalizeMessage extracts the event type and ProwJobEvent payload of a
+// message. The prow.k8s.io/pubsub.EventType attribute takes precedence, then
+// the type of CloudEvents, in binary or structured content mode. Payloads of
+// other messages are returned as is.
+func normalizeMessage(attrs map[string]string, payload []byte) (normalizedMessage, error) {
+	if eventType, ok := attrs[ProwEventType]; ok {
+		return normalizedMessage{eventType: eventType, payload: payload}, nil
+	}
+	if eventType, ok := attrs[CloudEventTypeAttribute]; ok {
+		return normalizedMessage{eventType: eventType, payload: payload}, nil
+	}
+	envelope, ok := parseCloudEventEnvelope(attrs, payload)
+	if !ok {
+		return normalizedMessage{payload: payload}, nil
+	}
+	if envelope.Type == "" {
+		return normalizedMessage{}, errors.New("CloudEvent has no type")
+	}
+	data := []byte(envelope.Data)
+	if envelope.DataBase64 != "" {
+		decoded, err := base64.StdEncoding.DecodeString(envelope.DataBase64)
+		if err != nil {
+			return normalizedMessage{}, fmt.Errorf("CloudEvent has invalid data_base64: %w", err)
+		}
+		data = decoded
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return normalizedMessage{}, errors.New("CloudEvent has no data")
+	}
+	return normalizedMessage{eventType: envelope.Type, payload: data}, nil
+}
+
+// parseCloudEventEnvelope returns the CloudEvent of a message in structured
+// content mode, identified by its content-type attribute or the specversion of
+// its payload, and false for other messages.
+func parseCloudEventEnvelope(attrs map[string]string, payload []byte) (cloudEventEnvelope, bool) {
+	var envelope cloudEventEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return cloudEventEnvelope{}, false
+	}
+	if attrs[contentTypeAttribute] != CloudEventsContentType && envelope.SpecVersion == "" {
+		return cloudEventEnvelope{}, false
+	}
+	return envelope, true
+}
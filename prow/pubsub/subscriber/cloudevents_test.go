@@ -0,0 +1,169 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subscriber
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/prow/prow/client/clientset/versioned/fake"
+	"sigs.k8s.io/prow/prow/config"
+)
+
+func TestNormalizeMessage(t *testing.T) {
+	event := `{"name":"test"}`
+	for _, tc := range []struct {
+		name      string
+		attrs     map[string]string
+		payload   string
+		eventType string
+		expected  string
+		err       string
+	}{
+		{
+			name:      "ProwEventType",
+			attrs:     map[string]string{ProwEventType: PeriodicProwJobEvent},
+			payload:   event,
+			eventType: PeriodicProwJobEvent,
+			expected:  event,
+		},
+		{
+			name:      "ProwEventTypeTakesPrecedence",
+			attrs:     map[string]string{ProwEventType: PeriodicProwJobEvent, CloudEventTypeAttribute: PostsubmitProwJobEvent},
+			payload:   event,
+			eventType: PeriodicProwJobEvent,
+			expected:  event,
+		},
+		{
+			name:     "NoEventType",
+			payload:  event,
+			expected: event,
+		},
+		{
+			name:      "BinaryCloudEvent",
+			attrs:     map[string]string{CloudEventTypeAttribute: PeriodicProwJobEvent, "ce-specversion": "1.0"},
+			payload:   event,
+			eventType: PeriodicProwJobEvent,
+			expected:  event,
+		},
+		{
+			name:      "StructuredCloudEvent",
+			payload:   `{"specversion":"1.0","type":"prow.k8s.io/pubsub.PeriodicProwJobEvent","source":"ci","id":"1","data":{"name":"test"}}`,
+			eventType: PeriodicProwJobEvent,
+			expected:  event,
+		},
+		{
+			name:      "StructuredCloudEventByContentType",
+			attrs:     map[string]string{"content-type": CloudEventsContentType},
+			payload:   `{"type":"prow.k8s.io/pubsub.PeriodicProwJobEvent","data":{"name":"test"}}`,
+			eventType: PeriodicProwJobEvent,
+			expected:  event,
+		},
+		{
+			name:      "StructuredCloudEventWithBase64Data",
+			payload:   `{"specversion":"1.0","type":"prow.k8s.io/pubsub.PeriodicProwJobEvent","data_base64":"` + base64.StdEncoding.EncodeToString([]byte(event)) + `"}`,
+			eventType: PeriodicProwJobEvent,
+			expected:  event,
+		},
+		{
+			name:    "StructuredCloudEventWithoutType",
+			payload: `{"specversion":"1.0","data":{"name":"test"}}`,
+			err:     "CloudEvent has no type",
+		},
+		{
+			name:    "StructuredCloudEventWithoutData",
+			payload: `{"specversion":"1.0","type":"prow.k8s.io/pubsub.PeriodicProwJobEvent"}`,
+			err:     "CloudEvent has no data",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			normalized, err := normalizeMessage(tc.attrs, []byte(tc.payload))
+			var gotErr string
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if gotErr != tc.err {
+				t.Fatalf("Expected error '%v' got '%v'", tc.err, gotErr)
+			}
+			if normalized.eventType != tc.eventType {
+				t.Errorf("Expected event type %q, got %q", tc.eventType, normalized.eventType)
+			}
+			if string(normalized.payload) != tc.expected {
+				t.Errorf("Expected payload %s, got %s", tc.expected, normalized.payload)
+			}
+		})
+	}
+}
+
+func TestHandleMessageCloudEvents(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		msg  pubsub.Message
+	}{
+		{
+			name: "BinaryContentMode",
+			msg: pubsub.Message{
+				ID:         "binary",
+				Data:       []byte(`{"name":"test"}`),
+				Attributes: map[string]string{CloudEventTypeAttribute: PeriodicProwJobEvent, "ce-specversion": "1.0"},
+			},
+		},
+		{
+			name: "StructuredContentMode",
+			msg: pubsub.Message{
+				ID:         "structured",
+				Data:       []byte(`{"specversion":"1.0","type":"prow.k8s.io/pubsub.PeriodicProwJobEvent","source":"ci","id":"1","data":{"name":"test"}}`),
+				Attributes: map[string]string{"content-type": CloudEventsContentType},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &config.Config{
+				JobConfig: config.JobConfig{
+					Periodics: []config.Periodic{{JobBase: config.JobBase{Name: "test"}}},
+				},
+			}
+			c.ProwJobNamespace = "prowjobs"
+			ca := &config.Agent{}
+			ca.Set(c)
+			fakeProwJobClient := fake.NewSimpleClientset()
+			s := Subscriber{
+				Metrics:       NewMetrics(),
+				ProwJobClient: fakeProwJobClient.ProwV1().ProwJobs(c.ProwJobNamespace),
+				ConfigAgent:   ca,
+				Reporter:      &fakeReporter{},
+			}
+			if err := s.handleMessage(context.Background(), &pubSubMessage{tc.msg}, "", config.PubSubTrigger{AllowedClusters: []string{"*"}}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			pjs, err := fakeProwJobClient.ProwV1().ProwJobs(c.ProwJobNamespace).List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Failed to list Prow Jobs: %v", err)
+			}
+			if len(pjs.Items) != 1 {
+				t.Fatalf("Expected one Prow Job, got %d", len(pjs.Items))
+			}
+			if job := pjs.Items[0].Spec.Job; job != "test" {
+				t.Errorf("Expected a Prow Job of test, got %s", job)
+			}
+		})
+	}
+}
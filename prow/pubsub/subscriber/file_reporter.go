@@ -0,0 +1,89 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subscriber
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	prowcrd "sigs.k8s.io/prow/prow/apis/prowjobs/v1"
+	reporter "sigs.k8s.io/prow/prow/crier/reporters/pubsub"
+)
+
+// FileReport is the record the FileReporter writes for every reported ProwJob.
+type FileReport struct {
+	ProwJob string               `json:"prowjob"`
+	JobName string               `json:"job_name"`
+	JobType prowcrd.ProwJobType  `json:"job_type"`
+	Status  prowcrd.ProwJobState `json:"status"`
+	Message string               `json:"message,omitempty"`
+	Payload string               `json:"payload,omitempty"`
+}
+
+// FileReporter reports whether ProwJobs were created by appending a JSON
+// FileReport per line to a file, instead of publishing to Pub/Sub, e.g. for
+// integration tests and air-gapped environments.
+type FileReporter struct {
+	path string
+	lock sync.Mutex
+}
+
+// NewFileReporter creates a FileReporter appending to the file at path,
+// which is created if it doesn't exist.
+func NewFileReporter(path string) *FileReporter {
+	return &FileReporter{path: path}
+}
+
+// ShouldReport returns true, as all ProwJobs are reported to the file.
+func (r *FileReporter) ShouldReport(_ context.Context, _ *logrus.Entry, _ *prowcrd.ProwJob) bool {
+	return true
+}
+
+// Report appends a FileReport for the ProwJob to the file.
+func (r *FileReporter) Report(_ context.Context, _ *logrus.Entry, pj *prowcrd.ProwJob) ([]*prowcrd.ProwJob, *reconcile.Result, error) {
+	line, err := json.Marshal(FileReport{
+		ProwJob: pj.Name,
+		JobName: pj.Spec.Job,
+		JobType: pj.Spec.Type,
+		Status:  pj.Status.State,
+		Message: pj.Status.Description,
+		Payload: pj.Annotations[reporter.PubSubPayloadLabel],
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not marshal report: %w", err)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open report file: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("could not write report: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, nil, fmt.Errorf("could not close report file: %w", err)
+	}
+	return []*prowcrd.ProwJob{pj}, nil, nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subscriber
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	prowapi "sigs.k8s.io/prow/prow/apis/prowjobs/v1"
+	"sigs.k8s.io/prow/prow/client/clientset/versioned/fake"
+	"sigs.k8s.io/prow/prow/config"
+)
+
+func TestFileReporter(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		job      string
+		expected FileReport
+	}{
+		{
+			name: "CreatedJob",
+			job:  "test",
+			expected: FileReport{
+				JobName: "test",
+				JobType: prowapi.PeriodicJob,
+				Status:  prowapi.TriggeredState,
+				Message: "Successfully triggered prowjob.",
+			},
+		},
+		{
+			name: "FailedJob",
+			job:  "missing",
+			expected: FileReport{
+				Status:  prowapi.ErrorState,
+				Message: "Failed creating prowjob: failed to find associated periodic job \"missing\"",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &config.Config{
+				JobConfig: config.JobConfig{
+					Periodics: []config.Periodic{{JobBase: config.JobBase{Name: "test"}}},
+				},
+			}
+			c.ProwJobNamespace = "prowjobs"
+			ca := &config.Agent{}
+			ca.Set(c)
+			path := filepath.Join(t.TempDir(), "reports.json")
+			s := Subscriber{
+				Metrics:       NewMetrics(),
+				ProwJobClient: fake.NewSimpleClientset().ProwV1().ProwJobs(c.ProwJobNamespace),
+				ConfigAgent:   ca,
+				Reporter:      NewFileReporter(path),
+			}
+			pe := ProwJobEvent{Name: tc.job}
+			m, err := pe.ToMessage()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Report twice to ensure records are appended.
+			for i := 0; i < 2; i++ {
+				s.handleMessage(context.Background(), &pubSubMessage{*m}, "", config.PubSubTrigger{AllowedClusters: []string{"*"}})
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatalf("Failed to open report file: %v", err)
+			}
+			defer f.Close()
+			var reports []FileReport
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				var report FileReport
+				if err := json.Unmarshal(scanner.Bytes(), &report); err != nil {
+					t.Fatalf("Failed to unmarshal report %q: %v", scanner.Text(), err)
+				}
+				reports = append(reports, report)
+			}
+			if len(reports) != 2 {
+				t.Fatalf("Expected 2 reports, got %d: %v", len(reports), reports)
+			}
+			for _, report := range reports {
+				if tc.expected.Status == prowapi.TriggeredState && report.ProwJob == "" {
+					t.Errorf("Expected report of the created Prow Job to name it, got %+v", report)
+				}
+				report.ProwJob = ""
+				if report != tc.expected {
+					t.Errorf("Expected report %+v, got %+v", tc.expected, report)
+				}
+			}
+		})
+	}
+}
@@ -26,6 +26,8 @@ const (
 	// The value of "failed-handle-prowjob" is the only case where prow operator
 	// should care
 	errorTypeLabel = "error_type"
+	eventTypeLabel = "event_type"
+	resultLabel    = "result"
 )
 
 var (
@@ -40,6 +42,26 @@ var (
 		Name: "prow_pubsub_error_counter",
 		Help: "A counter of the webhooks made to prow.",
 	}, []string{subscriptionLabel, errorTypeLabel})
+	eventTypeCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "prow_pubsub_event_type_counter",
+		Help: "A counter of handled messages by event type and whether handling them succeeded.",
+	}, []string{subscriptionLabel, eventTypeLabel, resultLabel})
+	slowMessageCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "prow_pubsub_slow_message_counter",
+		Help: "A counter of messages whose handling took longer than the processing_slo of their trigger.",
+	}, []string{subscriptionLabel})
+	attributeCombinationsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "prow_pubsub_attribute_combinations",
+		Help: "The number of distinct combinations of message attribute values seen per subscription, up to the configured cap.",
+	}, []string{subscriptionLabel})
+	attributeCombinationsOverflowCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "prow_pubsub_attribute_combinations_overflow",
+		Help: "A counter of messages with a new combination of attribute values seen after the cap of tracked combinations was reached.",
+	}, []string{subscriptionLabel})
+	circuitBreakerOpenGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "prow_pubsub_circuit_breaker_open",
+		Help: "Whether ProwJob creation is paused by the circuit breaker after consecutive failures, 1 if open or half-open.",
+	})
 
 	// Pull Server
 	ackedMessagesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
@@ -50,33 +72,94 @@ var (
 		Name: "prow_pubsub_nack_counter",
 		Help: "A counter for message nacked made to prow.",
 	}, []string{subscriptionLabel})
+	deliveryAttemptsHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "prow_pubsub_delivery_attempts",
+		Help:    "A histogram of how often the pulled messages were delivered, for subscriptions with a dead letter policy.",
+		Buckets: []float64{1, 2, 3, 5, 10, 20, 50},
+	}, []string{subscriptionLabel})
+	subscriptionLags             = &subscriptionLag{}
+	configuredSubscriptionsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "prow_pubsub_configured_subscriptions",
+		Help: "The number of subscriptions the pull server is configured to pull.",
+	})
+	connectedSubscriptionsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "prow_pubsub_connected_subscriptions",
+		Help: "The number of subscriptions the pull server is currently pulling.",
+	})
 
 	// Push Server
 	responseCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "prow_pubsub_response_codes",
 		Help: "A counter of the different responses server has responded to Push Events with.",
 	}, []string{responseCodeLabel, subscriptionLabel})
+
+	// Config
+	configReloadFailureCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "prow_pubsub_config_reload_failures",
+		Help: "A counter of failed config reloads, sub keeps running on the previous config when they fail.",
+	})
+	lastConfigReloadGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "prow_pubsub_last_successful_config_reload_timestamp_seconds",
+		Help: "Unix timestamp of the last successful config reload.",
+	})
 )
 
 func init() {
 	prometheus.MustRegister(messageCounter)
 	prometheus.MustRegister(responseCounter)
 	prometheus.MustRegister(errorCounter)
+	prometheus.MustRegister(eventTypeCounter)
+	prometheus.MustRegister(slowMessageCounter)
+	prometheus.MustRegister(attributeCombinationsGauge)
+	prometheus.MustRegister(attributeCombinationsOverflowCounter)
+	prometheus.MustRegister(circuitBreakerOpenGauge)
 	prometheus.MustRegister(ackedMessagesCounter)
 	prometheus.MustRegister(nackedMessagesCounter)
+	prometheus.MustRegister(deliveryAttemptsHistogram)
+	prometheus.MustRegister(subscriptionLags)
+	prometheus.MustRegister(configuredSubscriptionsGauge)
+	prometheus.MustRegister(connectedSubscriptionsGauge)
+	prometheus.MustRegister(configReloadFailureCounter)
+	prometheus.MustRegister(lastConfigReloadGauge)
 }
 
 type Metrics struct {
 	// Common
 	MessageCounter *prometheus.CounterVec
 	ErrorCounter   *prometheus.CounterVec
+	// EventTypeCounter counts handled messages by event type and result.
+	EventTypeCounter *prometheus.CounterVec
+	// SlowMessageCounter counts messages exceeding the ProcessingSLO of their trigger.
+	SlowMessageCounter *prometheus.CounterVec
+	// AttributeCombinationsGauge is the number of distinct attribute combinations
+	// tracked per subscription.
+	AttributeCombinationsGauge *prometheus.GaugeVec
+	// AttributeCombinationsOverflowCounter counts messages with new attribute
+	// combinations past the tracking cap.
+	AttributeCombinationsOverflowCounter *prometheus.CounterVec
+	// CircuitBreakerOpenGauge is 1 while ProwJob creation is paused by the circuit breaker.
+	CircuitBreakerOpenGauge prometheus.Gauge
 
 	// Pull Server
 	ACKMessageCounter  *prometheus.CounterVec
 	NACKMessageCounter *prometheus.CounterVec
+	// DeliveryAttemptsHistogram observes how often pulled messages were delivered.
+	DeliveryAttemptsHistogram *prometheus.HistogramVec
+	// ConfiguredSubscriptionsGauge is the number of subscriptions selected for pulling.
+	ConfiguredSubscriptionsGauge prometheus.Gauge
+	// ConnectedSubscriptionsGauge is the number of subscriptions being pulled.
+	ConnectedSubscriptionsGauge prometheus.Gauge
 
 	// Push Server
 	ResponseCounter *prometheus.CounterVec
+
+	// Config
+	ConfigReloadFailureCounter prometheus.Counter
+	LastConfigReloadGauge      prometheus.Gauge
+
+	// subscriptionLag tracks the messages being handled per subscription to
+	// report the age of the oldest of them when scraped.
+	subscriptionLag *subscriptionLag
 }
 
 func NewMetrics() *Metrics {
@@ -84,7 +167,31 @@ func NewMetrics() *Metrics {
 		MessageCounter:     messageCounter,
 		ResponseCounter:    responseCounter,
 		ErrorCounter:       errorCounter,
+		SlowMessageCounter: slowMessageCounter,
+		EventTypeCounter:   eventTypeCounter,
 		ACKMessageCounter:  ackedMessagesCounter,
 		NACKMessageCounter: nackedMessagesCounter,
+
+		AttributeCombinationsGauge:           attributeCombinationsGauge,
+		AttributeCombinationsOverflowCounter: attributeCombinationsOverflowCounter,
+		CircuitBreakerOpenGauge:              circuitBreakerOpenGauge,
+
+		DeliveryAttemptsHistogram:    deliveryAttemptsHistogram,
+		ConfiguredSubscriptionsGauge: configuredSubscriptionsGauge,
+		ConnectedSubscriptionsGauge:  connectedSubscriptionsGauge,
+
+		ConfigReloadFailureCounter: configReloadFailureCounter,
+		LastConfigReloadGauge:      lastConfigReloadGauge,
+
+		subscriptionLag: subscriptionLags,
+	}
+}
+
+// RecordConfigReload is a config.ReloadHook recording the result of a config reload.
+func (m *Metrics) RecordConfigReload(err error) {
+	if err != nil {
+		m.ConfigReloadFailureCounter.Inc()
+		return
 	}
+	m.LastConfigReloadGauge.SetToCurrentTime()
 }
@@ -0,0 +1,114 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subscriber
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// connectivityCheckTimeout bounds how long a connectivity check may take.
+const connectivityCheckTimeout = 10 * time.Second
+
+// ConnectivityProbe is an HTTP handler reporting whether sub can reach Pub/Sub,
+// by checking that the first subscription pulled by the server exists. The
+// result is cached for the interval, so that frequent probes don't cause
+// excessive API calls.
+type ConnectivityProbe struct {
+	server   *PullServer
+	interval time.Duration
+	// newClient creates the clients of the probe, which are separate from the
+	// ones of the server.
+	newClient func() pubsubClientInterface
+
+	lock    sync.Mutex
+	clients map[string]pubsubClientInterface
+	checked time.Time
+	err     error
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+// NewConnectivityProbe creates a ConnectivityProbe for the subscriptions of the
+// server, checking connectivity at most once per interval.
+func NewConnectivityProbe(s *PullServer, interval time.Duration) *ConnectivityProbe {
+	return &ConnectivityProbe{
+		server:    s,
+		interval:  interval,
+		newClient: func() pubsubClientInterface { return &pubSubClient{} },
+		now:       time.Now,
+	}
+}
+
+// ServeHTTP responds with 200 if Pub/Sub could be reached, and 503 otherwise.
+func (p *ConnectivityProbe) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.lock.Lock()
+	if p.checked.IsZero() || p.now().Sub(p.checked) >= p.interval {
+		ctx, cancel := context.WithTimeout(r.Context(), connectivityCheckTimeout)
+		p.err = p.check(ctx)
+		cancel()
+		p.checked = p.now()
+		if p.err != nil {
+			logrus.WithError(p.err).Warn("Pub/Sub connectivity check failed.")
+		}
+	}
+	err := p.err
+	p.lock.Unlock()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprint(w, "OK")
+}
+
+// check returns an error if the first pulled subscription can't be reached.
+// It succeeds if no subscriptions are pulled.
+func (p *ConnectivityProbe) check(ctx context.Context) error {
+	for _, trigger := range p.server.Subscriber.ConfigAgent.Config().PubSubTriggers {
+		subscriptions := p.server.selectSubscriptions(trigger.Topics)
+		if len(subscriptions) == 0 {
+			continue
+		}
+		client, ok := p.clients[trigger.Project]
+		if !ok {
+			var err error
+			if client, err = p.newClient().new(ctx, trigger.Project); err != nil {
+				return fmt.Errorf("failed to create Pub/Sub client for project %s: %w", trigger.Project, err)
+			}
+			if p.clients == nil {
+				p.clients = map[string]pubsubClientInterface{}
+			}
+			p.clients[trigger.Project] = client
+		}
+		sub := client.subscription(subscriptions[0], trigger.MaxOutstandingMessages)
+		exists, err := sub.exists(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to reach Pub/Sub: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("subscription %s does not exist", sub.string())
+		}
+		return nil
+	}
+	return nil
+}
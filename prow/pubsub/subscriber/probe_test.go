@@ -0,0 +1,150 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/prow/prow/config"
+)
+
+// probedPubSubClient reports whether its subscriptions exist, and counts the checks.
+type probedPubSubClient struct {
+	newErr    error
+	existsErr error
+	exists    bool
+	checked   []string
+}
+
+type probedSubscription struct {
+	name   string
+	client *probedPubSubClient
+}
+
+func (s *probedSubscription) string() string {
+	return s.name
+}
+
+func (s *probedSubscription) receive(ctx context.Context, f func(context.Context, messageInterface)) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *probedSubscription) exists(ctx context.Context) (bool, error) {
+	s.client.checked = append(s.client.checked, s.name)
+	return s.client.exists, s.client.existsErr
+}
+
+func (c *probedPubSubClient) new(ctx context.Context, project string) (pubsubClientInterface, error) {
+	return c, c.newErr
+}
+
+func (c *probedPubSubClient) subscription(id string, maxOutstandingMessages int) subscriptionInterface {
+	return &probedSubscription{name: id, client: c}
+}
+
+func TestConnectivityProbe(t *testing.T) {
+	triggers := config.PubSubTriggers{
+		{Project: "project-a", Topics: []string{"other"}},
+		{Project: "project-b", Topics: []string{"prow-jobs", "prow-releases"}},
+	}
+	for _, tc := range []struct {
+		name          string
+		triggers      config.PubSubTriggers
+		client        probedPubSubClient
+		expectedCode  int
+		expectedCheck []string
+	}{
+		{
+			name:          "Healthy",
+			triggers:      triggers,
+			client:        probedPubSubClient{exists: true},
+			expectedCode:  http.StatusOK,
+			expectedCheck: []string{"prow-jobs"},
+		},
+		{
+			name:          "Unreachable",
+			triggers:      triggers,
+			client:        probedPubSubClient{existsErr: errors.New("connection refused")},
+			expectedCode:  http.StatusServiceUnavailable,
+			expectedCheck: []string{"prow-jobs"},
+		},
+		{
+			name:          "MissingSubscription",
+			triggers:      triggers,
+			client:        probedPubSubClient{exists: false},
+			expectedCode:  http.StatusServiceUnavailable,
+			expectedCheck: []string{"prow-jobs"},
+		},
+		{
+			name:         "ClientCreationFailure",
+			triggers:     triggers,
+			client:       probedPubSubClient{newErr: errors.New("no credentials")},
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:         "NoSubscriptions",
+			client:       probedPubSubClient{existsErr: errors.New("connection refused")},
+			expectedCode: http.StatusOK,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ca := &config.Agent{}
+			ca.Set(&config.Config{ProwConfig: config.ProwConfig{PubSubTriggers: tc.triggers}})
+			pullServer := &PullServer{
+				Subscriber:    &Subscriber{ConfigAgent: ca, Metrics: NewMetrics()},
+				Subscriptions: []string{"prow-*"},
+			}
+			now := time.Now()
+			probe := NewConnectivityProbe(pullServer, time.Minute)
+			probe.newClient = func() pubsubClientInterface { return &tc.client }
+			probe.now = func() time.Time { return now }
+			probeOnce := func() {
+				t.Helper()
+				rr := httptest.NewRecorder()
+				probe.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz/pubsub", nil))
+				if rr.Code != tc.expectedCode {
+					t.Errorf("Expected status %d, got %d: %s", tc.expectedCode, rr.Code, rr.Body.String())
+				}
+			}
+
+			probeOnce()
+			// Probes within the interval reuse the result.
+			now = now.Add(30 * time.Second)
+			probeOnce()
+			if len(tc.client.checked) != len(tc.expectedCheck) {
+				t.Errorf("Expected subscriptions %v to be checked, got %v", tc.expectedCheck, tc.client.checked)
+			}
+			// Probes after the interval check again.
+			now = now.Add(time.Minute)
+			probeOnce()
+			if len(tc.client.checked) != 2*len(tc.expectedCheck) {
+				t.Errorf("Expected subscriptions %v to be checked twice, got %v", tc.expectedCheck, tc.client.checked)
+			}
+			for _, checked := range tc.client.checked {
+				if checked != "prow-jobs" {
+					t.Errorf("Expected only the first pulled subscription to be checked, got %s", checked)
+				}
+			}
+		})
+	}
+}
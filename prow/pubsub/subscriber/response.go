@@ -0,0 +1,98 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subscriber
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/sirupsen/logrus"
+
+	prowcrd "sigs.k8s.io/prow/prow/apis/prowjobs/v1"
+	"sigs.k8s.io/prow/prow/config"
+)
+
+// ResponseMessageIDAttribute is the attribute of responses holding the ID of
+// the message they respond to.
+const ResponseMessageIDAttribute = "prow.k8s.io/pubsub.MessageID"
+
+const responsePublishTimeout = 10 * time.Second
+
+// TriggerResponse is published to the response topic of a trigger once the
+// ProwJob of a message is created, so that publishers can correlate their
+// message with the job.
+type TriggerResponse struct {
+	MessageID string `json:"message_id"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	UID       string `json:"uid"`
+}
+
+// ResponsePublisher publishes messages to Pub/Sub topics.
+type ResponsePublisher interface {
+	Publish(ctx context.Context, project, topic string, msg *pubsub.Message) error
+}
+
+// pubSubResponsePublisher publishes messages with a Pub/Sub client created for
+// each message, like the Pub/Sub reporter.
+type pubSubResponsePublisher struct{}
+
+func (pubSubResponsePublisher) Publish(ctx context.Context, project, topic string, msg *pubsub.Message) error {
+	client, err := pubsub.NewClient(ctx, project)
+	if err != nil {
+		return fmt.Errorf("could not create pubsub client: %w", err)
+	}
+	defer client.Close()
+	t := client.Topic(topic)
+	defer t.Stop()
+	_, err = t.Publish(ctx, msg).Get(ctx)
+	return err
+}
+
+// publishResponse publishes a TriggerResponse for the created ProwJob to the
+// response topic of the trigger, if it has one.
+func (s *Subscriber) publishResponse(ctx context.Context, l *logrus.Entry, trigger config.PubSubTrigger, msgID string, pj *prowcrd.ProwJob) error {
+	if trigger.ResponseTopic == "" {
+		return nil
+	}
+	data, err := json.Marshal(TriggerResponse{
+		MessageID: msgID,
+		Name:      pj.Name,
+		Namespace: pj.Namespace,
+		UID:       string(pj.UID),
+	})
+	if err != nil {
+		return err
+	}
+	publisher := s.ResponsePublisher
+	if publisher == nil {
+		publisher = pubSubResponsePublisher{}
+	}
+	ctx, cancel := context.WithTimeout(ctx, responsePublishTimeout)
+	defer cancel()
+	l.WithField("topic", trigger.ResponseTopic).Debug("Publishing the response.")
+	if err := publisher.Publish(ctx, trigger.Project, trigger.ResponseTopic, &pubsub.Message{
+		Data:       data,
+		Attributes: map[string]string{ResponseMessageIDAttribute: msgID},
+	}); err != nil {
+		return fmt.Errorf("failed to publish the response to %s/%s: %w", trigger.Project, trigger.ResponseTopic, err)
+	}
+	return nil
+}
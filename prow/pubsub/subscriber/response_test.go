@@ -0,0 +1,127 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subscriber
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/prow/prow/client/clientset/versioned/fake"
+	"sigs.k8s.io/prow/prow/config"
+)
+
+type fakeResponsePublisher struct {
+	published []string
+	messages  []*pubsub.Message
+	err       error
+}
+
+func (p *fakeResponsePublisher) Publish(_ context.Context, project, topic string, msg *pubsub.Message) error {
+	p.published = append(p.published, project+"/"+topic)
+	p.messages = append(p.messages, msg)
+	return p.err
+}
+
+func TestHandleMessageResponse(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		responseTopic string
+		publishErr    error
+		expected      []string
+	}{
+		{
+			name: "NoResponseTopic",
+		},
+		{
+			name:          "ResponseTopic",
+			responseTopic: "responses",
+			expected:      []string{"project/responses"},
+		},
+		{
+			name:          "FailingToPublishDoesNotFailTheMessage",
+			responseTopic: "responses",
+			publishErr:    errors.New("topic not found"),
+			expected:      []string{"project/responses"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &config.Config{
+				JobConfig: config.JobConfig{
+					Periodics: []config.Periodic{{JobBase: config.JobBase{Name: "test"}}},
+				},
+			}
+			c.ProwJobNamespace = "prowjobs"
+			ca := &config.Agent{}
+			ca.Set(c)
+			fakeProwJobClient := fake.NewSimpleClientset()
+			publisher := &fakeResponsePublisher{err: tc.publishErr}
+			s := Subscriber{
+				Metrics:           NewMetrics(),
+				ProwJobClient:     fakeProwJobClient.ProwV1().ProwJobs(c.ProwJobNamespace),
+				ConfigAgent:       ca,
+				Reporter:          &fakeReporter{},
+				ResponsePublisher: publisher,
+			}
+			pe := ProwJobEvent{Name: "test"}
+			m, err := pe.ToMessage()
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.ID = "message-id"
+			trigger := config.PubSubTrigger{Project: "project", AllowedClusters: []string{"*"}, ResponseTopic: tc.responseTopic}
+			if err := s.handleMessage(context.Background(), &pubSubMessage{*m}, "", trigger); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(tc.expected, publisher.published) {
+				t.Fatalf("Expected responses published to %v, got %v", tc.expected, publisher.published)
+			}
+			if len(tc.expected) == 0 {
+				return
+			}
+			pjs, err := fakeProwJobClient.ProwV1().ProwJobs(c.ProwJobNamespace).List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Failed to list Prow Jobs: %v", err)
+			}
+			if len(pjs.Items) != 1 {
+				t.Fatalf("Expected one Prow Job, got %d", len(pjs.Items))
+			}
+			msg := publisher.messages[0]
+			if id := msg.Attributes[ResponseMessageIDAttribute]; id != "message-id" {
+				t.Errorf("Expected the response to carry the message ID, got %q", id)
+			}
+			var response TriggerResponse
+			if err := json.Unmarshal(msg.Data, &response); err != nil {
+				t.Fatalf("Failed to unmarshal the response: %v", err)
+			}
+			expected := TriggerResponse{
+				MessageID: "message-id",
+				Name:      pjs.Items[0].Name,
+				Namespace: "prowjobs",
+				UID:       string(pjs.Items[0].UID),
+			}
+			if response != expected {
+				t.Errorf("Expected response %+v, got %+v", expected, response)
+			}
+		})
+	}
+}
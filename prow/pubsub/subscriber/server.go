@@ -19,17 +19,29 @@ package subscriber
 import (
 	"context"
 	"errors"
+	"net/url"
+	"path/filepath"
 	"reflect"
-	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"cloud.google.com/go/pubsub"
 	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/prow/prow/config"
+	"sigs.k8s.io/prow/prow/gangway"
+	"sigs.k8s.io/yaml"
 )
 
+// defaultPermissionDeniedRetryInterval is how often pulling a subscription
+// that sub lacks permission on is retried by default.
+const defaultPermissionDeniedRetryInterval = 5 * time.Minute
+
 type configToWatch struct {
 	config.PubSubTriggers
 	config.PubsubSubscriptions
@@ -39,6 +51,20 @@ type configToWatch struct {
 type PullServer struct {
 	Subscriber *Subscriber
 	Client     pubsubClientInterface
+	// Subscriptions limits the subscriptions that are pulled to the ones
+	// matching any of these glob patterns. All subscriptions are pulled when empty.
+	Subscriptions []string
+	// PermissionDeniedRetryInterval is how often pulling a subscription is
+	// retried after it was denied permission. Defaults to 5 minutes.
+	PermissionDeniedRetryInterval time.Duration
+	// GracePeriod is how long the messages being handled are waited for on
+	// shutdown, for triggers that don't configure their own drain_grace_period.
+	// They aren't waited for if zero.
+	GracePeriod time.Duration
+	// WorkerPoolSize is the number of messages of each subscription that are
+	// handled concurrently, by a fixed pool of workers. Messages are handled on
+	// a goroutine each if zero.
+	WorkerPoolSize int
 }
 
 // NewPullServer creates a new PullServer
@@ -53,6 +79,7 @@ func NewPullServer(s *Subscriber) *PullServer {
 type subscriptionInterface interface {
 	string() string
 	receive(ctx context.Context, f func(context.Context, messageInterface)) error
+	exists(ctx context.Context) (bool, error)
 }
 
 // pubsubClientInterface interfaces with Cloud Pub/Sub client for testing reason
@@ -81,6 +108,10 @@ func (s *pubSubSubscription) receive(ctx context.Context, f func(context.Context
 	return s.sub.Receive(ctx, g)
 }
 
+func (s *pubSubSubscription) exists(ctx context.Context) (bool, error) {
+	return s.sub.Exists(ctx)
+}
+
 // New creates new Cloud Pub/Sub Client
 func (c *pubSubClient) new(ctx context.Context, project string) (pubsubClientInterface, error) {
 	client, err := pubsub.NewClient(ctx, project)
@@ -110,8 +141,21 @@ func (c *pubSubClient) subscription(id string, maxOutstandingMessages int) subsc
 func (s *PullServer) handlePulls(ctx context.Context, projectSubscriptions config.PubSubTriggers) (*errgroup.Group, context.Context, error) {
 	// Since config might change we need be able to cancel the current run
 	errGroup, derivedCtx := errgroup.WithContext(ctx)
+	var configured int
 	for _, topics := range projectSubscriptions {
-		project, subscriptions, allowedClusters := topics.Project, topics.Topics, topics.AllowedClusters
+		topics := topics
+		project, subscriptions := topics.Project, s.selectSubscriptions(topics.Topics)
+		if len(subscriptions) == 0 {
+			logrus.WithField("project", project).Debug("No subscriptions selected for project, skipping")
+			continue
+		}
+		// Surface credential problems at startup rather than on the first message.
+		if _, err := s.Subscriber.inRepoConfigGetter(topics); err != nil {
+			return errGroup, derivedCtx, err
+		}
+		if _, err := s.Subscriber.triggerProwJobClient(topics); err != nil {
+			return errGroup, derivedCtx, err
+		}
 		client, err := s.Client.new(ctx, project)
 		if err != nil {
 			return errGroup, derivedCtx, err
@@ -122,36 +166,199 @@ func (s *PullServer) handlePulls(ctx context.Context, projectSubscriptions confi
 				"subscription": sub.string(),
 				"project":      project,
 			})
+			configured++
 			errGroup.Go(func() error {
-				logger.Info("Listening for subscription")
-				defer logger.Warn("Stopped Listening for subscription")
-				err := sub.receive(derivedCtx, func(ctx context.Context, msg messageInterface) {
-					if err = s.Subscriber.handleMessage(msg, sub.string(), allowedClusters); err != nil {
-						s.Subscriber.Metrics.ACKMessageCounter.With(prometheus.Labels{subscriptionLabel: sub.string()}).Inc()
-					} else {
-						s.Subscriber.Metrics.NACKMessageCounter.With(prometheus.Labels{subscriptionLabel: sub.string()}).Inc()
-					}
-					msg.ack()
-				})
-				if err != nil {
-					if errors.Is(derivedCtx.Err(), context.Canceled) {
-						logger.WithError(err).Debug("Exiting as context cancelled")
-						return nil
-					}
-					if strings.Contains(err.Error(), "code = PermissionDenied") {
-						logger.WithError(err).Warn("Seems like missing permission.")
-						return nil
-					}
-					logger.WithError(err).Error("Failed to listen for subscription")
-					return err
-				}
-				return nil
+				return s.receive(derivedCtx, logger, sub, topics)
 			})
 		}
 	}
+	s.Subscriber.Metrics.ConfiguredSubscriptionsGauge.Set(float64(configured))
 	return errGroup, derivedCtx, nil
 }
 
+// receive handles the messages of the subscription until ctx is cancelled.
+// Pulling is retried periodically while sub lacks permission on the
+// subscription, so that the other subscriptions keep being served meanwhile.
+func (s *PullServer) receive(ctx context.Context, logger *logrus.Entry, sub subscriptionInterface, trigger config.PubSubTrigger) error {
+	interval := s.PermissionDeniedRetryInterval
+	if interval == 0 {
+		interval = defaultPermissionDeniedRetryInterval
+	}
+	logger.Info("Listening for subscription")
+	defer logger.Warn("Stopped Listening for subscription")
+	for {
+		// The subscription counts as connected while it is being pulled.
+		s.Subscriber.Metrics.ConnectedSubscriptionsGauge.Inc()
+		// Pub/Sub cancels the context of the handled messages once ctx is, so
+		// they are handled on one outliving it for the drain grace period.
+		handlerCtx, cancelHandlers := drainContext(ctx, s.gracePeriod(trigger))
+		err := s.receiveUntilDrained(ctx, logger, trigger, func() error {
+			handle := func(_ context.Context, msg messageInterface) {
+				defer s.Subscriber.trackLag(sub.string(), msg)()
+				if attempt := msg.getDeliveryAttempt(); attempt > 0 {
+					s.Subscriber.Metrics.DeliveryAttemptsHistogram.With(prometheus.Labels{subscriptionLabel: sub.string()}).Observe(float64(attempt))
+				}
+				if err := s.Subscriber.handleMessage(handlerCtx, msg, sub.string(), trigger); errors.Is(err, errMaintenanceWindow) || errors.Is(err, errCircuitOpen) || errors.Is(err, errMessageCancelled) || errors.Is(err, gangway.ErrJobPaused) {
+					// Have Pub/Sub redeliver the message once the window is over,
+					// the circuit breaker closed, the job unpaused or after handling
+					// it was cancelled.
+					s.Subscriber.Metrics.NACKMessageCounter.With(prometheus.Labels{subscriptionLabel: sub.string()}).Inc()
+					msg.nack()
+					return
+				} else if err != nil {
+					s.Subscriber.Metrics.ACKMessageCounter.With(prometheus.Labels{subscriptionLabel: sub.string()}).Inc()
+				} else {
+					s.Subscriber.Metrics.NACKMessageCounter.With(prometheus.Labels{subscriptionLabel: sub.string()}).Inc()
+				}
+				msg.ack()
+			}
+			if s.WorkerPoolSize <= 0 {
+				return sub.receive(ctx, handle)
+			}
+			pool := newWorkerPool(s.WorkerPoolSize, handle)
+			defer pool.stop()
+			return sub.receive(ctx, pool.dispatch)
+		})
+		cancelHandlers()
+		s.Subscriber.Metrics.ConnectedSubscriptionsGauge.Dec()
+		if err == nil {
+			return nil
+		}
+		if errors.Is(ctx.Err(), context.Canceled) {
+			logger.WithError(err).Debug("Exiting as context cancelled")
+			return nil
+		}
+		if status.Code(err) != codes.PermissionDenied {
+			logger.WithError(err).Error("Failed to listen for subscription")
+			return err
+		}
+		logger.WithError(err).Errorf("Permission denied on subscription, grant the service account of sub the Pub/Sub Subscriber role on it. Retrying in %s.", interval)
+		s.Subscriber.Metrics.ErrorCounter.With(prometheus.Labels{
+			subscriptionLabel: sub.string(),
+			errorTypeLabel:    "permission-denied",
+		}).Inc()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// receiveUntilDrained runs receive until it returns. Once ctx is cancelled, the
+// messages being handled are waited for up to the drain grace period of the
+// trigger and abandoned afterwards, so that Pub/Sub redelivers them.
+func (s *PullServer) receiveUntilDrained(ctx context.Context, logger *logrus.Entry, trigger config.PubSubTrigger, receive func() error) error {
+	gracePeriod := s.gracePeriod(trigger)
+	done := make(chan error, 1)
+	go func() {
+		done <- receive()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(gracePeriod):
+		if gracePeriod > 0 {
+			logger.Warnf("Messages were still being handled %s after shutdown, abandoning them.", gracePeriod)
+		}
+		return ctx.Err()
+	}
+}
+
+// gracePeriod returns how long the messages of the trigger being handled on
+// shutdown are waited for.
+func (s *PullServer) gracePeriod(trigger config.PubSubTrigger) time.Duration {
+	if trigger.DrainGracePeriod != nil {
+		return trigger.DrainGracePeriod.Duration
+	}
+	return s.GracePeriod
+}
+
+// drainContext returns a context that is only cancelled the grace period after
+// ctx is, or once the returned func is called.
+func drainContext(ctx context.Context, gracePeriod time.Duration) (context.Context, context.CancelFunc) {
+	drainCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(gracePeriod, cancel)
+	})
+	return drainCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// selectSubscriptions returns the subscriptions matching the configured patterns.
+func (s *PullServer) selectSubscriptions(subscriptions []string) []string {
+	if len(s.Subscriptions) == 0 {
+		return subscriptions
+	}
+	var selected []string
+	for _, subName := range subscriptions {
+		for _, pattern := range s.Subscriptions {
+			if match, _ := filepath.Match(pattern, subName); match {
+				selected = append(selected, subName)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// EffectiveConfig returns the YAML serialized triggers the server pulls, for
+// debugging. Only the selected subscriptions are included, with defaults
+// applied and forbidden envs resolved. Credentials and query values of
+// transform URLs are redacted.
+func (s *PullServer) EffectiveConfig() ([]byte, error) {
+	c := s.Subscriber.ConfigAgent.Config()
+	var triggers config.PubSubTriggers
+	for _, trigger := range c.PubSubTriggers {
+		subscriptions := s.selectSubscriptions(trigger.Topics)
+		if len(subscriptions) == 0 {
+			continue
+		}
+		effective := trigger
+		effective.Topics = subscriptions
+		effective.ForbiddenEnvs = sets.List(c.ForbiddenEnvs(trigger))
+		effective.AllowedEnvs = nil
+		if trigger.Transform != nil {
+			transform := *trigger.Transform
+			transform.URL = redactURL(transform.URL)
+			if transform.Timeout == nil {
+				transform.Timeout = &metav1.Duration{Duration: defaultTransformTimeout}
+			}
+			effective.Transform = &transform
+		}
+		triggers = append(triggers, effective)
+	}
+	return yaml.Marshal(struct {
+		PubSubTriggers           config.PubSubTriggers            `json:"pubsub_triggers"`
+		PubSubMaintenanceWindows []config.PubSubMaintenanceWindow `json:"pubsub_maintenance_windows,omitempty"`
+	}{triggers, c.PubSubMaintenanceWindows})
+}
+
+// redactURL replaces the password and query values of the URL, which may hold
+// credentials.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "REDACTED"
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), "REDACTED")
+	}
+	query := u.Query()
+	for key := range query {
+		query.Set(key, "REDACTED")
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
 // Run will block listening to all subscriptions and return once the context is cancelled
 // or one of the subscription has a unrecoverable error.
 func (s *PullServer) Run(ctx context.Context) error {
@@ -165,6 +372,14 @@ func (s *PullServer) Run(ctx context.Context) error {
 		}
 		logrus.Debug("Pull server shutting down.")
 	}()
+	// Infra failure retries are stopped on shutdown, and waited for so that
+	// none outlives the server.
+	retryCtx, cancelRetries := context.WithCancel(ctx)
+	s.Subscriber.retryCtx = retryCtx
+	defer func() {
+		cancelRetries()
+		s.Subscriber.retries.Wait()
+	}()
 	currentConfig := configToWatch{
 		s.Subscriber.ConfigAgent.Config().PubSubTriggers,
 		s.Subscriber.ConfigAgent.Config().PubSubSubscriptions,
@@ -176,8 +391,9 @@ func (s *PullServer) Run(ctx context.Context) error {
 
 	for {
 		select {
-		// Parent context. Shutdown
+		// Parent context. Shutdown once the messages being handled are drained
 		case <-ctx.Done():
+			errGroup.Wait()
 			return nil
 		// Current thread context, it may be failing already
 		case <-derivedCtx.Done():
@@ -17,19 +17,43 @@ limitations under the License.
 package subscriber
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"cloud.google.com/go/pubsub"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	prowcrd "sigs.k8s.io/prow/prow/apis/prowjobs/v1"
 	"sigs.k8s.io/prow/prow/config"
+	reporter "sigs.k8s.io/prow/prow/crier/reporters/pubsub"
 	"sigs.k8s.io/prow/prow/gangway"
 	"sigs.k8s.io/prow/prow/kube"
+	"sigs.k8s.io/prow/prow/pjutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -38,8 +62,32 @@ const (
 	PeriodicProwJobEvent   = "prow.k8s.io/pubsub.PeriodicProwJobEvent"
 	PresubmitProwJobEvent  = "prow.k8s.io/pubsub.PresubmitProwJobEvent"
 	PostsubmitProwJobEvent = "prow.k8s.io/pubsub.PostsubmitProwJobEvent"
+	// SchemaVersion is the message attribute producers use to declare the
+	// version of the ProwJobEvent schema they publish.
+	SchemaVersion = "schema-version"
+	// SanitizedLabelsAnnotation lists the labels of a job whose invalid values
+	// were sanitized, for triggers with sanitize_label_values.
+	SanitizedLabelsAnnotation = "prow.k8s.io/pubsub.sanitized-labels"
+	// CreatedAtAnnotation is the time the subscriber created a job, in RFC 3339 format.
+	CreatedAtAnnotation = "prow.k8s.io/pubsub.created-at"
+	// PublishedAtAnnotation is the time the message a job was created for was
+	// published, in RFC 3339 format, if known.
+	PublishedAtAnnotation = "prow.k8s.io/pubsub.published-at"
+
+	defaultInfraRetryPollInterval = 30 * time.Second
+	defaultMaxInfraRetryWatchers  = 100
+	defaultTransformTimeout       = 10 * time.Second
 )
 
+// errMaintenanceWindow is returned for messages of jobs paused by a maintenance
+// window, which are nacked so that they are redelivered after the window.
+var errMaintenanceWindow = errors.New("job is paused by a maintenance window")
+
+// errMessageCancelled is returned for messages whose handling timed out or was
+// cancelled before a Prow Job creation request was sent, which are nacked so
+// that they are redelivered.
+var errMessageCancelled = errors.New("handling the message was cancelled")
+
 // ProwJobEvent contains the minimum information required to start a ProwJob.
 type ProwJobEvent struct {
 	Name string `json:"name"`
@@ -48,6 +96,278 @@ type ProwJobEvent struct {
 	Envs        map[string]string `json:"envs,omitempty"`
 	Labels      map[string]string `json:"labels,omitempty"`
 	Annotations map[string]string `json:"annotations,omitempty"`
+	// Volumes are added to the pod spec of the job, only emptyDir and
+	// configMap volumes are allowed.
+	Volumes []v1.Volume `json:"volumes,omitempty"`
+	// VolumeMounts are added to every container of the job.
+	VolumeMounts []v1.VolumeMount `json:"volume_mounts,omitempty"`
+	// ProwJobName optionally sets the name of the created ProwJob, e.g. for
+	// callers deduplicating events themselves. It must be a DNS label and is
+	// only allowed by triggers with allow_prowjob_name.
+	ProwJobName string `json:"prowjob_name,omitempty"`
+	// Timeout optionally overrides the decoration timeout of the job, e.g. to
+	// tighten it for ad-hoc runs. It may not exceed the max_timeout of the
+	// trigger and is only allowed for decorated jobs.
+	Timeout *prowcrd.Duration `json:"timeout,omitempty"`
+	// OwnerReferences are added to the created ProwJob, e.g. so that it is
+	// garbage collected along with an object of an external controller. They
+	// are only allowed by triggers with allow_owner_references.
+	OwnerReferences []metav1.OwnerReference `json:"owner_references,omitempty"`
+	// Cluster optionally overrides the build cluster the job runs on, e.g. to
+	// target one of several build clusters. It must be a known build cluster
+	// allowed by the trigger.
+	Cluster string `json:"cluster,omitempty"`
+}
+
+// validateVolumes ensures the event only declares allowed volume types.
+func (pe *ProwJobEvent) validateVolumes() error {
+	for _, volume := range pe.Volumes {
+		if volume.Name == "" {
+			return errors.New("volumes must have a name")
+		}
+		if volume.EmptyDir == nil && volume.ConfigMap == nil {
+			return fmt.Errorf("volume %q has a disallowed type, only emptyDir and configMap volumes are allowed", volume.Name)
+		}
+	}
+	return nil
+}
+
+// validateTimeout ensures the event only sets a positive timeout up to the max.
+func (pe *ProwJobEvent) validateTimeout(max *metav1.Duration) error {
+	if pe.Timeout == nil {
+		return nil
+	}
+	if max == nil {
+		return fmt.Errorf("timeout %s is set, but the subscription doesn't allow it", pe.Timeout.Duration)
+	}
+	if pe.Timeout.Duration <= 0 {
+		return fmt.Errorf("timeout %s must be positive", pe.Timeout.Duration)
+	}
+	if pe.Timeout.Duration > max.Duration {
+		return fmt.Errorf("timeout %s exceeds the maximum of %s", pe.Timeout.Duration, max.Duration)
+	}
+	return nil
+}
+
+// validateProwJobName ensures the event only sets a valid ProwJob name if allowed.
+func (pe *ProwJobEvent) validateProwJobName(allowed bool) error {
+	if pe.ProwJobName == "" {
+		return nil
+	}
+	if !allowed {
+		return fmt.Errorf("prowjob_name %q is set, but the subscription doesn't allow it", pe.ProwJobName)
+	}
+	if errs := validation.IsDNS1123Label(pe.ProwJobName); len(errs) > 0 {
+		return fmt.Errorf("prowjob_name %q is not a valid DNS label: %s", pe.ProwJobName, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// validateOwnerReferences ensures the event only sets complete owner references
+// if allowed.
+func (pe *ProwJobEvent) validateOwnerReferences(allowed bool) error {
+	if len(pe.OwnerReferences) == 0 {
+		return nil
+	}
+	if !allowed {
+		return errors.New("owner_references are set, but the subscription doesn't allow them")
+	}
+	for i, ref := range pe.OwnerReferences {
+		var missing []string
+		if ref.APIVersion == "" {
+			missing = append(missing, "apiVersion")
+		}
+		if ref.Kind == "" {
+			missing = append(missing, "kind")
+		}
+		if ref.Name == "" {
+			missing = append(missing, "name")
+		}
+		if ref.UID == "" {
+			missing = append(missing, "uid")
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("owner_references[%d] is missing %s", i, strings.Join(missing, ", "))
+		}
+	}
+	return nil
+}
+
+// addAttributeLabels copies the values of the mapped message attributes to the
+// labels of the event, unless the event sets the label itself. Attributes
+// missing from the message are skipped.
+func (pe *ProwJobEvent) addAttributeLabels(attributes, attributeLabels map[string]string) {
+	for attribute, label := range attributeLabels {
+		value, ok := attributes[attribute]
+		if !ok {
+			continue
+		}
+		if _, ok := pe.Labels[label]; ok {
+			continue
+		}
+		if pe.Labels == nil {
+			pe.Labels = map[string]string{}
+		}
+		pe.Labels[label] = value
+	}
+}
+
+// addAnnotationTemplates sets the annotations of the templates, resolving their
+// variables from the message attributes, then from the envs of the event,
+// unless the event sets the annotation itself.
+func (pe *ProwJobEvent) addAnnotationTemplates(attributes, templates map[string]string) error {
+	lookup := func(variable string) (string, bool) {
+		if value, ok := attributes[variable]; ok {
+			return value, true
+		}
+		value, ok := pe.Envs[variable]
+		return value, ok
+	}
+	for _, annotation := range sets.List(sets.KeySet(templates)) {
+		if _, ok := pe.Annotations[annotation]; ok {
+			continue
+		}
+		value, err := config.ExpandAnnotationTemplate(templates[annotation], lookup)
+		if err != nil {
+			return fmt.Errorf("annotation %q: %w", annotation, err)
+		}
+		if pe.Annotations == nil {
+			pe.Annotations = map[string]string{}
+		}
+		pe.Annotations[annotation] = value
+	}
+	return nil
+}
+
+// validateLabelValues ensures the label values of the event are valid. Invalid
+// values are rejected, or sanitized if sanitize is set, in which case the
+// sanitized labels are returned and listed in the SanitizedLabelsAnnotation.
+func (pe *ProwJobEvent) validateLabelValues(sanitize bool) ([]string, error) {
+	var sanitized []string
+	for _, label := range sets.List(sets.KeySet(pe.Labels)) {
+		value := pe.Labels[label]
+		errs := validation.IsValidLabelValue(value)
+		if len(errs) == 0 {
+			continue
+		}
+		if !sanitize {
+			return nil, fmt.Errorf("label %q has invalid value %q: %s", label, value, strings.Join(errs, ", "))
+		}
+		pe.Labels[label] = sanitizeLabelValue(value)
+		sanitized = append(sanitized, label)
+	}
+	if len(sanitized) > 0 {
+		if pe.Annotations == nil {
+			pe.Annotations = map[string]string{}
+		}
+		pe.Annotations[SanitizedLabelsAnnotation] = strings.Join(sanitized, ",")
+	}
+	return sanitized, nil
+}
+
+// invalidLabelValueChars matches the characters label values may not contain.
+var invalidLabelValueChars = regexp.MustCompile(`[^-A-Za-z0-9_.]`)
+
+// sanitizeLabelValue turns the value into a valid label value by replacing
+// invalid characters with dashes, truncating it to the maximum length and
+// trimming the characters that may not start or end it.
+func sanitizeLabelValue(value string) string {
+	value = invalidLabelValueChars.ReplaceAllString(value, "-")
+	if len(value) > validation.LabelValueMaxLength {
+		value = value[:validation.LabelValueMaxLength]
+	}
+	return strings.Trim(value, "-_.")
+}
+
+// setProwJobName is a PreCreateHook naming the ProwJob as requested by the event.
+func setProwJobName(pe *ProwJobEvent, pj *prowcrd.ProwJob) error {
+	pj.Name = pe.ProwJobName
+	return nil
+}
+
+// addOwnerReferences is a PreCreateHook adding the owner references of the event to the ProwJob.
+func addOwnerReferences(pe *ProwJobEvent, pj *prowcrd.ProwJob) error {
+	pj.OwnerReferences = append(pj.OwnerReferences, pe.OwnerReferences...)
+	return nil
+}
+
+// clusterHook returns a PreCreateHook running the ProwJob on the build cluster
+// requested by the event, which must be known and allowed by the trigger.
+func clusterHook(allowed []string, known sets.Set[string]) PreCreateHook {
+	return func(pe *ProwJobEvent, pj *prowcrd.ProwJob) error {
+		if !known.Has(pe.Cluster) {
+			return fmt.Errorf("cluster %s is not a known build cluster", pe.Cluster)
+		}
+		if !sets.New[string](allowed...).HasAny("*", pe.Cluster) {
+			return fmt.Errorf("cluster %s is not allowed. Can be fixed by defining this cluster under pubsub_triggers -> allowed_clusters", pe.Cluster)
+		}
+		pj.Spec.Cluster = pe.Cluster
+		return nil
+	}
+}
+
+// gcsCredentialsHook returns a PreCreateHook making decorated ProwJobs upload
+// their artifacts with the GCS credentials of the given secret.
+func gcsCredentialsHook(secret string) PreCreateHook {
+	return func(_ *ProwJobEvent, pj *prowcrd.ProwJob) error {
+		if pj.Spec.DecorationConfig == nil {
+			return nil
+		}
+		// The decoration config is shared with the job config.
+		pj.Spec.DecorationConfig = pj.Spec.DecorationConfig.DeepCopy()
+		pj.Spec.DecorationConfig.GCSCredentialsSecret = &secret
+		return nil
+	}
+}
+
+// timestampHook returns a PreCreateHook annotating ProwJobs with the time they
+// are created at and, if known, the time their message was published at.
+func timestampHook(publishTime time.Time) PreCreateHook {
+	return func(_ *ProwJobEvent, pj *prowcrd.ProwJob) error {
+		if pj.Annotations == nil {
+			pj.Annotations = map[string]string{}
+		}
+		pj.Annotations[CreatedAtAnnotation] = time.Now().UTC().Format(time.RFC3339Nano)
+		if !publishTime.IsZero() {
+			pj.Annotations[PublishedAtAnnotation] = publishTime.UTC().Format(time.RFC3339Nano)
+		}
+		return nil
+	}
+}
+
+// setTimeout is a PreCreateHook overriding the decoration timeout of the ProwJob
+// as requested by the event.
+func setTimeout(pe *ProwJobEvent, pj *prowcrd.ProwJob) error {
+	if pj.Spec.DecorationConfig == nil {
+		return errors.New("timeouts can only be set on decorated jobs")
+	}
+	// The decoration config is shared with the job config.
+	pj.Spec.DecorationConfig = pj.Spec.DecorationConfig.DeepCopy()
+	pj.Spec.DecorationConfig.Timeout = &prowcrd.Duration{Duration: pe.Timeout.Duration}
+	return nil
+}
+
+// addEventVolumes is a PreCreateHook adding the volumes and mounts of the event to the ProwJob.
+func addEventVolumes(pe *ProwJobEvent, pj *prowcrd.ProwJob) error {
+	if pj.Spec.PodSpec == nil {
+		return errors.New("volumes can only be added to jobs with a pod spec")
+	}
+	// The pod spec is shared with the job config.
+	pj.Spec.PodSpec = pj.Spec.PodSpec.DeepCopy()
+	existing := sets.New[string]()
+	for _, volume := range pj.Spec.PodSpec.Volumes {
+		existing.Insert(volume.Name)
+	}
+	for _, volume := range pe.Volumes {
+		if existing.Has(volume.Name) {
+			return fmt.Errorf("volume %q is already defined by the job", volume.Name)
+		}
+		pj.Spec.PodSpec.Volumes = append(pj.Spec.PodSpec.Volumes, volume)
+	}
+	for i := range pj.Spec.PodSpec.Containers {
+		pj.Spec.PodSpec.Containers[i].VolumeMounts = append(pj.Spec.PodSpec.Containers[i].VolumeMounts, pe.VolumeMounts...)
+	}
+	return nil
 }
 
 // FromPayload set the ProwJobEvent from the PubSub message payload.
@@ -58,6 +378,85 @@ func (pe *ProwJobEvent) FromPayload(data []byte) error {
 	return nil
 }
 
+// FromTemplatedPayload sets the ProwJobEvent from a PubSub message payload of
+// another shape, using the template mapping event fields to paths of values in
+// the payload, as documented by config.PubSubTrigger.PayloadTemplate. Fields
+// whose path is missing from the payload are left unset.
+func (pe *ProwJobEvent) FromTemplatedPayload(data []byte, template map[string]string) error {
+	var payload interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+	event := map[string]interface{}{}
+	for field, path := range template {
+		value, ok, err := payloadValue(payload, path)
+		if err != nil {
+			return fmt.Errorf("payload_template %s: %w", field, err)
+		}
+		if !ok {
+			continue
+		}
+		// Keys of labels and annotations may contain dots themselves.
+		if prefix, key, nested := strings.Cut(field, "."); nested {
+			values, _ := event[prefix].(map[string]interface{})
+			if values == nil {
+				values = map[string]interface{}{}
+				event[prefix] = values
+			}
+			values[key] = value
+		} else {
+			event[field] = value
+		}
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return pe.FromPayload(data)
+}
+
+// payloadPathSegment matches an object key or an array index of a payload path.
+var payloadPathSegment = regexp.MustCompile(`\.([^.\[\]]+)|\[([0-9]+)\]`)
+
+// payloadValue returns the string value at the path of the payload, and false
+// if the payload has no value there.
+func payloadValue(payload interface{}, path string) (string, bool, error) {
+	value := payload
+	for _, segment := range payloadPathSegment.FindAllStringSubmatch(path, -1) {
+		if key := segment[1]; key != "" {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return "", false, nil
+			}
+			if value, ok = object[key]; !ok {
+				return "", false, nil
+			}
+			continue
+		}
+		index, err := strconv.Atoi(segment[2])
+		if err != nil {
+			return "", false, err
+		}
+		array, ok := value.([]interface{})
+		if !ok || index >= len(array) {
+			return "", false, nil
+		}
+		value = array[index]
+	}
+	switch v := value.(type) {
+	case nil:
+		return "", false, nil
+	case string:
+		return v, true, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true, nil
+	case bool:
+		return strconv.FormatBool(v), true, nil
+	default:
+		return "", false, fmt.Errorf("value at %s is not a string, number or boolean", path)
+	}
+}
+
 // ToMessage generates a PubSub Message from a ProwJobEvent.
 func (pe *ProwJobEvent) ToMessage() (*pubsub.Message, error) {
 	return pe.ToMessageOfType(PeriodicProwJobEvent)
@@ -87,12 +486,173 @@ type Subscriber struct {
 	ProwJobClient      gangway.ProwJobClient
 	Reporter           reportClient
 	InRepoConfigGetter config.InRepoConfigGetter
+	// NewInRepoConfigGetter creates an InRepoConfigGetter authenticated as the
+	// given GitHub App, for triggers that configure their own credentials.
+	NewInRepoConfigGetter func(app config.PubSubGitHubApp) (config.InRepoConfigGetter, error)
+	// NewProwJobClient creates a ProwJob client for the cluster of the given
+	// kubeconfig context, for triggers that store their ProwJobs elsewhere.
+	NewProwJobClient func(kubeContext string) (gangway.ProwJobClient, error)
+	// PreCreateHooks are invoked in order on every ProwJob before it is created.
+	// No hooks are run by default.
+	PreCreateHooks []PreCreateHook
+	// Tracer, if set, is used to start a span for every handled message.
+	// Tracing is disabled when nil.
+	Tracer trace.Tracer
+	// EventRecorder, if set, records a warning Event for every Prow Job that
+	// fails to be created, so that failures show up in `kubectl get events`.
+	EventRecorder record.EventRecorder
+	// BuildClusters are the aliases of the build clusters in the kubeconfigs,
+	// which events may request to run their jobs on. Only the default cluster
+	// may be requested if nil.
+	BuildClusters sets.Set[string]
+	// InfraRetryPollInterval is how often jobs of triggers with max_infra_retries
+	// are checked for completion. Defaults to 30 seconds.
+	InfraRetryPollInterval time.Duration
+	// MaxInfraRetryWatchers caps how many jobs are watched for infra failures
+	// at once, each on its own goroutine. Jobs created while the cap is reached
+	// are not retried. Defaults to 100.
+	MaxInfraRetryWatchers int
+	// MinSchemaVersion is the minimum SchemaVersion attribute accepted,
+	// messages with an older or missing version are rejected. 0 accepts all.
+	MinSchemaVersion int
+	// TransformClient is used to call the transform webhooks of triggers.
+	// Defaults to http.DefaultClient.
+	TransformClient *http.Client
+	// MessageTimeout is how long handling a message may take before it is
+	// cancelled, so that a slow API server doesn't hold up the subscription.
+	// Messages are nacked if no creation request was sent yet, and acked
+	// otherwise, as the ProwJob may have been created. No timeout if 0.
+	MessageTimeout time.Duration
+	// ResponsePublisher publishes the responses of triggers with a response
+	// topic. Defaults to publishing with a Pub/Sub client.
+	ResponsePublisher ResponsePublisher
+	// CircuitBreaker, if set, pauses creating ProwJobs after consecutive
+	// creation failures. Messages are nacked while it is open.
+	CircuitBreaker *CircuitBreaker
+	// MaxAttributeCombinations caps the distinct attribute combinations tracked
+	// per subscription by the attribute cardinality metrics. Messages with new
+	// combinations past the cap are counted in an overflow counter instead.
+	// Tracking is disabled when 0.
+	MaxAttributeCombinations int
+	// MaxDeliveryAttempts is how often a message that is nacked, e.g. during a
+	// maintenance window, is delivered before it is acked and its job reported
+	// as failed, to avoid poison-message loops. Pub/Sub only counts delivery
+	// attempts of subscriptions with a dead letter policy. Disabled when 0.
+	MaxDeliveryAttempts  int
+	attributeCardinality attributeCardinality
+	// retryCtx is cancelled to stop the infra failure retries, which outlive
+	// the handling of their messages. Set by the pull server.
+	retryCtx                context.Context
+	retries                 sync.WaitGroup
+	retryWatchers           atomic.Int32
+	inRepoConfigGettersLock sync.Mutex
+	inRepoConfigGetters     map[config.PubSubGitHubApp]config.InRepoConfigGetter
+	prowJobClientsLock      sync.Mutex
+	prowJobClients          map[string]gangway.ProwJobClient
+}
+
+// PreCreateHook is invoked with the event and the ProwJob built from it before
+// the ProwJob is created. It may mutate the ProwJob, or return an error to
+// reject it, in which case the ProwJob is not created.
+type PreCreateHook func(pe *ProwJobEvent, pj *prowcrd.ProwJob) error
+
+// hookedProwJobClient runs the pre-create hooks before creating a ProwJob.
+type hookedProwJobClient struct {
+	gangway.ProwJobClient
+	pe    *ProwJobEvent
+	hooks []PreCreateHook
+}
+
+func (c *hookedProwJobClient) Create(ctx context.Context, pj *prowcrd.ProwJob, opts metav1.CreateOptions) (*prowcrd.ProwJob, error) {
+	for _, hook := range c.hooks {
+		if err := hook(c.pe, pj); err != nil {
+			return nil, fmt.Errorf("rejected by pre-create hook: %w", err)
+		}
+	}
+	return c.ProwJobClient.Create(ctx, pj, opts)
+}
+
+// tenantHook returns a PreCreateHook rejecting jobs that don't belong to the tenant.
+func tenantHook(tenantID string) PreCreateHook {
+	return func(_ *ProwJobEvent, pj *prowcrd.ProwJob) error {
+		jobTenantID := config.DefaultTenantID
+		if pj.Spec.ProwJobDefault != nil && pj.Spec.ProwJobDefault.TenantID != "" {
+			jobTenantID = pj.Spec.ProwJobDefault.TenantID
+		}
+		if jobTenantID != tenantID {
+			return fmt.Errorf("job %q belongs to tenant %q, but the subscription only allows tenant %q", pj.Spec.Job, jobTenantID, tenantID)
+		}
+		return nil
+	}
+}
+
+// triggerProwJobClient returns the client for the cluster that ProwJobs of the
+// trigger are stored in.
+func (s *Subscriber) triggerProwJobClient(trigger config.PubSubTrigger) (gangway.ProwJobClient, error) {
+	if trigger.KubeContext == "" {
+		return s.ProwJobClient, nil
+	}
+	if s.NewProwJobClient == nil {
+		return nil, fmt.Errorf("no ProwJob client available for kube context %q", trigger.KubeContext)
+	}
+	s.prowJobClientsLock.Lock()
+	defer s.prowJobClientsLock.Unlock()
+	if client, ok := s.prowJobClients[trigger.KubeContext]; ok {
+		return client, nil
+	}
+	client, err := s.NewProwJobClient(trigger.KubeContext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ProwJob client for kube context %q: %w", trigger.KubeContext, err)
+	}
+	if s.prowJobClients == nil {
+		s.prowJobClients = map[string]gangway.ProwJobClient{}
+	}
+	s.prowJobClients[trigger.KubeContext] = client
+	return client, nil
+}
+
+// prowJobClient returns the client used to create ProwJobs for the given event.
+func (s *Subscriber) prowJobClient(client gangway.ProwJobClient, pe *ProwJobEvent, trigger config.PubSubTrigger, publishTime time.Time) gangway.ProwJobClient {
+	var hooks []PreCreateHook
+	if trigger.TenantID != "" {
+		hooks = append(hooks, tenantHook(trigger.TenantID))
+	}
+	if len(pe.Volumes) > 0 || len(pe.VolumeMounts) > 0 {
+		hooks = append(hooks, addEventVolumes)
+	}
+	if pe.ProwJobName != "" {
+		hooks = append(hooks, setProwJobName)
+	}
+	if len(pe.OwnerReferences) > 0 {
+		hooks = append(hooks, addOwnerReferences)
+	}
+	if pe.Cluster != "" {
+		known := s.BuildClusters
+		if known == nil {
+			known = sets.New[string](kube.DefaultClusterAlias)
+		}
+		hooks = append(hooks, clusterHook(trigger.AllowedClusters, known))
+	}
+	if trigger.GCSCredentialsSecret != "" {
+		hooks = append(hooks, gcsCredentialsHook(trigger.GCSCredentialsSecret))
+	}
+	if pe.Timeout != nil {
+		hooks = append(hooks, setTimeout)
+	}
+	hooks = append(hooks, s.PreCreateHooks...)
+	// Stamp the creation time last, as close to the creation as possible.
+	hooks = append(hooks, timestampHook(publishTime))
+	return &hookedProwJobClient{ProwJobClient: client, pe: pe, hooks: hooks}
 }
 
 type messageInterface interface {
 	getAttributes() map[string]string
 	getPayload() []byte
 	getID() string
+	getPublishTime() time.Time
+	// getDeliveryAttempt returns how often the message was delivered, or 0 if
+	// Pub/Sub doesn't count it.
+	getDeliveryAttempt() int
 	ack()
 	nack()
 }
@@ -118,6 +678,17 @@ func (m *pubSubMessage) getID() string {
 	return m.ID
 }
 
+func (m *pubSubMessage) getPublishTime() time.Time {
+	return m.PublishTime
+}
+
+func (m *pubSubMessage) getDeliveryAttempt() int {
+	if m.DeliveryAttempt == nil {
+		return 0
+	}
+	return *m.DeliveryAttempt
+}
+
 func (m *pubSubMessage) ack() {
 	m.Message.Ack()
 }
@@ -133,41 +704,284 @@ func extractFromAttribute(attrs map[string]string, key string) (string, error) {
 	return value, nil
 }
 
-func (s *Subscriber) getReporterFunc(l *logrus.Entry) gangway.ReporterFunc {
+// checkSchemaVersion rejects messages whose SchemaVersion attribute is older
+// than the minimum supported by the subscriber.
+func (s *Subscriber) checkSchemaVersion(attrs map[string]string) error {
+	if s.MinSchemaVersion <= 0 {
+		return nil
+	}
+	value, err := extractFromAttribute(attrs, SchemaVersion)
+	if err != nil {
+		return fmt.Errorf("schema version is required, the minimum supported is %d: %w", s.MinSchemaVersion, err)
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid schema version %q: %w", value, err)
+	}
+	if version < s.MinSchemaVersion {
+		return fmt.Errorf("schema version %d is not supported, the minimum supported is %d", version, s.MinSchemaVersion)
+	}
+	return nil
+}
+
+func (s *Subscriber) getReporterFunc(ctx context.Context, l *logrus.Entry, trigger config.PubSubTrigger, payload []byte) gangway.ReporterFunc {
 	return func(pj *prowcrd.ProwJob, state prowcrd.ProwJobState, err error) {
+		if kerrors.IsAlreadyExists(err) {
+			// Only ProwJobs named by the event collide, the earlier delivery
+			// of the event that created the job has reported it already.
+			return
+		}
 		pj.Status.State = state
 		pj.Status.Description = "Successfully triggered prowjob."
 		if err != nil {
 			pj.Status.Description = fmt.Sprintf("Failed creating prowjob: %v", err)
+			if trigger.ReportPayload {
+				pj = withReportedPayload(pj, payload)
+			}
+		}
+		if trigger.ReportTopic != "" {
+			pj = withReportTopic(pj, trigger)
 		}
-		if s.Reporter.ShouldReport(context.TODO(), l, pj) {
-			if _, _, err := s.Reporter.Report(context.TODO(), l, pj); err != nil {
+		if s.Reporter.ShouldReport(ctx, l, pj) {
+			if _, _, err := s.Reporter.Report(ctx, l, pj); err != nil {
 				l.WithError(err).Warning("Failed to report status.")
 			}
 		}
 	}
 }
 
-func (s *Subscriber) handleMessage(msg messageInterface, subscription string, allowedClusters []string) error {
+// maxReportedPayloadSize caps the size of the payload copies attached to the
+// reports of jobs that failed to be created.
+const maxReportedPayloadSize = 4 * 1024
+
+// withReportedPayload returns a copy of the ProwJob annotated with a copy of the
+// message payload, for triggers reporting payloads. The values of envs are
+// redacted as they may hold credentials, and the copy is truncated to
+// maxReportedPayloadSize.
+func withReportedPayload(pj *prowcrd.ProwJob, payload []byte) *prowcrd.ProwJob {
+	pj = pj.DeepCopy()
+	if pj.Annotations == nil {
+		pj.Annotations = map[string]string{}
+	}
+	pj.Annotations[reporter.PubSubPayloadLabel] = redactPayload(payload)
+	return pj
+}
+
+// redactPayload returns the payload with the values of its envs redacted,
+// truncated to maxReportedPayloadSize. Payloads that are not JSON objects are
+// returned as is, but truncated.
+func redactPayload(payload []byte) string {
+	var event map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &event); err == nil {
+		var envs map[string]string
+		if err := json.Unmarshal(event["envs"], &envs); err == nil && len(envs) > 0 {
+			for name := range envs {
+				envs[name] = "REDACTED"
+			}
+			if redacted, err := json.Marshal(envs); err == nil {
+				event["envs"] = redacted
+				if redactedPayload, err := json.Marshal(event); err == nil {
+					payload = redactedPayload
+				}
+			}
+		}
+	}
+	if len(payload) <= maxReportedPayloadSize {
+		return string(payload)
+	}
+	// Don't leave a partial UTF-8 sequence behind.
+	return strings.ToValidUTF8(string(payload[:maxReportedPayloadSize]), "") + "...(truncated)"
+}
+
+// withReportTopic returns a copy of the ProwJob that reports to the topic
+// configured for the trigger instead of the one it was created with.
+func withReportTopic(pj *prowcrd.ProwJob, trigger config.PubSubTrigger) *prowcrd.ProwJob {
+	pj = pj.DeepCopy()
+	if pj.Annotations == nil {
+		pj.Annotations = map[string]string{}
+	}
+	if pj.Annotations[reporter.PubSubProjectLabel] == "" && pj.Labels[reporter.PubSubProjectLabel] == "" {
+		pj.Annotations[reporter.PubSubProjectLabel] = trigger.Project
+	}
+	pj.Annotations[reporter.PubSubTopicLabel] = trigger.ReportTopic
+	return pj
+}
+
+// inRepoConfigGetter returns the InRepoConfigGetter to use for jobs triggered
+// by the given trigger, creating and caching it on first use.
+func (s *Subscriber) inRepoConfigGetter(trigger config.PubSubTrigger) (config.InRepoConfigGetter, error) {
+	if trigger.GitHubApp == nil {
+		return s.InRepoConfigGetter, nil
+	}
+	if s.NewInRepoConfigGetter == nil {
+		// Don't silently fetch inrepoconfig with the default credentials.
+		return nil, fmt.Errorf("GitHub App %q is not supported by the configured inrepoconfig getter", trigger.GitHubApp.AppID)
+	}
+	s.inRepoConfigGettersLock.Lock()
+	defer s.inRepoConfigGettersLock.Unlock()
+	if getter, ok := s.inRepoConfigGetters[*trigger.GitHubApp]; ok {
+		return getter, nil
+	}
+	getter, err := s.NewInRepoConfigGetter(*trigger.GitHubApp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create inrepoconfig getter for GitHub App %q: %w", trigger.GitHubApp.AppID, err)
+	}
+	if s.inRepoConfigGetters == nil {
+		s.inRepoConfigGetters = map[config.PubSubGitHubApp]config.InRepoConfigGetter{}
+	}
+	s.inRepoConfigGetters[*trigger.GitHubApp] = getter
+	return getter, nil
+}
+
+func (s *Subscriber) tracer() trace.Tracer {
+	if s.Tracer == nil {
+		return trace.NewNoopTracerProvider().Tracer("")
+	}
+	return s.Tracer
+}
+
+func (s *Subscriber) handleMessage(ctx context.Context, msg messageInterface, subscription string, trigger config.PubSubTrigger) (err error) {
 
 	msgID := msg.getID()
 	l := logrus.WithFields(logrus.Fields{
 		"pubsub-subscription": subscription,
 		"pubsub-id":           msgID})
 
+	if s.MessageTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.MessageTimeout)
+		defer cancel()
+	}
+	ctx, span := s.tracer().Start(ctx, "pubsub.handleMessage", trace.WithAttributes(
+		attribute.String("pubsub.subscription", subscription),
+		attribute.String("pubsub.message_id", msgID),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+	defer s.recordProcessingSLO(l, subscription, trigger, time.Now())
+	s.recordAttributeCardinality(subscription, msg.getAttributes())
+	// Messages of unsupported or unreadable event types are counted as unknown.
+	eventType := unknownEventType
+	defer func() {
+		s.recordEventType(subscription, eventType, err)
+	}()
+
 	// First, convert the incoming message into a CreateJobExecutionRequest type.
-	cjer, err := s.msgToCjer(l, msg, subscription)
+	pe, cjer, err := s.msgToCjer(ctx, l, msg, subscription, trigger)
 	if err != nil {
 		return err
 	}
+	eventType = eventTypeName(cjer.GetJobExecutionType())
+	span.SetAttributes(
+		attribute.String("prow.job_name", cjer.GetJobName()),
+		attribute.String("prow.job_execution_type", cjer.GetJobExecutionType().String()),
+	)
+
+	if forbidden := s.ConfigAgent.Config().RejectedEnvs(trigger, sets.KeySet(pe.Envs)); forbidden.Len() > 0 {
+		err = fmt.Errorf("message sets forbidden envs: %s", strings.Join(sets.List(forbidden), ", "))
+		l.WithError(err).Info("Forbidden envs")
+		s.Metrics.ErrorCounter.With(prometheus.Labels{
+			subscriptionLabel: subscription,
+			errorTypeLabel:    "forbidden-env",
+		}).Inc()
+		s.reportRejection(ctx, l, msg, trigger, pe, err)
+		return err
+	}
+
+	if s.ConfigAgent.Config().InPubSubMaintenanceWindow(cjer.GetJobName(), time.Now()) {
+		err = fmt.Errorf("%w: %s", errMaintenanceWindow, cjer.GetJobName())
+		l.WithError(err).Info("Deferring message until the maintenance window ends")
+		s.Metrics.ErrorCounter.With(prometheus.Labels{
+			subscriptionLabel: subscription,
+			errorTypeLabel:    "maintenance-window",
+		}).Inc()
+		return s.limitDeliveryAttempts(ctx, l, msg, subscription, trigger, pe, err)
+	}
 
 	// Do not check for HTTP client authorization, because we're handling a
 	// PubSub message.
 	var allowedApiClient *config.AllowedApiClient = nil
 	var requireTenantID bool = false
 
-	cfgAdapter := gangway.ProwCfgAdapter{Config: s.ConfigAgent.Config()}
-	if _, err = gangway.HandleProwJob(l, s.getReporterFunc(l), cjer, s.ProwJobClient, &cfgAdapter, s.InRepoConfigGetter, allowedApiClient, requireTenantID, allowedClusters); err != nil {
+	ircg, err := s.inRepoConfigGetter(trigger)
+	if err != nil {
+		l.WithError(err).Error("failed to get inrepoconfig getter")
+		s.Metrics.ErrorCounter.With(prometheus.Labels{
+			subscriptionLabel: subscription,
+			errorTypeLabel:    "failed-inrepoconfig-getter",
+		}).Inc()
+		return err
+	}
+
+	pjc, err := s.triggerProwJobClient(trigger)
+	if err != nil {
+		l.WithError(err).Error("failed to get ProwJob client")
+		s.Metrics.ErrorCounter.With(prometheus.Labels{
+			subscriptionLabel: subscription,
+			errorTypeLabel:    "failed-prowjob-client",
+		}).Inc()
+		return err
+	}
+
+	if !s.CircuitBreaker.allow() {
+		err = fmt.Errorf("%w: %s", errCircuitOpen, cjer.GetJobName())
+		l.WithError(err).Info("Deferring message until the circuit breaker closes")
+		s.Metrics.ErrorCounter.With(prometheus.Labels{
+			subscriptionLabel: subscription,
+			errorTypeLabel:    "circuit-breaker-open",
+		}).Inc()
+		return s.limitDeliveryAttempts(ctx, l, msg, subscription, trigger, pe, err)
+	}
+
+	createJob := func(ctx context.Context, client gangway.ProwJobClient, pe *ProwJobEvent) (*gangway.JobExecution, error) {
+		cfgAdapter := gangway.ProwCfgAdapter{Config: s.ConfigAgent.Config()}
+		allowedClusters := trigger.AllowedClusters
+		if pe.Cluster != "" {
+			// The configured cluster of the job is replaced by the requested
+			// one, which the cluster hook checks against the allowed clusters.
+			allowedClusters = []string{"*"}
+		}
+		return gangway.HandleProwJob(ctx, l, s.getReporterFunc(ctx, l, trigger, msg.getPayload()), cjer, s.prowJobClient(client, pe, trigger, msg.getPublishTime()), &cfgAdapter, ircg, allowedApiClient, requireTenantID, allowedClusters)
+	}
+	attempt := &attemptProwJobClient{ProwJobClient: pjc}
+	jobExec, err := createJob(ctx, attempt, pe)
+	s.CircuitBreaker.done(attempt.attempted, attempt.err)
+	s.recordCircuitBreakerState()
+	if err != nil && pe.ProwJobName != "" && kerrors.IsAlreadyExists(err) {
+		l.WithField("prowjob", pe.ProwJobName).Info("Prow Job already exists, the event was handled before.")
+		err = nil
+	} else if errors.Is(err, gangway.ErrJobPaused) {
+		l.WithError(err).Info("Deferring message until the job is unpaused")
+		s.Metrics.ErrorCounter.With(prometheus.Labels{
+			subscriptionLabel: subscription,
+			errorTypeLabel:    "job-paused",
+		}).Inc()
+		err = s.limitDeliveryAttempts(ctx, l, msg, subscription, trigger, pe, err)
+	} else if err != nil && ctx.Err() != nil && !attempt.attempted {
+		err = fmt.Errorf("%w: %v", errMessageCancelled, err)
+		l.WithError(err).Info("Deferring message whose handling was cancelled")
+		s.Metrics.ErrorCounter.With(prometheus.Labels{
+			subscriptionLabel: subscription,
+			errorTypeLabel:    "message-cancelled",
+		}).Inc()
+		// The rejection is still reported once the message is given up on.
+		err = s.limitDeliveryAttempts(context.WithoutCancel(ctx), l, msg, subscription, trigger, pe, err)
+	} else if err != nil && ctx.Err() != nil {
+		// The API server may have created the Prow Job although the request
+		// was cancelled, so the message isn't redelivered to avoid a duplicate.
+		err = fmt.Errorf("handling the message was cancelled while creating the Prow Job, which may have been created: %v", err)
+		l.WithError(err).Warn("Acking message whose Prow Job creation was cancelled")
+		s.Metrics.ErrorCounter.With(prometheus.Labels{
+			subscriptionLabel: subscription,
+			errorTypeLabel:    "create-cancelled",
+		}).Inc()
+		s.reportRejection(context.WithoutCancel(ctx), l, msg, trigger, pe, err)
+	} else if err != nil {
 		l.WithError(err).Info("failed to create Prow Job")
 		s.Metrics.ErrorCounter.With(prometheus.Labels{
 			subscriptionLabel: subscription,
@@ -176,6 +990,37 @@ func (s *Subscriber) handleMessage(msg messageInterface, subscription string, al
 			// prow. (There are exceptions, which we can iterate slightly later)
 			errorTypeLabel: "failed-handle-prowjob",
 		}).Inc()
+		s.recordCreateFailure(cjer.GetJobName(), subscription, msgID, err)
+	} else {
+		span.SetAttributes(attribute.String("prow.prowjob", jobExec.GetId()))
+		if attempt.created != nil {
+			if err := s.publishResponse(ctx, l, trigger, msgID, attempt.created); err != nil {
+				l.WithError(err).Warn("Failed to publish the response.")
+			}
+		}
+		if trigger.MaxInfraRetries > 0 {
+			// Retries can't reuse the name of the job they replace.
+			retryPE := *pe
+			retryPE.ProwJobName = ""
+			// Retries outlive the handling of the message, but not the
+			// subscriber: jobs are no longer watched after a restart.
+			if !s.goRetry(func(ctx context.Context) {
+				if err := s.retryOnInfraFailure(ctx, l, pjc, jobExec.GetId(), trigger.MaxInfraRetries, func(ctx context.Context) (*gangway.JobExecution, error) { return createJob(ctx, pjc, &retryPE) }); err != nil {
+					if ctx.Err() != nil {
+						l.WithError(err).Info("Stopped retrying Prow Job on infra failure on shutdown.")
+						return
+					}
+					l.WithError(err).Warn("Failed to retry Prow Job on infra failure.")
+				}
+			}) {
+				l.WithField("prowjob", jobExec.GetId()).Warn("Too many Prow Jobs are watched for infra failures, the Prow Job won't be retried.")
+			}
+		}
+		if trigger.AbortOlderPresubmits && cjer.GetJobExecutionType() == gangway.JobExecutionType_PRESUBMIT && attempt.created != nil {
+			if err := abortOlderPresubmits(ctx, l, pjc, attempt.created); err != nil {
+				l.WithError(err).Warn("Failed to abort older Prow Jobs.")
+			}
+		}
 	}
 
 	// TODO(chaodaiG): debugging purpose, remove once done debugging.
@@ -183,16 +1028,243 @@ func (s *Subscriber) handleMessage(msg messageInterface, subscription string, al
 	return err
 }
 
+// unknownEventType is the event_type metric label of messages whose event type
+// is unsupported or couldn't be determined.
+const unknownEventType = "unknown"
+
+// eventTypeName returns the event_type metric label of the job execution type.
+func eventTypeName(t gangway.JobExecutionType) string {
+	switch t {
+	case gangway.JobExecutionType_PERIODIC:
+		return "periodic"
+	case gangway.JobExecutionType_PRESUBMIT:
+		return "presubmit"
+	case gangway.JobExecutionType_POSTSUBMIT:
+		return "postsubmit"
+	}
+	return unknownEventType
+}
+
+// recordEventType counts the handled message by event type and result.
+func (s *Subscriber) recordEventType(subscription, eventType string, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	s.Metrics.EventTypeCounter.With(prometheus.Labels{
+		subscriptionLabel: subscription,
+		eventTypeLabel:    eventType,
+		resultLabel:       result,
+	}).Inc()
+}
+
+// limitDeliveryAttempts returns err, which has the message redelivered, unless
+// the message reached the MaxDeliveryAttempts. The job is then reported as
+// failed and an error having the message acked is returned instead, so that it
+// isn't redelivered forever.
+func (s *Subscriber) limitDeliveryAttempts(ctx context.Context, l *logrus.Entry, msg messageInterface, subscription string, trigger config.PubSubTrigger, pe *ProwJobEvent, err error) error {
+	attempt := msg.getDeliveryAttempt()
+	if s.MaxDeliveryAttempts <= 0 || attempt < s.MaxDeliveryAttempts {
+		return err
+	}
+	// Don't wrap the error, which would have the message redelivered.
+	err = fmt.Errorf("giving up after %d delivery attempts: %v", attempt, err)
+	l.WithError(err).Warn("Acking message that reached the max delivery attempts")
+	s.Metrics.ErrorCounter.With(prometheus.Labels{
+		subscriptionLabel: subscription,
+		errorTypeLabel:    "max-delivery-attempts",
+	}).Inc()
+	s.reportRejection(ctx, l, msg, trigger, pe, err)
+	return err
+}
+
+// reportRejection reports the failure of an event rejected before its ProwJob
+// was built, on a ProwJob carrying only the job name and event annotations.
+func (s *Subscriber) reportRejection(ctx context.Context, l *logrus.Entry, msg messageInterface, trigger config.PubSubTrigger, pe *ProwJobEvent, err error) {
+	pj := &prowcrd.ProwJob{
+		ObjectMeta: metav1.ObjectMeta{Annotations: pe.Annotations},
+		Spec:       prowcrd.ProwJobSpec{Job: pe.Name},
+	}
+	s.getReporterFunc(ctx, l, trigger, msg.getPayload())(pj, prowcrd.ErrorState, err)
+}
+
+// recordCircuitBreakerState exposes whether the circuit breaker is open.
+func (s *Subscriber) recordCircuitBreakerState() {
+	if s.CircuitBreaker == nil {
+		return
+	}
+	var open float64
+	if s.CircuitBreaker.isOpen() {
+		open = 1
+	}
+	s.Metrics.CircuitBreakerOpenGauge.Set(open)
+}
+
+// recordProcessingSLO counts the message as slow if handling it since start
+// took longer than the ProcessingSLO of the trigger.
+func (s *Subscriber) recordProcessingSLO(l *logrus.Entry, subscription string, trigger config.PubSubTrigger, start time.Time) {
+	if trigger.ProcessingSLO == nil {
+		return
+	}
+	if took := time.Since(start); took > trigger.ProcessingSLO.Duration {
+		l.WithField("duration", took).Warnf("Handling the message exceeded the processing SLO of %s.", trigger.ProcessingSLO.Duration)
+		s.Metrics.SlowMessageCounter.With(prometheus.Labels{subscriptionLabel: subscription}).Inc()
+	}
+}
+
+// recordCreateFailure records a warning Event for a Prow Job that failed to be
+// created, if an EventRecorder is configured. As the Prow Job doesn't exist, the
+// Event references it by job name.
+func (s *Subscriber) recordCreateFailure(jobName, subscription, msgID string, err error) {
+	if s.EventRecorder == nil {
+		return
+	}
+	ref := &v1.ObjectReference{
+		APIVersion: prowcrd.SchemeGroupVersion.String(),
+		Kind:       "ProwJob",
+		Namespace:  s.ConfigAgent.Config().ProwJobNamespace,
+		Name:       jobName,
+	}
+	s.EventRecorder.Eventf(ref, v1.EventTypeWarning, "FailedCreate", "Failed to create Prow Job %q for message %q from subscription %q: %v", jobName, msgID, subscription, err)
+}
+
+// retryOnInfraFailure waits for the named ProwJob to complete and recreates it
+// if it ended in the error state, which indicates an infrastructure failure
+// rather than a failing job, until it ends in another state or retries are
+// exhausted.
+func (s *Subscriber) retryOnInfraFailure(ctx context.Context, l *logrus.Entry, client gangway.ProwJobClient, name string, retries int, createJob func(context.Context) (*gangway.JobExecution, error)) error {
+	interval := s.InfraRetryPollInterval
+	if interval == 0 {
+		interval = defaultInfraRetryPollInterval
+	}
+	for retry := 1; ; retry++ {
+		var pj *prowcrd.ProwJob
+		if err := wait.PollUntilWithContext(ctx, interval, func(ctx context.Context) (bool, error) {
+			var err error
+			pj, err = client.Get(ctx, name, metav1.GetOptions{})
+			if kerrors.IsNotFound(err) {
+				return false, err
+			} else if err != nil {
+				// Keep trying.
+				return false, nil
+			}
+			return pj.Complete(), nil
+		}); err != nil {
+			return fmt.Errorf("failed waiting for Prow Job %q to complete: %w", name, err)
+		}
+		if pj.Status.State != prowcrd.ErrorState {
+			return nil
+		}
+		if retry > retries {
+			l.WithField("prowjob", name).Infof("Prow Job errored, giving up after %d retries.", retries)
+			return nil
+		}
+		l.WithField("prowjob", name).Infof("Prow Job errored, recreating it (retry %d of %d).", retry, retries)
+		jobExec, err := createJob(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to recreate Prow Job %q: %w", name, err)
+		}
+		name = jobExec.GetId()
+	}
+}
+
+// goRetry runs the retry in the background with the retry context, tracking it
+// so that shutdown can wait for it. It returns false without running the retry
+// if MaxInfraRetryWatchers retries are running already.
+func (s *Subscriber) goRetry(retry func(ctx context.Context)) bool {
+	max := s.MaxInfraRetryWatchers
+	if max == 0 {
+		max = defaultMaxInfraRetryWatchers
+	}
+	if int(s.retryWatchers.Add(1)) > max {
+		s.retryWatchers.Add(-1)
+		return false
+	}
+	ctx := s.retryCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s.retries.Add(1)
+	go func() {
+		defer s.retries.Done()
+		defer s.retryWatchers.Add(-1)
+		retry(ctx)
+	}()
+	return true
+}
+
+// abortingProwJobClient is implemented by the ProwJob clients able to abort
+// ProwJobs.
+type abortingProwJobClient interface {
+	List(context.Context, metav1.ListOptions) (*prowcrd.ProwJobList, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (*prowcrd.ProwJob, error)
+}
+
+// abortOlderPresubmits aborts the incomplete presubmits of the same job and pull
+// requests as the just created ProwJob, e.g. testing an older head SHA, the way
+// plank terminates duplicate presubmits.
+func abortOlderPresubmits(ctx context.Context, l *logrus.Entry, client gangway.ProwJobClient, created *prowcrd.ProwJob) error {
+	aborter, ok := client.(abortingProwJobClient)
+	if !ok {
+		return errors.New("the ProwJob client can't abort ProwJobs")
+	}
+	selector := labels.Set{}
+	for _, label := range []string{kube.ProwJobTypeLabel, kube.ProwJobAnnotation, kube.OrgLabel, kube.RepoLabel, kube.PullLabel} {
+		selector[label] = created.Labels[label]
+	}
+	pjs, err := aborter.List(ctx, metav1.ListOptions{LabelSelector: selector.AsSelector().String()})
+	if err != nil {
+		return fmt.Errorf("failed to list Prow Jobs: %w", err)
+	}
+	return pjutil.TerminateOlderJobs(&prowJobPatcher{client: aborter}, l, pjs.Items)
+}
+
+// prowJobPatcher has pjutil.TerminateOlderJobs patch ProwJobs with a ProwJob
+// clientset rather than a controller-runtime client.
+type prowJobPatcher struct {
+	client abortingProwJobClient
+}
+
+func (p *prowJobPatcher) Patch(ctx context.Context, obj ctrlruntimeclient.Object, patch ctrlruntimeclient.Patch, _ ...ctrlruntimeclient.PatchOption) error {
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+	_, err = p.client.Patch(ctx, obj.GetName(), patch.Type(), data, metav1.PatchOptions{})
+	return err
+}
+
 // msgToCjer converts an incoming message (PubSub message) into a CJER. It
 // actually does 2 conversions --- from the message to ProwJobEvent (in order to
 // unmarshal the raw bytes) then again from ProwJobEvent to a CJER.
-func (s *Subscriber) msgToCjer(l *logrus.Entry, msg messageInterface, subscription string) (*gangway.CreateJobExecutionRequest, error) {
+func (s *Subscriber) msgToCjer(ctx context.Context, l *logrus.Entry, msg messageInterface, subscription string, trigger config.PubSubTrigger) (*ProwJobEvent, *gangway.CreateJobExecutionRequest, error) {
 	msgAttributes := msg.getAttributes()
-	msgPayload := msg.getPayload()
 
-	l.WithField("payload", string(msgPayload)).Debug("Received message")
+	l.WithField("payload", string(msg.getPayload())).Debug("Received message")
 	s.Metrics.MessageCounter.With(prometheus.Labels{subscriptionLabel: subscription}).Inc()
 
+	// Messages of an unsupported schema are rejected before anything else,
+	// since no other step can be trusted to read them.
+	if err := s.checkSchemaVersion(msgAttributes); err != nil {
+		l.WithError(err).Info("Unsupported schema version")
+		s.Metrics.ErrorCounter.With(prometheus.Labels{
+			subscriptionLabel: subscription,
+			errorTypeLabel:    "unsupported-schema-version",
+		}).Inc()
+		return nil, nil, err
+	}
+
+	normalized, err := normalizeMessage(msgAttributes, msg.getPayload())
+	if err != nil {
+		l.WithError(err).Error("failed to read message")
+		s.Metrics.ErrorCounter.With(prometheus.Labels{
+			subscriptionLabel: subscription,
+			errorTypeLabel:    "malformed-message",
+		}).Inc()
+		return nil, nil, err
+	}
+	msgPayload := normalized.payload
+
 	// Note that a CreateJobExecutionRequest is a superset of ProwJobEvent.
 	// However we still use ProwJobEvent here because we want to use the
 	// existing jobHandlers to fetch the prowJobSpec (and the jobHandlers expect
@@ -203,21 +1275,168 @@ func (s *Subscriber) msgToCjer(l *logrus.Entry, msg messageInterface, subscripti
 	// (JSON) is well-formed. We convert it into a CreateJobExecutionRequest
 	// type here and never use it anywhere else.
 	l.WithField("raw-payload", string(msgPayload)).Debug("Raw payload passed in handleProwJob.")
-	if err := pe.FromPayload(msgPayload); err != nil {
-		return nil, err
+	if len(trigger.PayloadTemplate) > 0 {
+		if err := pe.FromTemplatedPayload(msgPayload, trigger.PayloadTemplate); err != nil {
+			return nil, nil, err
+		}
+	} else if err := pe.FromPayload(msgPayload); err != nil {
+		return nil, nil, err
 	}
 
-	eType, err := extractFromAttribute(msgAttributes, ProwEventType)
+	// The event type is resolved before the event is transformed, so that
+	// messages without one aren't sent to the transform webhook.
+	eType := normalized.eventType
+	if eType == "" {
+		eType, err = extractFromAttribute(msgAttributes, ProwEventType)
+	}
+	if err != nil && trigger.EventTypeFromPayload {
+		if payloadType := eventTypeFromPayload(msgPayload); payloadType != "" {
+			l.WithField("type", payloadType).Debug("Using the event type of the payload")
+			eType, err = payloadType, nil
+		}
+	}
+	if err != nil && trigger.DefaultEventType != "" {
+		l.WithField("type", trigger.DefaultEventType).Debug("Using the default event type of the subscription")
+		eType, err = trigger.DefaultEventType, nil
+	}
 	if err != nil {
 		l.WithError(err).Error("failed to read message")
 		s.Metrics.ErrorCounter.With(prometheus.Labels{
 			subscriptionLabel: subscription,
 			errorTypeLabel:    "malformed-message",
 		}).Inc()
-		return nil, err
+		return nil, nil, err
+	}
+
+	if trigger.Transform != nil {
+		transformed, err := s.transformEvent(ctx, *trigger.Transform, &pe)
+		if err == nil {
+			pe = *transformed
+		} else if trigger.Transform.FailOpen {
+			l.WithError(err).Warn("Failed to transform event, proceeding with the original event")
+		} else {
+			l.WithError(err).Info("Failed to transform event")
+			s.Metrics.ErrorCounter.With(prometheus.Labels{
+				subscriptionLabel: subscription,
+				errorTypeLabel:    "failed-transform",
+			}).Inc()
+			return nil, nil, err
+		}
+	}
+
+	if err := pe.validateVolumes(); err != nil {
+		l.WithError(err).Info("Disallowed volumes")
+		s.Metrics.ErrorCounter.With(prometheus.Labels{
+			subscriptionLabel: subscription,
+			errorTypeLabel:    "disallowed-volume",
+		}).Inc()
+		return nil, nil, err
 	}
 
-	return s.peToCjer(l, &pe, eType, subscription)
+	if err := pe.validateProwJobName(trigger.AllowProwJobName); err != nil {
+		l.WithError(err).Info("Invalid ProwJob name")
+		s.Metrics.ErrorCounter.With(prometheus.Labels{
+			subscriptionLabel: subscription,
+			errorTypeLabel:    "invalid-prowjob-name",
+		}).Inc()
+		return nil, nil, err
+	}
+
+	if err := pe.validateOwnerReferences(trigger.AllowOwnerReferences); err != nil {
+		l.WithError(err).Info("Invalid owner references")
+		s.Metrics.ErrorCounter.With(prometheus.Labels{
+			subscriptionLabel: subscription,
+			errorTypeLabel:    "invalid-owner-reference",
+		}).Inc()
+		return nil, nil, err
+	}
+
+	if err := pe.validateTimeout(trigger.MaxTimeout); err != nil {
+		l.WithError(err).Info("Invalid timeout")
+		s.Metrics.ErrorCounter.With(prometheus.Labels{
+			subscriptionLabel: subscription,
+			errorTypeLabel:    "invalid-timeout",
+		}).Inc()
+		return nil, nil, err
+	}
+
+	pe.addAttributeLabels(msgAttributes, trigger.AttributeLabels)
+	if err := pe.addAnnotationTemplates(msgAttributes, trigger.AnnotationTemplates); err != nil {
+		l.WithError(err).Info("Invalid annotation template")
+		s.Metrics.ErrorCounter.With(prometheus.Labels{
+			subscriptionLabel: subscription,
+			errorTypeLabel:    "invalid-annotation-template",
+		}).Inc()
+		return nil, nil, err
+	}
+	sanitized, err := pe.validateLabelValues(trigger.SanitizeLabelValues)
+	if err != nil {
+		l.WithError(err).Info("Invalid label value")
+		s.Metrics.ErrorCounter.With(prometheus.Labels{
+			subscriptionLabel: subscription,
+			errorTypeLabel:    "invalid-label-value",
+		}).Inc()
+		return nil, nil, err
+	}
+	if len(sanitized) > 0 {
+		l.WithField("labels", sanitized).Warn("Sanitized invalid label values")
+	}
+
+	cjer, err := s.peToCjer(l, &pe, eType, subscription)
+	return &pe, cjer, err
+}
+
+// eventTypeFromPayload returns the type field of the JSON payload, if any.
+func eventTypeFromPayload(payload []byte) string {
+	var typed struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(payload, &typed); err != nil {
+		return ""
+	}
+	return typed.Type
+}
+
+// transformEvent POSTs the event to the transform webhook and returns the
+// event it responds with. The request is cancelled along with ctx.
+func (s *Subscriber) transformEvent(ctx context.Context, transform config.PubSubTransform, pe *ProwJobEvent) (*ProwJobEvent, error) {
+	timeout := defaultTransformTimeout
+	if transform.Timeout != nil {
+		timeout = transform.Timeout.Duration
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, err := json.Marshal(pe)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, transform.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := s.TransformClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call transform webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("transform webhook responded with status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transform webhook response: %w", err)
+	}
+	var transformed ProwJobEvent
+	if err := transformed.FromPayload(data); err != nil {
+		return nil, fmt.Errorf("transform webhook responded with an invalid event: %w", err)
+	}
+	return &transformed, nil
 }
 
 func (s *Subscriber) peToCjer(l *logrus.Entry, pe *ProwJobEvent, eType, subscription string) (*gangway.CreateJobExecutionRequest, error) {
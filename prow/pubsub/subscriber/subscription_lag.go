@@ -0,0 +1,111 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subscriber
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var subscriptionLagDesc = prometheus.NewDesc(
+	"prow_pubsub_subscription_lag_seconds",
+	"The age of the oldest message being handled per subscription, 0 if none is.",
+	[]string{subscriptionLabel}, nil,
+)
+
+// subscriptionLag tracks the publish times of the messages being handled for
+// every subscription, so that the age of the oldest of them can be reported.
+// It is a collector computing the lag when scraped, so that the lag of a
+// message stuck being handled keeps growing.
+type subscriptionLag struct {
+	lock sync.Mutex
+	// inFlight maps subscriptions to the publish times of their messages by an
+	// ID unique to every handling, as Pub/Sub may redeliver a message that is
+	// still being handled.
+	inFlight map[string]map[uint64]time.Time
+	nextID   uint64
+}
+
+// start records a message of the subscription published at publishTime and
+// returns the ID to call done with once the message was handled.
+func (l *subscriptionLag) start(subscription string, publishTime time.Time) uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.inFlight == nil {
+		l.inFlight = map[string]map[uint64]time.Time{}
+	}
+	if l.inFlight[subscription] == nil {
+		l.inFlight[subscription] = map[uint64]time.Time{}
+	}
+	l.nextID++
+	l.inFlight[subscription][l.nextID] = publishTime
+	return l.nextID
+}
+
+// done forgets the message with the ID returned by start.
+func (l *subscriptionLag) done(subscription string, id uint64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.inFlight[subscription], id)
+}
+
+// lag returns how long ago the oldest message of the subscription being handled
+// was published, or 0 if none is.
+func (l *subscriptionLag) lag(subscription string, now time.Time) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var oldest time.Time
+	for _, publishTime := range l.inFlight[subscription] {
+		if oldest.IsZero() || publishTime.Before(oldest) {
+			oldest = publishTime
+		}
+	}
+	if oldest.IsZero() || now.Before(oldest) {
+		return 0
+	}
+	return now.Sub(oldest)
+}
+
+func (l *subscriptionLag) Describe(ch chan<- *prometheus.Desc) {
+	ch <- subscriptionLagDesc
+}
+
+// Collect reports the lag of every subscription that had messages handled.
+func (l *subscriptionLag) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	l.lock.Lock()
+	subscriptions := make([]string, 0, len(l.inFlight))
+	for subscription := range l.inFlight {
+		subscriptions = append(subscriptions, subscription)
+	}
+	l.lock.Unlock()
+	for _, subscription := range subscriptions {
+		ch <- prometheus.MustNewConstMetric(subscriptionLagDesc, prometheus.GaugeValue, l.lag(subscription, now).Seconds(), subscription)
+	}
+}
+
+// trackLag records that a message of the subscription is being handled in the
+// subscription lag metric. The returned func must be called once the message
+// was acked or nacked.
+func (s *Subscriber) trackLag(subscription string, msg messageInterface) func() {
+	id := s.Metrics.subscriptionLag.start(subscription, msg.getPublishTime())
+	return func() {
+		s.Metrics.subscriptionLag.done(subscription, id)
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subscriber
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSubscriptionLag(t *testing.T) {
+	now := time.Now()
+	var l subscriptionLag
+	expectLag := func(subscription string, expected time.Duration) {
+		t.Helper()
+		if lag := l.lag(subscription, now); lag != expected {
+			t.Errorf("Expected a lag of %s for %s, got %s", expected, subscription, lag)
+		}
+	}
+
+	expectLag("sub", 0)
+	newer := l.start("sub", now.Add(-time.Minute))
+	expectLag("sub", time.Minute)
+	older := l.start("sub", now.Add(-3*time.Minute))
+	// The same message redelivered while it is still being handled.
+	redelivered := l.start("sub", now.Add(-3*time.Minute))
+	l.start("other", now.Add(-time.Hour))
+	expectLag("sub", 3*time.Minute)
+
+	l.done("sub", older)
+	expectLag("sub", 3*time.Minute)
+	l.done("sub", redelivered)
+	expectLag("sub", time.Minute)
+	l.done("sub", newer)
+	expectLag("sub", 0)
+	expectLag("other", time.Hour)
+}
+
+func TestTrackLag(t *testing.T) {
+	m := NewMetrics()
+	m.subscriptionLag = &subscriptionLag{}
+	s := &Subscriber{Metrics: m}
+	expectLagAbout := func(expected time.Duration) {
+		t.Helper()
+		// Allow for the time passing while the test runs.
+		if lag := testutil.ToFloat64(m.subscriptionLag); lag < expected.Seconds() || lag > (expected+time.Minute).Seconds() {
+			t.Errorf("Expected a lag of about %s, got %vs", expected, lag)
+		}
+	}
+
+	now := time.Now()
+	doneOlder := s.trackLag("sub", &pubSubMessage{pubsub.Message{PublishTime: now.Add(-time.Hour)}})
+	doneNewer := s.trackLag("sub", &pubSubMessage{pubsub.Message{PublishTime: now.Add(-10 * time.Minute)}})
+	expectLagAbout(time.Hour)
+	doneOlder()
+	expectLagAbout(10 * time.Minute)
+	doneNewer()
+	if lag := testutil.ToFloat64(m.subscriptionLag); lag != 0 {
+		t.Errorf("Expected no lag once all messages were handled, got %vs", lag)
+	}
+}
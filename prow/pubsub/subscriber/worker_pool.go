@@ -0,0 +1,67 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subscriber
+
+import (
+	"context"
+	"sync"
+)
+
+// workerPool handles the messages of a subscription on a fixed number of
+// goroutines, rather than one goroutine per received message.
+type workerPool struct {
+	messages chan poolMessage
+	wg       sync.WaitGroup
+}
+
+type poolMessage struct {
+	ctx context.Context
+	msg messageInterface
+}
+
+// newWorkerPool starts size workers handling the dispatched messages.
+func newWorkerPool(size int, handle func(context.Context, messageInterface)) *workerPool {
+	p := &workerPool{messages: make(chan poolMessage)}
+	p.wg.Add(size)
+	for i := 0; i < size; i++ {
+		go func() {
+			defer p.wg.Done()
+			for m := range p.messages {
+				handle(m.ctx, m.msg)
+			}
+		}()
+	}
+	return p
+}
+
+// dispatch hands the message to the next idle worker, blocking until one is
+// available. The message is nacked if ctx is cancelled meanwhile, so that
+// Pub/Sub redelivers it.
+func (p *workerPool) dispatch(ctx context.Context, msg messageInterface) {
+	select {
+	case p.messages <- poolMessage{ctx: ctx, msg: msg}:
+	case <-ctx.Done():
+		msg.nack()
+	}
+}
+
+// stop waits for the workers to handle the dispatched messages. No message
+// may be dispatched afterwards.
+func (p *workerPool) stop() {
+	close(p.messages)
+	p.wg.Wait()
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subscriber
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type nackRecordingMessage struct {
+	fakeMessage
+	nacked bool
+}
+
+func (m *nackRecordingMessage) nack() {
+	m.nacked = true
+}
+
+func TestWorkerPool(t *testing.T) {
+	const size, messages = 3, 20
+	var lock sync.Mutex
+	var active, maxActive, handled int
+	pool := newWorkerPool(size, func(context.Context, messageInterface) {
+		lock.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		lock.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		lock.Lock()
+		active--
+		handled++
+		lock.Unlock()
+	})
+
+	// Pub/Sub invokes the callback concurrently for the received messages.
+	var wg sync.WaitGroup
+	for i := 0; i < messages; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.dispatch(context.Background(), &fakeMessage{})
+		}()
+	}
+	wg.Wait()
+	pool.stop()
+
+	if maxActive > size {
+		t.Errorf("Expected at most %d messages to be handled concurrently, got %d", size, maxActive)
+	}
+	if handled != messages {
+		t.Errorf("Expected all %d messages to be handled, got %d", messages, handled)
+	}
+}
+
+func TestWorkerPoolCancelledDispatch(t *testing.T) {
+	release := make(chan struct{})
+	pool := newWorkerPool(1, func(context.Context, messageInterface) {
+		<-release
+	})
+	// Keep the only worker busy.
+	pool.dispatch(context.Background(), &fakeMessage{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	msg := &nackRecordingMessage{}
+	pool.dispatch(ctx, msg)
+	if !msg.nacked {
+		t.Error("Expected the message to be nacked when no worker became available before cancellation")
+	}
+	close(release)
+	pool.stop()
+}